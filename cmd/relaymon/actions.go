@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	config "github.com/msaf1980/relaymon/config/relaymon"
+	"github.com/msaf1980/relaymon/pkg/action"
+	"github.com/msaf1980/relaymon/pkg/announcer"
+	"github.com/msaf1980/relaymon/pkg/checker"
+	"github.com/msaf1980/relaymon/pkg/promtext"
+)
+
+// Actions on transitions
+type Actions struct {
+	Success []action.Step
+	Error   []action.Step
+	// Warn on transition to warning state (degraded, but not evicted)
+	Warn []action.Step
+	// Recover on transition from warning to success state
+	Recover []action.Step
+	// Acquire and Release ip addresses on ownership change (in active/passive mode ip actions moved from success actions)
+	Acquire []action.Step
+	Release []action.Step
+	// Withdraw ip addresses and routes on shutdown (ip and route actions from error actions)
+	Withdraw []action.Step
+	// Shutdown on relaymon stop
+	Shutdown []action.Step
+}
+
+func newStep(cfg *config.Config, a *config.Action, addrs []*net.IPNet, ann announcer.Announcer, prefixes []*net.IPNet, success bool) (action.Step, error) {
+	step := action.Step{Retry: a.Retry, RetryInterval: a.RetryInterval, AbortOnFailure: a.AbortOnFailure}
+	var err error
+	switch a.Type {
+	case "ip":
+		step.Action = action.NewIP(cfg.Iface, addrs, success)
+	case "route":
+		step.Action = action.NewRoute(ann, prefixes, success)
+	case "exec":
+		step.Action, err = action.NewExec(a.Args, a.Env, a.Timeout)
+	case "systemd":
+		step.Action, err = action.NewSystemd(a.Op, a.Unit, a.Timeout)
+	case "http":
+		step.Action, err = action.NewHTTP(a.Method, a.URL, a.Headers, a.Body, a.Timeout)
+	case "touch":
+		step.Action, err = action.NewTouch(a.Path, a.Remove)
+	default:
+		return step, fmt.Errorf("action type '%s' unknown", a.Type)
+	}
+	return step, err
+}
+
+// ipManaged return true, if ip addresses are added with success actions
+func ipManaged(cfg *config.Config) bool {
+	for i := range cfg.Actions.Success {
+		if cfg.Actions.Success[i].Type == "ip" {
+			return true
+		}
+	}
+	return false
+}
+
+// newActions build actions from config
+func newActions(cfg *config.Config, addrs []*net.IPNet, ann announcer.Announcer, prefixes []*net.IPNet) (*Actions, error) {
+	actions := &Actions{
+		Success:  make([]action.Step, len(cfg.Actions.Success)),
+		Error:    make([]action.Step, len(cfg.Actions.Error)),
+		Warn:     make([]action.Step, len(cfg.Actions.Warn)),
+		Recover:  make([]action.Step, len(cfg.Actions.Recover)),
+		Shutdown: make([]action.Step, len(cfg.Actions.Shutdown)),
+	}
+	var err error
+	for i := range cfg.Actions.Success {
+		if actions.Success[i], err = newStep(cfg, &cfg.Actions.Success[i], addrs, ann, prefixes, true); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Peers.Election.Enabled {
+		success := actions.Success[:0]
+		for i := range actions.Success {
+			if cfg.Actions.Success[i].Type == "ip" {
+				actions.Acquire = append(actions.Acquire, actions.Success[i])
+				release := actions.Success[i]
+				release.Action = action.NewIP(cfg.Iface, addrs, false)
+				actions.Release = append(actions.Release, release)
+			} else {
+				success = append(success, actions.Success[i])
+			}
+		}
+		actions.Success = success
+	}
+	for i := range cfg.Actions.Error {
+		if actions.Error[i], err = newStep(cfg, &cfg.Actions.Error[i], addrs, ann, prefixes, false); err != nil {
+			return nil, err
+		}
+		if typ := cfg.Actions.Error[i].Type; typ == "ip" || typ == "route" {
+			actions.Withdraw = append(actions.Withdraw, actions.Error[i])
+		}
+	}
+	// ip and route actions are not allowed in warn, recover and shutdown actions (validated in config)
+	for i := range cfg.Actions.Warn {
+		if actions.Warn[i], err = newStep(cfg, &cfg.Actions.Warn[i], addrs, ann, prefixes, true); err != nil {
+			return nil, err
+		}
+	}
+	for i := range cfg.Actions.Recover {
+		if actions.Recover[i], err = newStep(cfg, &cfg.Actions.Recover[i], addrs, ann, prefixes, true); err != nil {
+			return nil, err
+		}
+	}
+	for i := range cfg.Actions.Shutdown {
+		if actions.Shutdown[i], err = newStep(cfg, &cfg.Actions.Shutdown[i], addrs, ann, prefixes, false); err != nil {
+			return nil, err
+		}
+	}
+	return actions, nil
+}
+
+// runActions run actions, log and count results, return results and true if any action failed
+func runActions(ctx context.Context, steps []action.Step, actionName string, registry *promtext.Registry, cfg *config.Config) ([]action.Result, bool) {
+	results, aborted := action.Run(ctx, steps)
+	for i := range results {
+		r := &results[i]
+		typ := r.Action.Type()
+		result := "success"
+		if r.Err != nil {
+			result = "failed"
+		}
+		registry.AddCounter("actions_total", "executed actions", 1,
+			checker.Label{Name: "action", Value: actionName}, checker.Label{Name: "type", Value: typ},
+			checker.Label{Name: "result", Value: result},
+		)
+
+		switch a := r.Action.(type) {
+		case *action.IP:
+			op := "del"
+			if a.Add {
+				op = "add"
+			}
+			if errs, ok := r.Err.(action.Errors); ok {
+				countIPErrors(registry, op, errs)
+			} else if r.Err != nil {
+				countIPErrors(registry, op, []error{r.Err})
+			} else if a.Add {
+				announceIPs(registry, a.Iface, a.Addrs, cfg.GARPCount, cfg.GARPInterval, actionName)
+			}
+		case *action.Exec, *action.Systemd:
+			countCommand(registry, actionName, r.Err)
+		}
+
+		if r.Err == nil {
+			log.Info().Str("action", actionName).Str("type", typ).Int("attempts", r.Attempts).Msg(r.Out)
+		} else {
+			log.Error().Str("action", actionName).Str("type", typ).Int("attempts", r.Attempts).Str("error", r.Err.Error()).Msg(r.Out)
+		}
+	}
+	if aborted {
+		log.Error().Str("action", actionName).Int("skipped", len(steps)-len(results)).Msg("actions aborted")
+	}
+	return results, action.Failed(results)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	config "github.com/msaf1980/relaymon/config/relaymon"
+)
+
+func TestNewActionsWithdraw(t *testing.T) {
+	cfg := &config.Config{Iface: "lo"}
+	cfg.Actions.Success = []config.Action{{Type: "ip"}, {Type: "route"}}
+	cfg.Actions.Error = []config.Action{
+		{Type: "exec", Args: []string{"systemctl", "stop", "carbon-c-relay"}},
+		{Type: "route"},
+		{Type: "touch", Path: "/tmp/relaymon.down"},
+		{Type: "ip"},
+	}
+	actions, err := newActions(cfg, mustParseCIDRs(t, "192.168.155.10/32"), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions.Error) != 4 {
+		t.Fatalf("error actions = %d, want 4", len(actions.Error))
+	}
+	var types []string
+	for i := range actions.Withdraw {
+		types = append(types, actions.Withdraw[i].Action.Type())
+	}
+	if !stringsEqual(types, []string{"route", "ip"}) {
+		t.Errorf("withdraw actions = %v, want [route ip]", types)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net"
+
+	config "github.com/msaf1980/relaymon/config/relaymon"
+	"github.com/msaf1980/relaymon/pkg/announcer"
+)
+
+// newAnnouncer create routes announcer (nil, if not configured)
+func newAnnouncer(cfg *config.Config) (announcer.Announcer, []*net.IPNet, error) {
+	if len(cfg.Announcer.Type) == 0 {
+		return nil, nil, nil
+	}
+	prefixes, err := parseIPs(cfg.Announcer.Prefixes)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := announcer.New(cfg.Announcer.Type, announcer.Options{
+		Pipe:     cfg.Announcer.Pipe,
+		NextHop:  cfg.Announcer.NextHop,
+		Peer:     cfg.Announcer.Peer,
+		LocalAS:  cfg.Announcer.LocalAS,
+		PeerAS:   cfg.Announcer.PeerAS,
+		RouterID: cfg.Announcer.RouterID,
+		HoldTime: cfg.Announcer.HoldTime,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return a, prefixes, nil
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	config "github.com/msaf1980/relaymon/config/relaymon"
+	"github.com/msaf1980/relaymon/pkg/aggregator"
+	carboncrelay "github.com/msaf1980/relaymon/pkg/carbon_c_relay"
+	"github.com/msaf1980/relaymon/pkg/carbonnetwork"
+	"github.com/msaf1980/relaymon/pkg/checker"
+	"github.com/msaf1980/relaymon/pkg/httpapi"
+	"github.com/msaf1980/relaymon/pkg/netconf"
+	"github.com/msaf1980/relaymon/pkg/systemd"
+	"github.com/msaf1980/relaymon/pkg/tcpnetwork"
+)
+
+// CheckStatus checker with last state and events
+type CheckStatus struct {
+	Checker checker.Checker
+	Status  checker.State
+	Events  []string
+	// Advisory checker errors not evict node (only warning)
+	Advisory bool
+}
+
+// Checkers describe configured checkers
+type Checkers struct {
+	Services []CheckStatus
+	Network  []CheckStatus
+	// RelayFiles is a carbon-c-relay config files (with includes)
+	RelayFiles []string
+	// RelayIncludes is a carbon-c-relay include glob patterns
+	RelayIncludes []string
+	// Aggregator for global state (all required checkers must succeed, if nil)
+	Aggregator *aggregator.Aggregator
+}
+
+// newCheckers build checkers from config
+func newCheckers(cfg *config.Config, backend systemd.Backend) (*Checkers, error) {
+	c := &Checkers{
+		Services: make([]CheckStatus, len(cfg.Services)),
+		Network:  make([]CheckStatus, 0),
+	}
+	for i := range c.Services {
+		service := &cfg.Services[i]
+		c.Services[i].Checker = systemd.NewServiceChecker(service.Name, service.FailCount, service.CheckCount, service.ResetCount).
+			SetBackend(backend).SetRestartsPolicy(service.MaxRestarts, service.RestartsWindow).SetProcess(service.Process)
+		c.Services[i].Advisory = !service.Required
+	}
+
+	// carbon-c-relay
+	if cfg.CarbonCRelay.Config != "" {
+		relayConfig, err := carboncrelay.Parse(cfg.CarbonCRelay.Config)
+		if err != nil {
+			return nil, fmt.Errorf("carbon-c-relay config load: %s", err.Error())
+		}
+		c.RelayFiles = relayConfig.Files
+		c.RelayIncludes = relayConfig.IncludePatterns
+		clusters, unreferenced := relayConfig.NetworkClusters(cfg.CarbonCRelay.Required, cfg.Prefix, cfg.NetTimeout,
+			cfg.CarbonCRelay.SkipUnreferenced)
+		log.Debug().Str("carbon-c-relay", "load config").Strs("files", relayConfig.Files).Msg("config parsed")
+		for _, err := range relayConfig.Undefined() {
+			log.Warn().Str("carbon-c-relay", "load config").Msg(err.Error())
+		}
+		for i := range unreferenced {
+			log.Warn().Str("carbon-c-relay", "load config").Str("cluster", unreferenced[i]).Msg("cluster not referenced by routes")
+		}
+		for i := range clusters {
+			clusters[i].SetUDPUnreachable(cfg.CarbonCRelay.UDPUnreachable)
+		}
+		checker := carbonnetwork.NewNetworkChecker("carbon-c-relay clusters", clusters, cfg.NetTimeout, cfg.FailCount, cfg.CheckCount, cfg.ResetCount)
+		if len(cfg.Relay) > 0 && len(cfg.Prefix) > 0 {
+			checker.SetNotify(true)
+		} else {
+			checker.SetNotify(false)
+		}
+		c.Network = append(c.Network, CheckStatus{Checker: checker})
+	}
+
+	for i := range cfg.TCPChecks {
+		targets := make([]tcpnetwork.Target, len(cfg.TCPChecks[i].Targets))
+		for j := range targets {
+			targets[j].Address = cfg.TCPChecks[i].Targets[j].Address
+			targets[j].Timeout = cfg.TCPChecks[i].Targets[j].Timeout
+			targets[j].Required = cfg.TCPChecks[i].Targets[j].Required
+		}
+		checker := tcpnetwork.NewTCPChecker(cfg.TCPChecks[i].Name, targets, cfg.TCPChecks[i].MinSuccess,
+			cfg.FailCount, cfg.CheckCount, cfg.ResetCount)
+		c.Network = append(c.Network, CheckStatus{Checker: checker})
+	}
+
+	groups := make([]aggregator.Group, len(cfg.Aggregation.Groups))
+	for i := range groups {
+		groups[i] = aggregator.Group{
+			Name:       cfg.Aggregation.Groups[i].Name,
+			Checkers:   cfg.Aggregation.Groups[i].Checkers,
+			MinSuccess: cfg.Aggregation.Groups[i].MinSuccess,
+			Required:   cfg.Aggregation.Groups[i].Required,
+		}
+	}
+	var err error
+	c.Aggregator, err = aggregator.New(aggregator.Policy{
+		Weights:    cfg.Aggregation.Weights,
+		MinWeight:  cfg.Aggregation.MinWeight,
+		Groups:     groups,
+		Expression: cfg.Aggregation.Expression,
+	}, c.Inputs())
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func mergeCheckStatus(checkers []CheckStatus, previous []CheckStatus) {
+	for i := range checkers {
+		for j := range previous {
+			if checkers[i].Checker.Name() == previous[j].Checker.Name() {
+				if m, ok := checkers[i].Checker.(checker.Merger); ok {
+					m.Merge(previous[j].Checker)
+				}
+				checkers[i].Status = previous[j].Status
+				checkers[i].Events = previous[j].Events
+				break
+			}
+		}
+	}
+}
+
+// Merge take state from previous checkers with same name (on config reload)
+func (c *Checkers) Merge(previous *Checkers) {
+	mergeCheckStatus(c.Services, previous.Services)
+	mergeCheckStatus(c.Network, previous.Network)
+}
+
+// Len return checkers count
+func (c *Checkers) Len() int {
+	return len(c.Services) + len(c.Network)
+}
+
+// Inputs return checkers states for aggregation
+func (c *Checkers) Inputs() []aggregator.Input {
+	inputs := make([]aggregator.Input, 0, c.Len())
+	for _, checkers := range [][]CheckStatus{c.Services, c.Network} {
+		for i := range checkers {
+			inputs = append(inputs, aggregator.Input{
+				Name:     checkers[i].Checker.Name(),
+				State:    checkers[i].Status,
+				Advisory: checkers[i].Advisory,
+			})
+		}
+	}
+	return inputs
+}
+
+// Aggregate return global state from checkers state with aggregation policy (see aggregator.Aggregate).
+// Ready is true, if policy succeeded without degraded checkers (node can be brought up in warn state).
+func (c *Checkers) Aggregate() (checker.State, bool) {
+	a := c.Aggregator
+	if a == nil {
+		a, _ = aggregator.New(aggregator.Policy{}, nil)
+	}
+	return a.Aggregate(c.Inputs())
+}
+
+// Status return checkers status for HTTP API
+func (c *Checkers) Status() []httpapi.CheckerStatus {
+	statuses := make([]httpapi.CheckerStatus, 0, c.Len())
+	for _, checkers := range [][]CheckStatus{c.Services, c.Network} {
+		for j := range checkers {
+			cs := &checkers[j]
+			events := cs.Events
+			if events == nil {
+				events = []string{}
+			}
+			statuses = append(statuses, httpapi.CheckerStatus{
+				Name:     cs.Checker.Name(),
+				State:    cs.Status.String(),
+				Code:     cs.Status,
+				Events:   events,
+				Counters: cs.Checker.Counters(),
+				Advisory: cs.Advisory,
+			})
+		}
+	}
+	return statuses
+}
+
+// Families return labeled metrics families (for cleanup on reload)
+func (c *Checkers) Families() map[string]bool {
+	families := make(map[string]bool)
+	for _, checkers := range [][]CheckStatus{c.Services, c.Network} {
+		for j := range checkers {
+			metrics := checkers[j].Checker.Metrics()
+			for k := range metrics {
+				if len(metrics[k].Family) > 0 {
+					families[metrics[k].Family] = true
+				}
+			}
+		}
+	}
+	return families
+}
+
+// netconfOptions is ip addresses backend and options
+type netconfOptions struct {
+	backend string
+	addr    netconf.AddrOptions
+}
+
+// parseNetconf check ip addresses backend and options (not applied)
+func parseNetconf(cfg *config.Config) (netconfOptions, error) {
+	var opts netconfOptions
+	scope, err := netconf.ParseScope(cfg.IPScope)
+	if err != nil {
+		return opts, err
+	}
+	flags, err := netconf.ParseFlags(cfg.IPFlags)
+	if err != nil {
+		return opts, err
+	}
+	if opts.backend, err = netconf.ParseBackend(cfg.IPBackend); err != nil {
+		return opts, err
+	}
+	opts.addr = netconf.AddrOptions{Label: cfg.IPLabel, Scope: scope, Flags: flags}
+	return opts, nil
+}
+
+// apply set ip addresses backend and options
+func (o netconfOptions) apply() {
+	_ = netconf.SetBackend(o.backend)
+	netconf.SetAddrOptions(o.addr)
+}
+
+// configureNetconf set ip addresses backend and options
+func configureNetconf(cfg *config.Config) error {
+	opts, err := parseNetconf(cfg)
+	if err != nil {
+		return err
+	}
+	opts.apply()
+	return nil
+}
+
+// parseIPs parse ip addresses (in ip/net format)
+func parseIPs(ips []string) ([]*net.IPNet, error) {
+	addrs := make([]*net.IPNet, len(ips))
+	for i := range ips {
+		ip, addr, err := net.ParseCIDR(ips[i])
+		if err != nil {
+			return nil, err
+		}
+		addr.IP = ip
+		addrs[i] = addr
+	}
+	return addrs, nil
+}
+
+// ipsDiff return addresses from a, not found in b
+func ipsDiff(a []*net.IPNet, b []*net.IPNet) []*net.IPNet {
+	diff := make([]*net.IPNet, 0)
+	for i := range a {
+		found := false
+		for j := range b {
+			if a[i].IP.Equal(b[j].IP) && netconf.IPMaskEqual(a[i].Mask, b[j].Mask) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			diff = append(diff, a[i])
+		}
+	}
+	return diff
+}
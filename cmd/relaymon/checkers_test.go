@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	config "github.com/msaf1980/relaymon/config/relaymon"
+	"github.com/msaf1980/relaymon/pkg/checker"
+)
+
+type fakeChecker struct {
+	name string
+}
+
+func (f *fakeChecker) Name() string {
+	return f.name
+}
+
+func (f *fakeChecker) Status(ctx context.Context, timestamp int64) (checker.State, []string) {
+	return checker.SuccessState, nil
+}
+
+func (f *fakeChecker) Metrics() []checker.Metric {
+	return nil
+}
+
+func (f *fakeChecker) Counters() checker.Counters {
+	return checker.Counters{}
+}
+
+func TestCheckersAggregate(t *testing.T) {
+	tests := []struct {
+		name      string
+		services  []checker.State
+		advisory  []bool
+		network   []checker.State
+		want      checker.State
+		wantReady bool
+	}{
+		{"success", []checker.State{checker.SuccessState}, []bool{false}, []checker.State{checker.SuccessState}, checker.SuccessState, true},
+		{"collecting", []checker.State{checker.CollectingState}, []bool{false}, []checker.State{checker.SuccessState}, checker.CollectingState, false},
+		{"error", []checker.State{checker.SuccessState}, []bool{false}, []checker.State{checker.ErrorState}, checker.ErrorState, false},
+		{"warn", []checker.State{checker.WarnState}, []bool{false}, []checker.State{checker.SuccessState}, checker.WarnState, false},
+		{
+			"advisory error",
+			[]checker.State{checker.SuccessState, checker.ErrorState}, []bool{false, true},
+			[]checker.State{checker.SuccessState}, checker.WarnState, true,
+		},
+		{
+			"advisory warn",
+			[]checker.State{checker.SuccessState, checker.WarnState}, []bool{false, true},
+			[]checker.State{checker.SuccessState}, checker.WarnState, true,
+		},
+		{
+			"advisory collecting",
+			[]checker.State{checker.SuccessState, checker.CollectingState}, []bool{false, true},
+			[]checker.State{checker.SuccessState}, checker.CollectingState, false,
+		},
+		{"not found", []checker.State{checker.NotFoundState}, []bool{false}, nil, checker.CollectingState, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c Checkers
+			for i, s := range tt.services {
+				c.Services = append(c.Services, CheckStatus{Checker: &fakeChecker{name: "service"}, Status: s, Advisory: tt.advisory[i]})
+			}
+			for _, s := range tt.network {
+				c.Network = append(c.Network, CheckStatus{Checker: &fakeChecker{name: "network"}, Status: s})
+			}
+			got, ready := c.Aggregate()
+			if got != tt.want || ready != tt.wantReady {
+				t.Errorf("Aggregate() = (%s, %v), want (%s, %v)", got.String(), ready, tt.want.String(), tt.wantReady)
+			}
+		})
+	}
+}
+
+func TestNewCheckersErrors(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.CarbonCRelay.Config = "/nonexistent/relay.conf"
+	if _, err := newCheckers(cfg, nil); err == nil || !strings.HasPrefix(err.Error(), "carbon-c-relay config load: ") {
+		t.Errorf("newCheckers() with invalid carbon-c-relay config error = %v", err)
+	}
+
+	cfg = &config.Config{}
+	cfg.Aggregation.Weights = map[string]float64{"unknown": 1}
+	if _, err := newCheckers(cfg, nil); err == nil || !strings.HasPrefix(err.Error(), "aggregation: ") {
+		t.Errorf("newCheckers() with invalid aggregation error = %v", err)
+	}
+}
+
+func TestLogStatusEvents(t *testing.T) {
+	c := &CheckStatus{Checker: &fakeChecker{name: "test"}, Status: checker.SuccessState}
+	logStatus(checker.ErrorState, c, []string{"connection refused"})
+	if c.Status != checker.ErrorState || len(c.Events) != 1 {
+		t.Fatalf("logStatus() on failure got status %s, events %v", c.Status.String(), c.Events)
+	}
+	logStatus(checker.ErrorState, c, nil)
+	if len(c.Events) != 1 {
+		t.Errorf("logStatus() without events on failure cleared events")
+	}
+	logStatus(checker.SuccessState, c, nil)
+	if c.Status != checker.SuccessState || len(c.Events) != 0 {
+		t.Errorf("logStatus() on success got status %s, events %v", c.Status.String(), c.Events)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"time"
+
+	config "github.com/msaf1980/relaymon/config/relaymon"
+	"github.com/msaf1980/relaymon/pkg/checker"
+	"github.com/msaf1980/relaymon/pkg/httpapi"
+	"github.com/msaf1980/relaymon/pkg/hysteresis"
+	"github.com/msaf1980/relaymon/pkg/promtext"
+)
+
+var heldReasons = []hysteresis.Reason{hysteresis.MinHold, hysteresis.Backoff, hysteresis.FlapLock, heldPeers}
+
+func hysteresisConfig(cfg *config.Config) hysteresis.Config {
+	return hysteresis.Config{
+		MinHold:       cfg.Hysteresis.MinHold,
+		Window:        cfg.Hysteresis.Window,
+		Backoff:       cfg.Hysteresis.Backoff,
+		MaxBackoff:    cfg.Hysteresis.MaxBackoff,
+		FlapThreshold: cfg.Hysteresis.FlapThreshold,
+	}
+}
+
+// transitionHold track held transition (for logs and metrics)
+type transitionHold struct {
+	reason hysteresis.Reason
+	detail string
+}
+
+// set current held transition reason (hysteresis.None, if not held)
+func (h *transitionHold) set(registry *promtext.Registry, statusHandler *httpapi.StatusHandler,
+	reason hysteresis.Reason, remain time.Duration, detail string, target checker.State) {
+	if (reason != h.reason || detail != h.detail) && reason != hysteresis.None {
+		e := log.Warn().Str("action", actionHold).Str("reason", string(reason)).Str("state", target.String())
+		if remain > 0 {
+			e = e.Str("remain", remain.Round(time.Second).String())
+		}
+		if len(detail) > 0 {
+			e = e.Str("detail", detail)
+		}
+		e.Msg("transition held")
+		if reason != h.reason {
+			registry.AddCounter("transitions_held_total", "held state transitions", 1,
+				checker.Label{Name: "reason", Value: string(reason)},
+			)
+		}
+	} else if reason == hysteresis.None && h.reason != hysteresis.None {
+		log.Info().Str("action", actionHold).Str("reason", string(h.reason)).Msg("transition released")
+	}
+	h.reason, h.detail = reason, detail
+	for _, r := range heldReasons {
+		var v float64
+		if r == reason {
+			v = 1
+		}
+		registry.SetGauge("transition_held", "state transition held (by reason)", v,
+			checker.Label{Name: "reason", Value: string(r)},
+		)
+	}
+	statusHandler.SetHeld(string(reason))
+}
+
+// logUnlock log flap lock clear (on SIGUSR1 or HTTP request)
+func logUnlock(wasLocked bool, source string) {
+	if wasLocked {
+		log.Warn().Str("action", actionUnlock).Str("source", source).Msg("flap lock cleared")
+	} else {
+		log.Info().Str("action", actionUnlock).Str("source", source).Msg("flap lock not set")
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"net"
 	"os"
-	"os/exec"
 	"os/signal"
 	"strconv"
 	"strings"
@@ -14,10 +13,16 @@ import (
 	"syscall"
 	"time"
 
-	carboncrelay "github.com/msaf1980/relaymon/pkg/carbon_c_relay"
-	"github.com/msaf1980/relaymon/pkg/carbonnetwork"
+	"github.com/msaf1980/relaymon/pkg/action"
+	"github.com/msaf1980/relaymon/pkg/announcer"
 	"github.com/msaf1980/relaymon/pkg/checker"
+	"github.com/msaf1980/relaymon/pkg/filewatch"
+	"github.com/msaf1980/relaymon/pkg/httpapi"
+	"github.com/msaf1980/relaymon/pkg/hysteresis"
 	"github.com/msaf1980/relaymon/pkg/netconf"
+	"github.com/msaf1980/relaymon/pkg/peers"
+	"github.com/msaf1980/relaymon/pkg/promtext"
+	"github.com/msaf1980/relaymon/pkg/spool"
 	"github.com/msaf1980/relaymon/pkg/systemd"
 
 	config "github.com/msaf1980/relaymon/config/relaymon"
@@ -27,26 +32,99 @@ import (
 
 var (
 	running     int32 = 1
-	ctx, cancel       = context.WithCancel(context.Background())
+	reload      int32
+	unlock      int32
+	ctx, cancel = context.WithCancel(context.Background())
 	log         zerolog.Logger
 	version     string
 
-	actionStop  = "stop"
-	actionCheck = "check"
-	actionDown  = "down"
-	actionUp    = "up"
+	actionStop    = "stop"
+	actionCheck   = "check"
+	actionDown    = "down"
+	actionUp      = "up"
+	actionWarn    = "warn"
+	actionRecover = "recover"
+	actionReload  = "reload"
+	actionHold    = "hold"
+	actionUnlock  = "unlock"
+	actionElect   = "elect"
 )
 
-type CheckStatus struct {
-	Checker checker.Checker
-	Status  checker.State
+const stateHelp = "state (0 - collecting, 1 - success, 2 - warn, 3 - error, 4 - not found, 5 - unknown)"
+
+func putMetrics(graphite *GraphiteQueue, registry *promtext.Registry, metrics []checker.Metric, timestamp int64) {
+	for k := range metrics {
+		graphite.PutMetric(&metrics[k], timestamp)
+		if len(metrics[k].Family) > 0 {
+			v, err := strconv.ParseFloat(metrics[k].Value, 64)
+			if err != nil {
+				continue
+			}
+			help := metrics[k].Help
+			if len(help) == 0 {
+				help = "checker " + stateHelp
+			}
+			if metrics[k].Counter {
+				registry.SetCounter(metrics[k].Family, help, v, metrics[k].Labels...)
+			} else {
+				registry.SetGauge(metrics[k].Family, help, v, metrics[k].Labels...)
+			}
+		}
+	}
+}
+
+// putGraphiteStats export metric sender counters as self-metrics
+func putGraphiteStats(graphite *GraphiteQueue, registry *promtext.Registry, timestamp int64) {
+	stats := graphite.Stats()
+	graphite.Put("metrics.sent", strconv.FormatUint(stats.Sent, 10), timestamp)
+	graphite.Put("metrics.spooled", strconv.FormatUint(stats.Spooled, 10), timestamp)
+	graphite.Put("metrics.dropped", strconv.FormatUint(stats.Dropped, 10), timestamp)
+	graphite.Put("metrics.spool_size", strconv.FormatInt(stats.SpoolSize, 10), timestamp)
+	registry.SetCounter("graphite_metrics_sent_total", "self-metrics sended to graphite_relay", float64(stats.Sent))
+	registry.SetCounter("graphite_metrics_spooled_total", "self-metrics written to spool", float64(stats.Spooled))
+	registry.SetCounter("graphite_metrics_dropped_total", "self-metrics dropped (queue or spool overflow)", float64(stats.Dropped))
+	registry.SetGauge("graphite_spool_bytes", "self-metrics spool size", float64(stats.SpoolSize))
+}
+
+func countCommand(registry *promtext.Registry, action string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failed"
+	}
+	registry.AddCounter("commands_total", "executed commands", 1,
+		checker.Label{Name: "action", Value: action}, checker.Label{Name: "result", Value: result},
+	)
+}
+
+func countIPErrors(registry *promtext.Registry, op string, errs []error) {
+	registry.AddCounter("ip_errors_total", "ip addresses configure failures", float64(len(errs)),
+		checker.Label{Name: "op", Value: op},
+	)
+}
+
+// announceIPs send gratuitous ARP/unsolicited NA for added ip addresses (in background)
+func announceIPs(registry *promtext.Registry, iface string, addrs []*net.IPNet, count int, interval time.Duration, action string) {
+	if count < 1 || len(addrs) == 0 {
+		return
+	}
+	go func() {
+		errs := netconf.Announce(iface, addrs, count, interval)
+		countIPErrors(registry, "announce", errs)
+		for i := range errs {
+			log.Error().Str("action", action).Str("type", "network").Msg(errs[i].Error())
+		}
+	}()
 }
 
 func logStatus(s checker.State, c *CheckStatus, events []string) {
 	if len(events) > 0 {
+		c.Events = events
 		for i := range events {
 			log.Info().Str("service", c.Checker.Name()).Msg(events[i])
 		}
+	} else if s == checker.SuccessState {
+		// failure events are outdated
+		c.Events = nil
 	}
 	if s != c.Status {
 		switch s {
@@ -67,26 +145,6 @@ func logStatus(s checker.State, c *CheckStatus, events []string) {
 	}
 }
 
-func execute(command string) (string, error) {
-	var err error
-	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctx, "sh", "-c", command)
-	out, err := cmd.CombinedOutput()
-	if ctx.Err() == context.DeadlineExceeded {
-		err = fmt.Errorf("command timeout")
-	} else if err != nil {
-		exitErr, ok := err.(*exec.ExitError)
-		if ok {
-			err = fmt.Errorf("command exit with %d", exitErr.ExitCode())
-		} else {
-			err = fmt.Errorf("command execute error with %s", err.Error())
-		}
-	}
-	return string(out), err
-}
-
 func main() {
 	configFile := flag.String("config", "/etc/relaymon.yml", "config file (in YAML)")
 	logLevel := flag.String("loglevel", "", "override loglevel")
@@ -122,26 +180,32 @@ func main() {
 
 	if *waitIp == 0 {
 		signalChannel := make(chan os.Signal, 2)
-		signal.Notify(signalChannel, os.Interrupt, syscall.SIGTERM)
+		signal.Notify(signalChannel, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1)
 		go func() {
-			sig := <-signalChannel
-			switch sig {
-			case os.Interrupt, syscall.SIGTERM:
-				log.Info().Str("action", actionStop).Msg("stopping")
-				cancel()
-				atomic.StoreInt32(&running, 0)
+			for sig := range signalChannel {
+				switch sig {
+				case os.Interrupt, syscall.SIGTERM:
+					log.Info().Str("action", actionStop).Msg("stopping")
+					cancel()
+					atomic.StoreInt32(&running, 0)
+					return
+				case syscall.SIGHUP:
+					log.Info().Str("action", actionReload).Msg("reload requested")
+					atomic.StoreInt32(&reload, 1)
+				case syscall.SIGUSR1:
+					log.Info().Str("action", actionUnlock).Msg("flap lock clear requested")
+					atomic.StoreInt32(&unlock, 1)
+				}
 			}
 		}()
 	}
 
-	addrs := make([]*net.IPNet, len(cfg.IPs))
-	for i := range cfg.IPs {
-		var ip net.IP
-		ip, addrs[i], err = net.ParseCIDR(cfg.IPs[i])
-		if err != nil {
-			log.Fatal().Msg(err.Error())
-		}
-		addrs[i].IP = ip
+	addrs, err := parseIPs(cfg.IPs)
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+	if err = configureNetconf(cfg); err != nil {
+		log.Fatal().Msg(err.Error())
 	}
 
 	if *evict {
@@ -149,7 +213,11 @@ func main() {
 
 		log.Debug().Str("action", actionStop).Msg("stopping")
 
-		out, err := execute("systemctl stop " + cfg.Service)
+		stop, err := action.NewSystemd("stop", cfg.Service, 0)
+		if err != nil {
+			log.Fatal().Str("action", actionStop).Msg(err.Error())
+		}
+		out, err := stop.Run(context.Background())
 		if err == nil {
 			log.Info().Str("action", actionStop).Str("type", "cmd").Msg(out)
 		} else {
@@ -157,21 +225,28 @@ func main() {
 			rc++
 		}
 
-		if len(addrs) > 0 {
-			errs := netconf.IfaceAddrDel(cfg.Iface, addrs)
-			if len(errs) > 0 {
-				rc++
-				for i := range errs {
-					log.Error().Msg(errs[i].Error())
-				}
+		var (
+			ann      announcer.Announcer
+			prefixes []*net.IPNet
+		)
+		if cfg.Announcer.Type == "exabgp" {
+			// BGP speaker routes are withdrawn on relaymon stop
+			if ann, prefixes, err = newAnnouncer(cfg); err != nil {
+				log.Fatal().Str("action", actionDown).Str("type", "announcer").Msg(err.Error())
 			}
 		}
-		if len(cfg.ErrorCmd) > 0 {
-			out, err := execute(cfg.ErrorCmd)
-			if err == nil {
-				log.Info().Str("action", actionDown).Str("type", "cmd").Msg(out)
-			} else {
-				log.Error().Str("action", actionDown).Str("type", "cmd").Str("error", err.Error()).Msg(out)
+		actions, err := newActions(cfg, addrs, ann, prefixes)
+		if err != nil {
+			log.Fatal().Str("action", actionDown).Msg(err.Error())
+		}
+		registry := promtext.NewRegistry("relaymon_")
+		if _, failed := runActions(context.Background(), actions.Error, actionDown, registry, cfg); failed {
+			rc++
+		}
+		if len(cfg.StateFile) > 0 {
+			// ip addresses removed, so saved state is invalid
+			if err = os.Remove(cfg.StateFile); err != nil && !os.IsNotExist(err) {
+				log.Error().Str("action", actionState).Msg(err.Error())
 				rc++
 			}
 		}
@@ -216,137 +291,325 @@ func main() {
 		os.Exit(1)
 	}
 
-	checkers := make([]CheckStatus, len(cfg.Services))
-	for i := range checkers {
-		checkers[i].Checker = systemd.NewServiceChecker(cfg.Services[i], cfg.FailCount, cfg.CheckCount, cfg.ResetCount)
+	backend, err := systemd.NewBackend(cfg.SystemdBackend)
+	if err != nil {
+		log.Fatal().Str("systemd", "backend").Msg(err.Error())
+	}
+	log.Debug().Str("systemd", "backend").Msg(fmt.Sprintf("%T", backend))
+
+	checkers, err := newCheckers(cfg, backend)
+	if err != nil {
+		log.Fatal().Str("relaymon", "checkers").Msg(err.Error())
 	}
 
-	netCheckers := make([]CheckStatus, 0)
+	ann, prefixes, err := newAnnouncer(cfg)
+	if err != nil {
+		log.Fatal().Str("relaymon", "announcer").Msg(err.Error())
+	}
+	actions, err := newActions(cfg, addrs, ann, prefixes)
+	if err != nil {
+		log.Fatal().Str("relaymon", "actions").Msg(err.Error())
+	}
 
-	graphite, _ := GraphiteInit(cfg.Relay, cfg.Prefix, 4096, 14)
+	var sp *spool.Spool
+	if len(cfg.GraphiteSpool.Path) > 0 {
+		if sp, err = spool.Open(cfg.GraphiteSpool.Path, cfg.GraphiteSpool.SegmentSize, cfg.GraphiteSpool.MaxSize); err != nil {
+			log.Fatal().Str("relaymon", "spool").Msg(err.Error())
+		}
+	}
+	graphite, err := GraphiteInit(cfg, 4096, 14, sp)
+	if err != nil {
+		log.Fatal().Str("relaymon", "metric").Msg(err.Error())
+	}
 	graphite.Run()
 
-	// carbon-c-relay
-	if cfg.CarbonCRelay.Config != "" {
-		clusters, err := carboncrelay.Clusters(cfg.CarbonCRelay.Config, cfg.CarbonCRelay.Required, cfg.Prefix, cfg.NetTimeout, &running)
-		if err != nil {
-			log.Fatal().Str("carbon-c-relay", "load config").Msg(err.Error())
-		} else {
-			checker := carbonnetwork.NewNetworkChecker("carbon-c-relay clusters", clusters, cfg.NetTimeout, cfg.FailCount, cfg.CheckCount, cfg.ResetCount)
-			if len(cfg.Relay) > 0 && len(cfg.Prefix) > 0 {
-				checker.SetNotify(true)
-			} else {
-				checker.SetNotify(false)
+	damper := hysteresis.New(hysteresisConfig(cfg))
+	gossip, err := newGossip(cfg)
+	if err != nil {
+		log.Fatal().Str("relaymon", "peers").Msg(err.Error())
+	}
+	elector := newElector(cfg, gossip)
+	var hold transitionHold
+
+	var server *httpapi.Server
+	statusHandler := httpapi.NewStatusHandler(cfg.Hostname, cfg.Iface, addrs)
+	registry := promtext.NewRegistry("relaymon_")
+	countIPErrors(registry, "add", nil)
+	countIPErrors(registry, "del", nil)
+	if len(cfg.Listen) > 0 {
+		server = httpapi.NewServer(cfg.Listen)
+		server.Handle("/status", statusHandler)
+		server.Handle("/metrics", registry)
+		server.Handle("/peers", httpapi.NewJSONHandler(func() interface{} {
+			if gossip == nil {
+				return []peers.Peer{}
 			}
-			netCheckers = append(netCheckers, CheckStatus{Checker: checker})
+			return gossip.Peers()
+		}))
+		server.Handle("/flap_lock", httpapi.NewFlapLockHandler(damper, func(wasLocked bool) {
+			logUnlock(wasLocked, "http")
+		}))
+		err = server.Start(func(err error) {
+			log.Error().Str("relaymon", "http").Msg(err.Error())
+		})
+		if err != nil {
+			log.Fatal().Str("relaymon", "http").Msg(err.Error())
 		}
+		log.Info().Str("relaymon", "http").Msg("listen on " + server.Addr())
+	}
+
+	notifier, err := newNotifier(cfg, registry)
+	if err != nil {
+		log.Fatal().Str("relaymon", "notify").Msg(err.Error())
 	}
 
-	status := checker.CollectingState
-	checks := len(checkers) + len(netCheckers)
+	var watcher *filewatch.Watcher
+	if cfg.WatchConfig {
+		watcher = watchConfig(*configFile, checkers.RelayFiles, checkers.RelayIncludes)
+	}
+
+	// failedSince is time of local checks failure (for peers policy)
+	var failedSince time.Time
+	// ipOwned is true, if ip addresses configured by elected owner (in active/passive mode)
+	var electReason string
+	status, history, ipOwned := restoreState(cfg, checkers, addrs, time.Now())
+	damper.Restore(history)
+	if elector != nil {
+		elector.Restore(ipOwned)
+	}
+	if ann != nil && (status == checker.SuccessState || status == checker.WarnState) {
+		// routes are withdrawn by announcer on restart
+		if err = ann.Announce(prefixes); err == nil {
+			log.Info().Str("action", actionState).Str("type", "route").Msg("routes announced")
+		} else {
+			log.Error().Str("action", actionState).Str("type", "route").Msg(err.Error())
+		}
+	}
 BREAK_LOOP:
 	for atomic.LoadInt32(&running) == 1 {
-		stepStatus := checker.CollectingState
-		timestamp := time.Now().Unix()
+		if atomic.CompareAndSwapInt32(&reload, 1, 0) {
+			r, err := reloadConfig(*configFile, *logLevel, cfg, backend, ann, prefixes, registry)
+			if err == nil {
+				newCfg, newAddrs, newCheckers := r.cfg, r.addrs, r.checkers
+				r.apply()
+				if notifier != nil {
+					// wait for pending notifications in background
+					go notifier.Close(10 * time.Second)
+				}
+				notifier = r.notifier
+				newCheckers.Merge(checkers)
+				if ipManaged(cfg) && ipManaged(newCfg) {
+					ipStatus := status
+					if elector != nil && !ipOwned {
+						// ip addresses not configured on backup node
+						ipStatus = checker.CollectingState
+					}
+					reconfigureIPs(ipStatus, cfg, addrs, newCfg, newAddrs, registry)
+				}
+				for family := range checkers.Families() {
+					registry.Delete(family)
+				}
+				cfg, addrs, checkers, actions = newCfg, newAddrs, newCheckers, r.actions
+				damper.SetConfig(hysteresisConfig(cfg))
+				if elector != nil {
+					elector.SetConfig(electionConfig(cfg))
+				}
+				statusHandler.SetIPs(cfg.Iface, addrs)
+				if watcher != nil {
+					watcher.Close()
+					watcher = nil
+				}
+				if cfg.WatchConfig {
+					watcher = watchConfig(*configFile, checkers.RelayFiles, checkers.RelayIncludes)
+				}
+				log.Info().Str("action", actionReload).Msg("config reloaded")
+			} else {
+				log.Error().Str("action", actionReload).Msg(err.Error())
+			}
+		}
+
+		if atomic.CompareAndSwapInt32(&unlock, 1, 0) {
+			logUnlock(damper.Unlock(), "signal")
+		}
+
+		now := time.Now()
+		timestamp := now.Unix()
 
 		// services
-		success := 0
-		for i := range checkers {
+		for i := range checkers.Services {
+			c := &checkers.Services[i]
 
-			log.Trace().Str("action", actionCheck).Str("checker", checkers[i].Checker.Name()).Msg("next check iteration")
+			log.Trace().Str("action", actionCheck).Str("checker", c.Checker.Name()).Msg("next check iteration")
 
-			s, errs := checkers[i].Checker.Status(ctx, timestamp)
-			if s == checker.ErrorState {
-				stepStatus = checker.ErrorState
-			} else if s == checker.SuccessState {
-				success++
+			s, errs := c.Checker.Status(ctx, timestamp)
+			if c.Advisory && s == checker.ErrorState && c.Status != checker.ErrorState {
+				log.Warn().Str("service", c.Checker.Name()).Msg("advisory service failed, node not evicted")
 			}
-			logStatus(s, &checkers[i], errs)
+			logStatus(s, c, errs)
 
-			metrics := checkers[i].Checker.Metrics()
-			for k := range metrics {
-				graphite.Put(metrics[k].Name, metrics[k].Value, timestamp)
-			}
+			putMetrics(graphite, registry, c.Checker.Metrics(), timestamp)
 
-			log.Trace().Str("action", actionCheck).Str("checker", checkers[i].Checker.Name()).Msg("end check iteration")
+			log.Trace().Str("action", actionCheck).Str("checker", c.Checker.Name()).Msg("end check iteration")
 		}
 
-		for i := range netCheckers {
+		for i := range checkers.Network {
+			c := &checkers.Network[i]
 
-			log.Trace().Str("action", actionCheck).Str("network_checker", netCheckers[i].Checker.Name()).Msg("next check iteration")
+			log.Trace().Str("action", actionCheck).Str("network_checker", c.Checker.Name()).Msg("next check iteration")
 
-			s, errs := netCheckers[i].Checker.Status(ctx, timestamp)
-			if s == checker.ErrorState {
-				stepStatus = checker.ErrorState
-			} else if s == checker.SuccessState {
-				success++
-			}
-			metrics := netCheckers[i].Checker.Metrics()
-			for k := range metrics {
-				graphite.Put(metrics[k].Name, metrics[k].Value, timestamp)
-			}
+			s, errs := c.Checker.Status(ctx, timestamp)
+			putMetrics(graphite, registry, c.Checker.Metrics(), timestamp)
 
-			logStatus(s, &netCheckers[i], errs)
+			logStatus(s, c, errs)
 
-			log.Trace().Str("action", actionCheck).Str("network_checker", netCheckers[i].Checker.Name()).Msg("end check iteration")
+			log.Trace().Str("action", actionCheck).Str("network_checker", c.Checker.Name()).Msg("end check iteration")
 		}
 
-		if success == checks {
-			stepStatus = checker.SuccessState
+		stepStatus, ready := checkers.Aggregate()
+		if stepStatus != checker.ErrorState {
+			failedSince = time.Time{}
+		} else if failedSince.IsZero() {
+			failedSince = now
 		}
 
+		prevStatus := status
+		var (
+			results []action.Result
+			held    hysteresis.Reason
+			remain  time.Duration
+			detail  string
+		)
 		if status != stepStatus {
 			// status changed
-			if stepStatus == checker.ErrorState {
+			up := status == checker.SuccessState || status == checker.WarnState
+			switch stepStatus {
+			case checker.ErrorState:
 				// checks failed
-				log.Error().Str("action", actionStop).Msg("go to error state")
-				status = checker.ErrorState
-				if len(cfg.IPs) > 0 {
-					errs := netconf.IfaceAddrDel(cfg.Iface, addrs)
-					if len(errs) > 0 {
-						for i := range errs {
-							log.Error().Str("action", actionUp).Str("type", "network").Msg(errs[i].Error())
-						}
-					} else {
-						log.Info().Str("action", actionStop).Str("type", "network").Msg("IP addresses deconfigured")
+				if up {
+					if held, remain = damper.CanDown(now); held != hysteresis.None {
+						break
 					}
-				}
-				if len(cfg.ErrorCmd) > 0 {
-					out, err := execute(cfg.ErrorCmd)
-					if err == nil {
-						log.Info().Str("action", actionStop).Str("type", "cmd").Msg(out)
-					} else {
-						log.Error().Str("action", actionStop).Str("type", "cmd").Str("error", err.Error()).Msg(out)
+					var allow bool
+					if allow, detail = allowWithdraw(cfg, gossip, failedSince); !allow {
+						held = heldPeers
+						break
 					}
-
 				}
-			} else if stepStatus == checker.SuccessState {
+				log.Error().Str("action", actionStop).Msg("go to error state")
+				status = checker.ErrorState
+				results, _ = runActions(ctx, actions.Error, actionDown, registry, cfg)
+				ipOwned = false
+				if up && damper.Down(now) {
+					_, reason := damper.Locked()
+					log.Error().Str("action", actionHold).Str("reason", string(hysteresis.FlapLock)).Msg("flap lock set, " + reason)
+				}
+			case checker.SuccessState:
 				// checks success
-				status = checker.SuccessState
-				if len(cfg.IPs) > 0 {
-					errs := netconf.IfaceAddrAdd(cfg.Iface, addrs)
-					if len(errs) > 0 {
+				if status == checker.WarnState {
+					log.Info().Str("action", actionRecover).Msg("warning cleared")
+					status = checker.SuccessState
+					results, _ = runActions(ctx, actions.Recover, actionRecover, registry, cfg)
+				} else {
+					if held, remain = damper.CanUp(now); held != hysteresis.None {
+						break
+					}
+					status = checker.SuccessState
+					var failed bool
+					results, failed = runActions(ctx, actions.Success, actionUp, registry, cfg)
+					if failed {
+						// retry on next check
 						status = checker.ErrorState
-						for i := range errs {
-							log.Error().Str("action", actionUp).Str("type", "network").Msg(errs[i].Error())
-						}
 					} else {
-						log.Info().Str("action", actionUp).Str("type", "network").Msg("IP addresses configured")
+						damper.Up(now)
 					}
 				}
-				if len(cfg.SuccessCmd) > 0 {
-					out, err := execute(cfg.SuccessCmd)
-					if err == nil {
-						log.Info().Str("action", actionUp).Str("type", "cmd").Msg(out)
-					} else {
+			case checker.WarnState:
+				// node is degraded, but not evicted
+				if status != checker.SuccessState {
+					if !ready {
+						// not recovered yet, stay in current state
+						break
+					}
+					if held, remain = damper.CanUp(now); held != hysteresis.None {
+						break
+					}
+					var failed bool
+					results, failed = runActions(ctx, actions.Success, actionUp, registry, cfg)
+					if failed {
+						// retry on next check
 						status = checker.ErrorState
-						log.Error().Str("action", actionUp).Str("type", "cmd").Str("error", err.Error()).Msg(out)
+						break
 					}
+					damper.Up(now)
 				}
+				log.Warn().Str("action", actionWarn).Msg("go to warning state")
+				status = checker.WarnState
+				warnResults, _ := runActions(ctx, actions.Warn, actionWarn, registry, cfg)
+				results = append(results, warnResults...)
 			}
 		}
 
+		if status != prevStatus {
+			if notifier != nil {
+				notifier.Notify(notifyPayload(cfg, timestamp, prevStatus, status, checkers, results))
+			}
+			registry.AddCounter("state_transitions_total", "global state transitions", 1,
+				checker.Label{Name: "state", Value: status.String()},
+			)
+		}
+
+		hold.set(registry, statusHandler, held, remain, detail, stepStatus)
+		up := status == checker.SuccessState || status == checker.WarnState
+		if elector != nil {
+			owner, reason := elector.Elect(now, up)
+			if reason != electReason {
+				log.Info().Str("action", actionElect).Bool("owner", owner).Msg(reason)
+				electReason = reason
+			}
+			if owner && up && !ipOwned {
+				log.Info().Str("action", actionElect).Msg("acquire ip addresses")
+				ownResults, failed := runActions(ctx, actions.Acquire, actionElect, registry, cfg)
+				results = append(results, ownResults...)
+				// retry on next check
+				ipOwned = !failed
+			} else if !owner && ipOwned {
+				log.Info().Str("action", actionElect).Msg("release ip addresses")
+				ownResults, _ := runActions(ctx, actions.Release, actionElect, registry, cfg)
+				results = append(results, ownResults...)
+				ipOwned = false
+			}
+			var owned float64
+			if ipOwned {
+				owned = 1
+			}
+			registry.SetGauge("election_owner", "ip addresses owner (active/passive mode)", owned)
+		}
+		if gossip != nil {
+			s := peersState(status, stepStatus, checkers)
+			if elector != nil {
+				elector.Fill(&s, up)
+			}
+			gossip.Update(s)
+		}
+		var flapLocked float64
+		if locked, _ := damper.Locked(); locked {
+			flapLocked = 1
+		}
+
 		graphite.Put("status", strconv.Itoa(int(stepStatus)), timestamp)
+		graphite.Put("flap_locked", strconv.Itoa(int(flapLocked)), timestamp)
+		putGraphiteStats(graphite, registry, timestamp)
+		registry.SetGauge("status", "global check "+stateHelp, float64(stepStatus))
+		registry.SetGauge("flap_locked", "node held down until flap lock cleared", flapLocked)
+		if ann != nil {
+			var established float64
+			if ann.Established() {
+				established = 1
+			}
+			registry.SetGauge("announcer_established", "routes announcer session established", established)
+		}
+		statusHandler.Update(status, timestamp, checkers.Status())
+		saveState(cfg, status, damper.History(), checkers, addrs, ownedIPs(cfg, elector != nil, status, ipOwned), now)
 
 		log.Trace().Str("action", actionCheck).Msg("sleep")
 
@@ -355,12 +618,60 @@ BREAK_LOOP:
 			if atomic.LoadInt32(&running) == 0 {
 				break BREAK_LOOP
 			}
+			if atomic.LoadInt32(&reload) == 1 {
+				continue BREAK_LOOP
+			}
 			time.Sleep(time.Second)
 			sleepInterval -= time.Second
 		}
 		time.Sleep(sleepInterval)
 	}
 
-	graphite.Stop()
-	log.Info().Msg("shutdown")
+	// shutdown sequence (signal context is cancelled, so with own deadline)
+	start := time.Now()
+	deadline := start.Add(cfg.ShutdownTimeout)
+	log.Info().Str("action", actionShutdown).Str("on_shutdown", cfg.OnShutdown).Str("timeout", cfg.ShutdownTimeout.String()).
+		Msg("shutdown started")
+	shutdownCtx, shutdownCancel := context.WithDeadline(context.Background(), deadline)
+	ipsState := shutdownIPs(shutdownCtx, cfg, actions, registry, status, ipOwned)
+	if ipsState == shutdownIPsWithdrawn {
+		status = checker.ErrorState
+		ipOwned = false
+	}
+	shutdownResults, shutdownFailed := runActions(shutdownCtx, actions.Shutdown, actionShutdown, registry, cfg)
+	shutdownCancel()
+
+	saveState(cfg, status, damper.History(), checkers, addrs, ownedIPs(cfg, elector != nil, status, ipOwned), time.Now())
+
+	if watcher != nil {
+		watcher.Close()
+	}
+	if ann != nil {
+		_ = ann.Close()
+	}
+	if gossip != nil {
+		_ = gossip.Close()
+	}
+	if server != nil {
+		_ = server.Stop(time.Second)
+	}
+	// pending notifications and queued metrics are sent concurrently, so slow webhooks don't consume metrics flush deadline
+	notifierDone := make(chan struct{})
+	go func() {
+		if notifier != nil {
+			notifier.Close(time.Until(deadline))
+		}
+		close(notifierDone)
+	}()
+	flushed := graphite.Stop(time.Until(deadline))
+	<-notifierDone
+	if sp != nil {
+		_ = sp.Close()
+	}
+	stats := graphite.Stats()
+	log.Info().Str("action", actionShutdown).Str("ips", ipsState).
+		Int("actions", len(shutdownResults)).Bool("actions_failed", shutdownFailed).
+		Bool("metrics_flushed", flushed).Uint64("metrics_sent", stats.Sent).Uint64("metrics_spooled", stats.Spooled).
+		Uint64("metrics_dropped", stats.Dropped).Str("duration", time.Since(start).String()).
+		Msg("shutdown")
 }
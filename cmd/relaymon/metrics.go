@@ -1,54 +1,301 @@
 package main
 
 import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	lockfree_queue "github.com/msaf1980/go-lockfree-queue"
-	graphite "github.com/msaf1980/graphite-golang"
+	config "github.com/msaf1980/relaymon/config/relaymon"
+	"github.com/msaf1980/relaymon/pkg/checker"
+	"github.com/msaf1980/relaymon/pkg/graphiteout"
+	"github.com/msaf1980/relaymon/pkg/spool"
 )
 
+// destination is self-metrics receiver
+type destination struct {
+	client *graphiteout.Client
+	failed bool
+	// retry is time of next connect attempt after failure
+	retry time.Time
+}
+
+// send metrics to destination, connect if needed (not more often than retryInterval after failure)
+func (d *destination) send(metrics []*graphiteout.Metric, now time.Time) error {
+	if !d.client.IsConnected() {
+		if now.Before(d.retry) {
+			return errDestinationRetry
+		}
+		if err := d.client.Connect(); err != nil {
+			_ = d.client.Disconnect()
+			d.fail(err, now)
+			return err
+		}
+	}
+	if err := d.client.Send(metrics); err != nil {
+		_ = d.client.Disconnect()
+		d.fail(err, now)
+		return err
+	}
+	if d.failed {
+		d.failed = false
+		log.Info().Str("relaymon", "metric").Str("destination", d.client.Address()).Msg("metrics sended")
+	}
+	return nil
+}
+
+func (d *destination) fail(err error, now time.Time) {
+	d.retry = now.Add(retryInterval)
+	if !d.failed {
+		d.failed = true
+		log.Error().Str("relaymon", "metric").Str("destination", d.client.Address()).Msg(err.Error())
+	}
+}
+
+// sender states
+const (
+	senderStopped int32 = iota
+	senderRunning
+	// senderStopping flush queue and stop
+	senderStopping
+)
+
+const (
+	retryInterval = time.Second
+	sendTimeout   = 5 * time.Second
+)
+
+var errDestinationRetry = errors.New("destination unavailable")
+
 type GraphiteQueue struct {
-	queue     *lockfree_queue.Queue
-	graphite  *graphite.Graphite
+	queue        *lockfree_queue.Queue
+	destinations []*destination
+	// failover send to first available destination (to all destinations, if false)
+	failover  bool
 	batchSend int
+	// spool for metrics, not sended while all destinations unavailable (disabled if nil)
+	spool *spool.Spool
+	// host tag for tagged series
+	host string
+
+	// counters
+	sent    uint64
+	spooled uint64
+	dropped uint64
 
-	failed  bool
-	running bool
+	// state of sender goroutine
+	state int32
+	done  chan struct{}
 }
 
-// GraphiteInit init metric sender
-func GraphiteInit(address string, prefix string, queueSize int, batchSend int) (*GraphiteQueue, error) {
-	return new(GraphiteQueue).init(address, prefix, queueSize, batchSend)
+// GraphiteStats is metric sender counters
+type GraphiteStats struct {
+	Sent    uint64
+	Spooled uint64
+	Dropped uint64
+	// Spool size in bytes
+	SpoolSize int64
 }
 
-func (g *GraphiteQueue) init(address string, prefix string, queueSize int, batchSend int) (*GraphiteQueue, error) {
-	if len(address) > 0 {
-		var err error
+// GraphiteInit init metric sender for destinations (with optional disk spool)
+func GraphiteInit(cfg *config.Config, queueSize int, batchSend int, sp *spool.Spool) (*GraphiteQueue, error) {
+	return new(GraphiteQueue).init(cfg, queueSize, batchSend, sp)
+}
+
+func (g *GraphiteQueue) init(cfg *config.Config, queueSize int, batchSend int, sp *spool.Spool) (*GraphiteQueue, error) {
+	if len(cfg.GraphiteDestinations) > 0 {
 		g.queue = lockfree_queue.NewQueue(queueSize)
 		if batchSend < 1 {
 			g.batchSend = 1
 		} else {
 			g.batchSend = batchSend
 		}
-		g.graphite, err = graphite.NewGraphiteWithMetricPrefix(address, prefix)
-		return g, err
-	} else {
-		return g, nil
+		g.failover = cfg.GraphiteMode == "failover"
+		g.spool = sp
+		g.host = cfg.Hostname
+		// hostname is tag in tagged series
+		taggedPrefix := strings.TrimSuffix(cfg.Prefix, "."+checker.Strip(cfg.Hostname))
+		g.destinations = make([]*destination, len(cfg.GraphiteDestinations))
+		for i := range cfg.GraphiteDestinations {
+			d := &cfg.GraphiteDestinations[i]
+			client, err := graphiteout.New(d.Address, d.Protocol, cfg.Prefix, d.Tagged, taggedPrefix, sendTimeout)
+			if err != nil {
+				return g, err
+			}
+			g.destinations[i] = &destination{client: client}
+		}
+	}
+	return g, nil
+}
+
+// send metrics to all destinations or to first available destination (in failover mode),
+// return error if no destination available
+func (g *GraphiteQueue) send(metrics []*graphiteout.Metric) error {
+	now := time.Now()
+	var (
+		sent    bool
+		lastErr error
+	)
+	for _, d := range g.destinations {
+		if err := d.send(metrics, now); err != nil {
+			lastErr = err
+			continue
+		}
+		sent = true
+		if g.failover {
+			break
+		}
+	}
+	if sent {
+		atomic.AddUint64(&g.sent, uint64(len(metrics)))
+		return nil
+	}
+	return lastErr
+}
+
+// connected return true, if any destination connected
+func (g *GraphiteQueue) connected() bool {
+	for _, d := range g.destinations {
+		if d.client.IsConnected() {
+			return true
+		}
 	}
+	return false
 }
 
-// Put metric to queue
+// Put metric to queue (tagged series is name with host tag)
 func (g *GraphiteQueue) Put(name, value string, timestamp int64) {
 	if g.queue == nil {
 		return
 	}
-	m := graphite.NewMetricPtr(name, value, timestamp)
+	g.put(&graphiteout.Metric{
+		Name: name, Value: value, Timestamp: timestamp,
+		Series: graphiteout.Series(name, checker.Label{Name: "host", Value: g.host}),
+	})
+}
+
+// PutMetric put checker metric to queue (tagged series is metric family with labels and host tag)
+func (g *GraphiteQueue) PutMetric(m *checker.Metric, timestamp int64) {
+	if g.queue == nil {
+		return
+	}
+	if len(m.Family) == 0 {
+		g.Put(m.Name, m.Value, timestamp)
+		return
+	}
+	labels := make([]checker.Label, 0, len(m.Labels)+1)
+	labels = append(labels, m.Labels...)
+	labels = append(labels, checker.Label{Name: "host", Value: g.host})
+	g.put(&graphiteout.Metric{
+		Name: m.Name, Value: m.Value, Timestamp: timestamp, Series: graphiteout.Series(m.Family, labels...),
+	})
+}
+
+func (g *GraphiteQueue) put(m *graphiteout.Metric) {
 	if !g.queue.Put(m) {
-		// drop last two elements and try put again
-		g.queue.Get()
-		g.queue.Get()
-		g.queue.Put(m)
+		// drop (or spool) last two elements and try put again
+		evicted := make([]*graphiteout.Metric, 0, 2)
+		for i := 0; i < 2; i++ {
+			if e, _ := g.queue.Get(); e != nil {
+				evicted = append(evicted, e.(*graphiteout.Metric))
+			}
+		}
+		g.spoolMetrics(evicted)
+		if !g.queue.Put(m) {
+			atomic.AddUint64(&g.dropped, 1)
+		}
+	}
+}
+
+// Stats get metric sender counters
+func (g *GraphiteQueue) Stats() GraphiteStats {
+	stats := GraphiteStats{
+		Sent:    atomic.LoadUint64(&g.sent),
+		Spooled: atomic.LoadUint64(&g.spooled),
+		Dropped: atomic.LoadUint64(&g.dropped),
+	}
+	if g.spool != nil {
+		stats.SpoolSize = g.spool.Size()
+	}
+	return stats
+}
+
+// spoolMetrics write metrics to spool (or drop, if spool disabled)
+func (g *GraphiteQueue) spoolMetrics(metrics []*graphiteout.Metric) {
+	if len(metrics) == 0 {
+		return
+	}
+	if g.spool == nil {
+		atomic.AddUint64(&g.dropped, uint64(len(metrics)))
+		return
+	}
+	records := make([]string, len(metrics))
+	for i, m := range metrics {
+		records[i] = m.Name + " " + m.Value + " " + strconv.FormatInt(m.Timestamp, 10) + " " + m.Series
+	}
+	dropped, err := g.spool.Write(records)
+	if err != nil {
+		log.Error().Str("relaymon", "metric").Str("type", "spool").Msg(err.Error())
+		atomic.AddUint64(&g.dropped, uint64(len(metrics)))
+		return
+	}
+	atomic.AddUint64(&g.spooled, uint64(len(metrics)))
+	atomic.AddUint64(&g.dropped, uint64(dropped))
+}
+
+// drainSpool send oldest spool segment (segment is removed after send), return false on send error
+func (g *GraphiteQueue) drainSpool() bool {
+	if g.spool == nil || g.spool.Len() == 0 {
+		return true
+	}
+	id, records, err := g.spool.Oldest()
+	if err != nil {
+		// broken segment
+		log.Error().Str("relaymon", "metric").Str("type", "spool").Msg(err.Error())
+		n, _ := g.spool.Remove(id)
+		atomic.AddUint64(&g.dropped, uint64(n))
+		return true
+	}
+	metrics := make([]*graphiteout.Metric, 0, g.batchSend)
+	for i := range records {
+		// name value timestamp [series]
+		fields := strings.Split(records[i], " ")
+		if len(fields) < 3 || len(fields) > 4 {
+			atomic.AddUint64(&g.dropped, 1)
+			continue
+		}
+		timestamp, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			atomic.AddUint64(&g.dropped, 1)
+			continue
+		}
+		m := &graphiteout.Metric{Name: fields[0], Value: fields[1], Timestamp: timestamp}
+		if len(fields) == 4 {
+			m.Series = fields[3]
+		}
+		metrics = append(metrics, m)
+		if len(metrics) == g.batchSend {
+			// resend whole segment on error (metrics with same timestamp are overwritten in graphite)
+			if err = g.send(metrics); err != nil {
+				return false
+			}
+			metrics = metrics[:0]
+		}
+	}
+	if len(metrics) > 0 {
+		if err = g.send(metrics); err != nil {
+			return false
+		}
 	}
+	if _, err = g.spool.Remove(id); err != nil {
+		log.Error().Str("relaymon", "metric").Str("type", "spool").Msg(err.Error())
+	}
+	if g.spool.Len() == 0 {
+		log.Info().Str("relaymon", "metric").Str("type", "spool").Msg("spooled metrics sended")
+	}
+	return true
 }
 
 // Run goroutune for queue read and send metrics
@@ -56,53 +303,58 @@ func (g *GraphiteQueue) Run() {
 	if g.queue == nil {
 		return
 	}
-	g.running = true
+	atomic.StoreInt32(&g.state, senderRunning)
+	g.done = make(chan struct{})
 	go func() {
-		metrics := make([]*graphite.Metric, g.batchSend)
+		defer close(g.done)
+		metrics := make([]*graphiteout.Metric, g.batchSend)
 		i := 0
 		nextSend := false
-		for g.running {
+		for {
+			state := atomic.LoadInt32(&g.state)
+			if state == senderStopped {
+				break
+			}
 			if i == g.batchSend || nextSend {
-				if !g.graphite.IsConnected() {
-					err := g.graphite.Connect()
-					if err != nil {
-						_ = g.graphite.Disconnect()
-						if !g.failed {
-							g.failed = true
-							log.Error().Str("relaymon", "metric").Msg(err.Error())
-						}
-						time.Sleep(1 * time.Second)
-						continue
-					}
-				}
-				err := g.graphite.SendMetricPtrs(metrics[0:i])
-				if err == nil {
+				if err := g.send(metrics[0:i]); err == nil {
 					i = 0
 					nextSend = false
-					if g.failed {
-						g.failed = false
-						log.Info().Str("relaymon", "metric").Msg("metrics sended")
+					if state == senderRunning {
+						// drain spool after reconnect (one segment per batch, so queue is not blocked)
+						g.drainSpool()
 					}
 				} else {
-					if !g.failed {
-						g.failed = true
-						log.Error().Str("relaymon", "metric").Msg(err.Error())
-						g.graphite.Disconnect()
+					if g.spool != nil {
+						// don't block queue while destinations unavailable
+						g.spoolMetrics(metrics[0:i])
+						i = 0
+						nextSend = false
 					}
+					time.Sleep(retryInterval)
 					continue
 				}
 			}
 			if i < g.batchSend {
 				m, _ := g.queue.Get()
 				if m != nil {
-					metrics[i] = m.(*graphite.Metric)
+					metrics[i] = m.(*graphiteout.Metric)
 					i++
 				} else {
 					m, _ := g.queue.Get()
 					if m != nil {
-						metrics[i] = m.(*graphite.Metric)
+						metrics[i] = m.(*graphiteout.Metric)
 						i++
 					} else if i == 0 {
+						if state == senderStopping {
+							// queue flushed
+							return
+						}
+						if g.spool != nil && g.spool.Len() > 0 && g.connected() {
+							// queue is empty, drain spool
+							if g.drainSpool() {
+								continue
+							}
+						}
 						time.Sleep(1 * time.Second)
 					} else {
 						nextSend = true
@@ -110,10 +362,41 @@ func (g *GraphiteQueue) Run() {
 				}
 			}
 		}
+		// stopped before queue flushed, spool (or drop) not sended metrics
+		for {
+			m, _ := g.queue.Get()
+			if m == nil {
+				break
+			}
+			if i == g.batchSend {
+				g.spoolMetrics(metrics[0:i])
+				i = 0
+			}
+			metrics[i] = m.(*graphiteout.Metric)
+			i++
+		}
+		g.spoolMetrics(metrics[0:i])
 	}()
 }
 
-// Stop goroutune for queue read and send metrics
-func (g *GraphiteQueue) Stop() {
-	g.running = false
+// Stop send queued metrics and stop goroutune, return false if queue not flushed before timeout
+// (not sended metrics are spooled or dropped)
+func (g *GraphiteQueue) Stop(timeout time.Duration) bool {
+	if g.done == nil {
+		return true
+	}
+	atomic.CompareAndSwapInt32(&g.state, senderRunning, senderStopping)
+	select {
+	case <-g.done:
+		return true
+	case <-time.After(timeout):
+	}
+	atomic.StoreInt32(&g.state, senderStopped)
+	// sender can be blocked on network, so don't wait too long
+	// (spool is closed after stop, so late metrics are dropped)
+	select {
+	case <-g.done:
+	case <-time.After(retryInterval):
+	}
+	return false
 }
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	config "github.com/msaf1980/relaymon/config/relaymon"
+	"github.com/msaf1980/relaymon/pkg/checker"
+	"github.com/msaf1980/relaymon/pkg/promtext"
+	"github.com/msaf1980/relaymon/pkg/spool"
+)
+
+// listenGraphite receive plaintext metrics on tcp address
+func listenGraphite(t *testing.T, address string) (chan string, func()) {
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan string, 100)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					received <- scanner.Text()
+				}
+			}(conn)
+		}
+	}()
+	return received, func() { ln.Close() }
+}
+
+// freeAddress reserve tcp address (without listener)
+func freeAddress(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+	return address
+}
+
+// receive wait for n unique metrics
+func receive(t *testing.T, received chan string, n int) map[string]bool {
+	got := make(map[string]bool)
+	timeout := time.After(10 * time.Second)
+	for len(got) < n {
+		select {
+		case line := <-received:
+			got[line] = true
+		case <-timeout:
+			t.Fatalf("metrics not received, got %v", got)
+		}
+	}
+	return got
+}
+
+func TestGraphiteQueue_Destinations(t *testing.T) {
+	tests := []struct {
+		name string
+		mode string
+		// destinations availability
+		up []bool
+		// metrics received by destinations
+		want []bool
+	}{
+		{name: "all", mode: "all", up: []bool{true, false, true}, want: []bool{true, false, true}},
+		{name: "failover", mode: "failover", up: []bool{true, true}, want: []bool{true, false}},
+		{name: "failover to second", mode: "failover", up: []bool{false, true, true}, want: []bool{false, true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			destinations := make([]config.GraphiteDestination, len(tt.up))
+			received := make([]chan string, len(tt.up))
+			for i := range tt.up {
+				destinations[i] = config.GraphiteDestination{Address: freeAddress(t), Protocol: "tcp"}
+				if tt.up[i] {
+					var stop func()
+					received[i], stop = listenGraphite(t, destinations[i].Address)
+					defer stop()
+				}
+			}
+			g, err := GraphiteInit(&config.Config{GraphiteDestinations: destinations, GraphiteMode: tt.mode, Prefix: "test"}, 16, 4, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			g.Run()
+			defer g.Stop(time.Second)
+			g.Put("metric", "1", 1600000000)
+
+			for i := range tt.want {
+				if tt.want[i] {
+					if got := receive(t, received[i], 1); !got["test.metric 1 1600000000"] {
+						t.Errorf("destination %d got %v", i, got)
+					}
+				}
+			}
+			// wait for unexpected sends
+			time.Sleep(100 * time.Millisecond)
+			for i := range tt.want {
+				if !tt.want[i] && received[i] != nil && len(received[i]) > 0 {
+					t.Errorf("destination %d must not receive metrics", i)
+				}
+			}
+			if stats := g.Stats(); stats.Sent != 1 {
+				t.Errorf("GraphiteQueue.Stats() got %+v", stats)
+			}
+		})
+	}
+}
+
+func TestGraphiteQueue_Tagged(t *testing.T) {
+	plainAddress := freeAddress(t)
+	plain, stopPlain := listenGraphite(t, plainAddress)
+	defer stopPlain()
+	taggedAddress := freeAddress(t)
+	tagged, stopTagged := listenGraphite(t, taggedAddress)
+	defer stopTagged()
+
+	g, err := GraphiteInit(&config.Config{
+		GraphiteDestinations: []config.GraphiteDestination{
+			{Address: plainAddress, Protocol: "tcp"}, {Address: taggedAddress, Protocol: "tcp", Tagged: true},
+		},
+		GraphiteMode: "all", Prefix: "graphite.relaymon.relay1", Hostname: "relay1",
+	}, 16, 4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Run()
+	defer g.Stop(time.Second)
+
+	g.PutMetric(&checker.Metric{
+		Name: "network.tcp.backend.127_0_0_1_2003", Value: "1", Family: "tcp_target_state",
+		Labels: []checker.Label{{Name: "check", Value: "backend"}, {Name: "target", Value: "127.0.0.1:2003"}},
+	}, 1600000000)
+	g.Put("status", "1", 1600000000)
+
+	want := []string{
+		"graphite.relaymon.relay1.network.tcp.backend.127_0_0_1_2003 1 1600000000",
+		"graphite.relaymon.relay1.status 1 1600000000",
+	}
+	got := receive(t, plain, len(want))
+	for _, line := range want {
+		if !got[line] {
+			t.Errorf("metric '%s' not received, got %v", line, got)
+		}
+	}
+	want = []string{
+		"graphite.relaymon.tcp_target_state;check=backend;target=127.0.0.1:2003;host=relay1 1 1600000000",
+		"graphite.relaymon.status;host=relay1 1 1600000000",
+	}
+	got = receive(t, tagged, len(want))
+	for _, line := range want {
+		if !got[line] {
+			t.Errorf("tagged metric '%s' not received, got %v", line, got)
+		}
+	}
+}
+
+func TestGraphiteQueue_Stop(t *testing.T) {
+	address := freeAddress(t)
+	received, stop := listenGraphite(t, address)
+	defer stop()
+
+	g, err := GraphiteInit(&config.Config{
+		GraphiteDestinations: []config.GraphiteDestination{{Address: address, Protocol: "tcp"}}, GraphiteMode: "all", Prefix: "test",
+	}, 64, 4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Run()
+	n := 10
+	for i := 0; i < n; i++ {
+		g.Put("metric"+strconv.Itoa(i), "1", 1600000000)
+	}
+	// queued metrics are flushed
+	if !g.Stop(5 * time.Second) {
+		t.Errorf("GraphiteQueue.Stop() queue not flushed")
+	}
+	receive(t, received, n)
+	if stats := g.Stats(); stats.Sent != uint64(n) || stats.Dropped != 0 {
+		t.Errorf("GraphiteQueue.Stats() after stop got %+v", stats)
+	}
+
+	// destination unavailable, not sended metrics are dropped after timeout
+	g, _ = GraphiteInit(&config.Config{
+		GraphiteDestinations: []config.GraphiteDestination{{Address: freeAddress(t), Protocol: "tcp"}}, GraphiteMode: "all", Prefix: "test",
+	}, 64, 4, nil)
+	g.Run()
+	for i := 0; i < n; i++ {
+		g.Put("metric"+strconv.Itoa(i), "1", 1600000000)
+	}
+	if g.Stop(100 * time.Millisecond) {
+		t.Errorf("GraphiteQueue.Stop() must not flush queue to unavailable destination")
+	}
+	if stats := g.Stats(); stats.Sent != 0 || stats.Dropped != uint64(n) {
+		t.Errorf("GraphiteQueue.Stats() after stop got %+v", stats)
+	}
+}
+
+func TestGraphiteQueue_Spool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "relaymon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sp, err := spool.Open(dir, 256, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sp.Close()
+
+	// graphite is unavailable
+	address := freeAddress(t)
+	g, err := GraphiteInit(&config.Config{
+		GraphiteDestinations: []config.GraphiteDestination{{Address: address, Protocol: "tcp"}}, GraphiteMode: "all", Prefix: "test",
+	}, 16, 4, sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Run()
+	defer g.Stop(time.Second)
+
+	n := 10
+	for i := 0; i < n; i++ {
+		g.Put("metric"+strconv.Itoa(i), "1", 1600000000)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for g.Stats().Spooled < uint64(n) && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if stats := g.Stats(); stats.Spooled != uint64(n) || stats.Sent != 0 || stats.Dropped != 0 {
+		t.Fatalf("GraphiteQueue.Stats() with unavailable graphite got %+v", stats)
+	}
+
+	received, stop := listenGraphite(t, address)
+	defer stop()
+
+	// trigger reconnect
+	g.Put("metric"+strconv.Itoa(n), "1", 1600000000)
+	got := receive(t, received, n+1)
+	for i := 0; i <= n; i++ {
+		line := "test.metric" + strconv.Itoa(i) + " 1 1600000000"
+		if !got[line] {
+			t.Errorf("metric '%s' not received", line)
+		}
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for sp.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if stats := g.Stats(); stats.Sent != uint64(n+1) || stats.SpoolSize != 0 {
+		t.Errorf("GraphiteQueue.Stats() after drain got %+v", stats)
+	}
+}
+
+func TestGraphiteQueue_SpoolBroken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "relaymon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sp, err := spool.Open(dir, 256, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sp.Close()
+	if _, err = sp.Write([]string{"test.metric1 1 1600000000", "test.metric2 1 1600000000"}); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := GraphiteInit(&config.Config{
+		GraphiteDestinations: []config.GraphiteDestination{{Address: freeAddress(t), Protocol: "tcp"}}, GraphiteMode: "all", Prefix: "test",
+	}, 16, 4, sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// segment is unreadable
+	files, _ := ioutil.ReadDir(dir)
+	for _, f := range files {
+		_ = os.Remove(filepath.Join(dir, f.Name()))
+	}
+	if !g.drainSpool() {
+		t.Fatal("GraphiteQueue.drainSpool() with broken segment = false")
+	}
+	if stats := g.Stats(); stats.Dropped != 2 || stats.SpoolSize != 0 {
+		t.Errorf("GraphiteQueue.Stats() after broken segment got %+v", stats)
+	}
+}
+
+func TestPutMetrics(t *testing.T) {
+	g, err := GraphiteInit(&config.Config{}, 16, 4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := promtext.NewRegistry("relaymon_")
+	labels := []checker.Label{{Name: "service", Value: "carbon-c-relay"}}
+	putMetrics(g, registry, []checker.Metric{
+		{Name: "systemd.carbon-c-relay", Value: "1", Family: "service_state", Labels: labels},
+		{
+			Name: "systemd_restarts.carbon-c-relay", Value: "2", Family: "service_restarts_total", Labels: labels,
+			Help: "service restarts detected", Counter: true,
+		},
+	}, 1600000000)
+
+	var buf bytes.Buffer
+	registry.Write(&buf)
+	out := buf.String()
+	for _, want := range []string{
+		"# HELP relaymon_service_state checker " + stateHelp + "\n# TYPE relaymon_service_state gauge\n",
+		"# HELP relaymon_service_restarts_total service restarts detected\n# TYPE relaymon_service_restarts_total counter\n",
+		"relaymon_service_restarts_total{service=\"carbon-c-relay\"} 2\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("registry output not contain %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	config "github.com/msaf1980/relaymon/config/relaymon"
+	"github.com/msaf1980/relaymon/pkg/action"
+	"github.com/msaf1980/relaymon/pkg/checker"
+	"github.com/msaf1980/relaymon/pkg/notify"
+	"github.com/msaf1980/relaymon/pkg/promtext"
+)
+
+// newNotifier create webhooks notifier (nil, if webhooks not configured)
+func newNotifier(cfg *config.Config, registry *promtext.Registry) (*notify.Notifier, error) {
+	if len(cfg.Webhooks) == 0 {
+		return nil, nil
+	}
+	hooks := make([]notify.Webhook, len(cfg.Webhooks))
+	for i, w := range cfg.Webhooks {
+		hooks[i] = notify.Webhook{
+			URL: w.URL, Format: w.Format, Template: w.Template, ChatID: w.ChatID, Headers: w.Headers,
+			Timeout: w.Timeout, Retries: w.Retries, Backoff: w.Backoff,
+		}
+	}
+	n, err := notify.NewNotifier(hooks)
+	if err != nil {
+		return nil, err
+	}
+	n.OnSend(func(url string, err error) {
+		result := "success"
+		if err != nil {
+			result = "failed"
+		}
+		registry.AddCounter("notifications_total", "sent webhook notifications", 1,
+			checker.Label{Name: "result", Value: result},
+		)
+	})
+	return n, nil
+}
+
+// notifyPayload build transition notification
+func notifyPayload(cfg *config.Config, timestamp int64, oldState, newState checker.State, checkers *Checkers,
+	results []action.Result) notify.Payload {
+
+	p := notify.Payload{
+		Hostname:  cfg.Hostname,
+		Timestamp: timestamp,
+		OldState:  oldState.String(),
+		NewState:  newState.String(),
+		Failing:   []notify.Checker{},
+		Actions:   make([]notify.Action, 0, len(results)),
+		IPs:       notify.IPs{Iface: cfg.Iface},
+	}
+	for _, checkers := range [][]CheckStatus{checkers.Services, checkers.Network} {
+		for i := range checkers {
+			c := &checkers[i]
+			if c.Status == checker.ErrorState || c.Status == checker.WarnState {
+				p.Failing = append(p.Failing, notify.Checker{Name: c.Checker.Name(), State: c.Status.String(), Events: c.Events})
+			}
+		}
+	}
+	for i := range results {
+		r := &results[i]
+		a := notify.Action{Type: r.Action.Type(), Result: "success", Attempts: r.Attempts}
+		if r.Err != nil {
+			a.Result = "failed"
+			a.Error = r.Err.Error()
+		} else if ip, ok := r.Action.(*action.IP); ok {
+			addrs := make([]string, len(ip.Addrs))
+			for j := range ip.Addrs {
+				addrs[j] = ip.Addrs[j].String()
+			}
+			if ip.Add {
+				p.IPs.Added = append(p.IPs.Added, addrs...)
+			} else {
+				p.IPs.Removed = append(p.IPs.Removed, addrs...)
+			}
+		}
+		p.Actions = append(p.Actions, a)
+	}
+	return p
+}
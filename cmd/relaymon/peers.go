@@ -0,0 +1,75 @@
+package main
+
+import (
+	"time"
+
+	config "github.com/msaf1980/relaymon/config/relaymon"
+	"github.com/msaf1980/relaymon/pkg/checker"
+	"github.com/msaf1980/relaymon/pkg/hysteresis"
+	"github.com/msaf1980/relaymon/pkg/peers"
+)
+
+// heldPeers is reason of withdraw, held by peers policy
+const heldPeers hysteresis.Reason = "peers"
+
+// newGossip create peers gossip (nil, if not configured)
+func newGossip(cfg *config.Config) (*peers.Gossip, error) {
+	if len(cfg.Peers.Listen) == 0 {
+		return nil, nil
+	}
+	g, err := peers.New(peers.Config{
+		Node:     cfg.Hostname,
+		Listen:   cfg.Peers.Listen,
+		Peers:    cfg.Peers.Addresses,
+		Interval: cfg.Peers.Interval,
+		Timeout:  cfg.Peers.Timeout,
+		Secret:   cfg.Peers.Secret,
+	})
+	if err != nil {
+		return nil, err
+	}
+	g.Start()
+	return g, nil
+}
+
+// newElector create ip addresses owner elector (nil, if election not enabled)
+func newElector(cfg *config.Config, gossip *peers.Gossip) *peers.Elector {
+	if gossip == nil || !cfg.Peers.Election.Enabled {
+		return nil
+	}
+	return peers.NewElector(gossip, electionConfig(cfg))
+}
+
+func electionConfig(cfg *config.Config) peers.ElectionConfig {
+	return peers.ElectionConfig{
+		Priority:     cfg.Peers.Election.Priority,
+		Preempt:      cfg.Peers.Election.Preempt,
+		PreemptDelay: cfg.Peers.Election.PreemptDelay,
+		Quorum:       cfg.Peers.Election.Quorum,
+	}
+}
+
+// peersState return local state for peers
+func peersState(status, stepStatus checker.State, checkers *Checkers) peers.State {
+	s := peers.State{
+		State: stepStatus.String(),
+		Up:    status == checker.SuccessState || status == checker.WarnState,
+	}
+	for _, checkers := range [][]CheckStatus{checkers.Services, checkers.Network} {
+		for i := range checkers {
+			if checkers[i].Status != checker.SuccessState && checkers[i].Status != checker.CollectingState {
+				s.Failing = append(s.Failing, checkers[i].Checker.Name())
+			}
+		}
+	}
+	return s
+}
+
+// allowWithdraw check withdraw with peers policy (with peers states, received after local failure since time),
+// return reason, if not allowed
+func allowWithdraw(cfg *config.Config, gossip *peers.Gossip, since time.Time) (bool, string) {
+	if gossip == nil {
+		return true, ""
+	}
+	return peers.AllowWithdraw(peers.Policy(cfg.Peers.Policy), cfg.Peers.MinHealthy, gossip.Peers(), since)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	config "github.com/msaf1980/relaymon/config/relaymon"
+	"github.com/msaf1980/relaymon/pkg/announcer"
+	"github.com/msaf1980/relaymon/pkg/checker"
+	"github.com/msaf1980/relaymon/pkg/filewatch"
+	"github.com/msaf1980/relaymon/pkg/netconf"
+	"github.com/msaf1980/relaymon/pkg/notify"
+	"github.com/msaf1980/relaymon/pkg/promtext"
+	"github.com/msaf1980/relaymon/pkg/systemd"
+
+	"github.com/rs/zerolog"
+)
+
+// reloadState is new config state, built without side effects (applied only after all parts are built)
+type reloadState struct {
+	cfg      *config.Config
+	addrs    []*net.IPNet
+	checkers *Checkers
+	actions  *Actions
+	notifier *notify.Notifier
+	level    zerolog.Level
+	netconf  netconfOptions
+}
+
+// apply set new log level and ip addresses backend and options
+func (r *reloadState) apply() {
+	r.netconf.apply()
+	zerolog.SetGlobalLevel(r.level)
+}
+
+// reloadConfig load config and build new checkers, actions and notifier (state from previous checkers not merged),
+// nothing is applied on error
+func reloadConfig(configFile string, overrideLogLevel string, prev *config.Config, backend systemd.Backend,
+	ann announcer.Announcer, prefixes []*net.IPNet, registry *promtext.Registry) (*reloadState, error) {
+
+	cfg, err := config.LoadConfig(configFile, overrideLogLevel)
+	if err != nil {
+		return nil, fmt.Errorf("configuration load: %s", err.Error())
+	}
+	r := &reloadState{cfg: cfg}
+	if r.level, err = zerolog.ParseLevel(strings.ToLower(cfg.LogLevel)); err != nil {
+		return nil, fmt.Errorf("invalid log_level: %s", cfg.LogLevel)
+	}
+	if r.addrs, err = parseIPs(cfg.IPs); err != nil {
+		return nil, err
+	}
+	if r.netconf, err = parseNetconf(cfg); err != nil {
+		return nil, err
+	}
+	if r.checkers, err = newCheckers(cfg, backend); err != nil {
+		return nil, err
+	}
+	// announcer changes require restart, so prefixes are not changed
+	if r.actions, err = newActions(cfg, r.addrs, ann, prefixes); err != nil {
+		return nil, err
+	}
+	if r.notifier, err = newNotifier(cfg, registry); err != nil {
+		return nil, err
+	}
+
+	if !reflect.DeepEqual(cfg.GraphiteDestinations, prev.GraphiteDestinations) || cfg.GraphiteMode != prev.GraphiteMode ||
+		cfg.Prefix != prev.Prefix || cfg.GraphiteSpool != prev.GraphiteSpool || cfg.Hostname != prev.Hostname {
+		log.Warn().Str("action", actionReload).Msg("graphite_destinations, graphite_mode, graphite_spool, prefix or hostname changed, restart required for apply")
+	}
+	if cfg.SystemdBackend != prev.SystemdBackend {
+		log.Warn().Str("action", actionReload).Msg("systemd_backend changed, restart required for apply")
+	}
+	if cfg.Peers.Listen != prev.Peers.Listen || !reflect.DeepEqual(cfg.Peers.Addresses, prev.Peers.Addresses) ||
+		cfg.Peers.Interval != prev.Peers.Interval || cfg.Peers.Timeout != prev.Peers.Timeout || cfg.Peers.Secret != prev.Peers.Secret {
+		log.Warn().Str("action", actionReload).Msg("peers changed, restart required for apply (policy is applied)")
+	}
+	if !reflect.DeepEqual(cfg.Announcer, prev.Announcer) {
+		log.Warn().Str("action", actionReload).Msg("announcer changed, restart required for apply")
+	}
+	if cfg.Listen != prev.Listen {
+		log.Warn().Str("action", actionReload).Msg("listen changed, restart required for apply")
+	}
+
+	return r, nil
+}
+
+// ipsChanges return ip addresses for remove and add on reload (only in success or warn state, when ip addresses are up)
+func ipsChanges(status checker.State, oldCfg *config.Config, oldAddrs []*net.IPNet, newCfg *config.Config,
+	newAddrs []*net.IPNet) (removed, added []*net.IPNet) {
+
+	if status != checker.SuccessState && status != checker.WarnState {
+		return nil, nil
+	}
+	if oldCfg.Iface == newCfg.Iface {
+		return ipsDiff(oldAddrs, newAddrs), ipsDiff(newAddrs, oldAddrs)
+	}
+	return oldAddrs, newAddrs
+}
+
+// reconfigureIPs configure changed ip addresses (only in success or warn state, when ip addresses are up)
+func reconfigureIPs(status checker.State, oldCfg *config.Config, oldAddrs []*net.IPNet, newCfg *config.Config, newAddrs []*net.IPNet,
+	registry *promtext.Registry) {
+
+	oldIface := oldCfg.Iface
+	newIface := newCfg.Iface
+	removed, added := ipsChanges(status, oldCfg, oldAddrs, newCfg, newAddrs)
+
+	if len(removed) > 0 {
+		errs := netconf.IfaceAddrDel(oldIface, removed)
+		countIPErrors(registry, "del", errs)
+		for i := range errs {
+			log.Error().Str("action", actionReload).Str("type", "network").Msg(errs[i].Error())
+		}
+		if len(errs) == 0 {
+			log.Info().Str("action", actionReload).Str("type", "network").Msg("removed IP addresses deconfigured")
+		}
+	}
+	if len(added) > 0 {
+		errs := netconf.IfaceAddrAdd(newIface, added)
+		countIPErrors(registry, "add", errs)
+		for i := range errs {
+			log.Error().Str("action", actionReload).Str("type", "network").Msg(errs[i].Error())
+		}
+		if len(errs) == 0 {
+			log.Info().Str("action", actionReload).Str("type", "network").Msg("added IP addresses configured")
+			announceIPs(registry, newIface, added, newCfg.GARPCount, newCfg.GARPInterval, actionReload)
+		}
+	}
+}
+
+// watchConfig request config reload on relaymon or carbon-c-relay config files change
+// (or new files, matched by carbon-c-relay include patterns)
+func watchConfig(configFile string, relayFiles []string, relayIncludes []string) *filewatch.Watcher {
+	files := append([]string{configFile}, relayFiles...)
+	watcher, err := filewatch.NewWatcher(files, relayIncludes, func(file string) {
+		log.Info().Str("action", actionReload).Str("file", file).Msg("config changed")
+		atomic.StoreInt32(&reload, 1)
+	})
+	if err != nil {
+		log.Error().Str("action", actionReload).Msg(err.Error())
+		return nil
+	}
+	return watcher
+}
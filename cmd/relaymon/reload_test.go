@@ -0,0 +1,125 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"reflect"
+	"testing"
+
+	config "github.com/msaf1980/relaymon/config/relaymon"
+	"github.com/msaf1980/relaymon/pkg/checker"
+	"github.com/msaf1980/relaymon/pkg/netconf"
+	"github.com/msaf1980/relaymon/pkg/promtext"
+
+	"github.com/rs/zerolog"
+)
+
+func mustParseCIDRs(t *testing.T, ips ...string) []*net.IPNet {
+	addrs, err := parseIPs(ips)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addrs
+}
+
+func TestIPsChanges(t *testing.T) {
+	oldAddrs := mustParseCIDRs(t, "192.168.155.10/32", "192.168.155.11/32")
+	newAddrs := mustParseCIDRs(t, "192.168.155.11/32", "192.168.155.12/32")
+
+	tests := []struct {
+		name        string
+		status      checker.State
+		newIface    string
+		wantRemoved []string
+		wantAdded   []string
+	}{
+		{name: "success", status: checker.SuccessState, newIface: "lo", wantRemoved: []string{"192.168.155.10/32"}, wantAdded: []string{"192.168.155.12/32"}},
+		// ip addresses are configured in warn state
+		{name: "warn", status: checker.WarnState, newIface: "lo", wantRemoved: []string{"192.168.155.10/32"}, wantAdded: []string{"192.168.155.12/32"}},
+		{
+			name: "warn iface changed", status: checker.WarnState, newIface: "eth0",
+			wantRemoved: []string{"192.168.155.10/32", "192.168.155.11/32"}, wantAdded: []string{"192.168.155.11/32", "192.168.155.12/32"},
+		},
+		{name: "error", status: checker.ErrorState, newIface: "lo"},
+		{name: "collecting", status: checker.CollectingState, newIface: "lo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			removed, added := ipsChanges(tt.status, &config.Config{Iface: "lo"}, oldAddrs, &config.Config{Iface: tt.newIface}, newAddrs)
+			if got := ipsStrings(removed); !stringsEqual(got, tt.wantRemoved) {
+				t.Errorf("ipsChanges() removed = %v, want %v", got, tt.wantRemoved)
+			}
+			if got := ipsStrings(added); !stringsEqual(got, tt.wantAdded) {
+				t.Errorf("ipsChanges() added = %v, want %v", got, tt.wantAdded)
+			}
+		})
+	}
+}
+
+func ipsStrings(addrs []*net.IPNet) []string {
+	s := make([]string, len(addrs))
+	for i := range addrs {
+		s[i] = addrs[i].String()
+	}
+	return s
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestReloadConfigNotApplied(t *testing.T) {
+	f, err := ioutil.TempFile("", "relaymon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	// actions build failed (invalid exec env)
+	_, err = f.WriteString(`
+log_level: trace
+services: [ "carbon-c-relay" ]
+ips: [ "192.168.155.10/32" ]
+ip_backend: ip
+ip_label: "lo:reload"
+ip_scope: host
+actions:
+  success:
+    - type: ip
+  error:
+    - type: ip
+    - type: exec
+      args: [ "true" ]
+      env: [ "INVALID" ]
+`)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = configureNetconf(&config.Config{}); err != nil {
+		t.Fatal(err)
+	}
+	level := zerolog.GlobalLevel()
+	backend, opts := netconf.GetBackend(), netconf.GetAddrOptions()
+
+	if _, err = reloadConfig(f.Name(), "", &config.Config{}, nil, nil, nil, promtext.NewRegistry("relaymon_")); err == nil {
+		t.Fatal("reloadConfig() must fail")
+	} else if err.Error() != "exec env INVALID invalid" {
+		t.Fatalf("reloadConfig() error = %s", err.Error())
+	}
+	if netconf.GetBackend() != backend || !reflect.DeepEqual(netconf.GetAddrOptions(), opts) {
+		t.Errorf("netconf options changed on failed reload: %s, %+v", netconf.GetBackend(), netconf.GetAddrOptions())
+	}
+	if zerolog.GlobalLevel() != level {
+		t.Errorf("log level changed on failed reload: %s", zerolog.GlobalLevel())
+	}
+}
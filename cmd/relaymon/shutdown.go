@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+
+	config "github.com/msaf1980/relaymon/config/relaymon"
+	"github.com/msaf1980/relaymon/pkg/checker"
+	"github.com/msaf1980/relaymon/pkg/promtext"
+)
+
+const actionShutdown = "shutdown"
+
+// ip addresses state after shutdown (for report)
+const (
+	shutdownIPsKept          = "kept"
+	shutdownIPsWithdrawn     = "withdrawn"
+	shutdownIPsWithdrawError = "withdraw failed"
+	shutdownIPsNotOwned      = "not owned"
+)
+
+// shutdownIPs withdraw ip addresses and routes with ip and route error actions (if on_shutdown is withdraw and node is up),
+// return ip addresses state
+func shutdownIPs(ctx context.Context, cfg *config.Config, actions *Actions, registry *promtext.Registry,
+	status checker.State, ipOwned bool) string {
+
+	up := status == checker.SuccessState || status == checker.WarnState
+	if cfg.Peers.Election.Enabled {
+		up = up && ipOwned
+	}
+	if !up {
+		return shutdownIPsNotOwned
+	}
+	if cfg.OnShutdown != "withdraw" {
+		return shutdownIPsKept
+	}
+
+	failed := false
+	if cfg.Peers.Election.Enabled {
+		_, failed = runActions(ctx, actions.Release, actionShutdown, registry, cfg)
+	}
+	if _, errFailed := runActions(ctx, actions.Withdraw, actionShutdown, registry, cfg); errFailed {
+		failed = true
+	}
+	if failed {
+		return shutdownIPsWithdrawError
+	}
+	return shutdownIPsWithdrawn
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"net"
+	"os"
+	"time"
+
+	config "github.com/msaf1980/relaymon/config/relaymon"
+	"github.com/msaf1980/relaymon/pkg/checker"
+	"github.com/msaf1980/relaymon/pkg/hysteresis"
+	"github.com/msaf1980/relaymon/pkg/netconf"
+	"github.com/msaf1980/relaymon/pkg/statefile"
+)
+
+const actionState = "state"
+
+// ipsConfigured return true, if all ip addresses configured on interface
+func ipsConfigured(iface string, addrs []*net.IPNet) bool {
+	ifaceAddrs, err := netconf.IfaceAddrs(iface)
+	if err != nil {
+		return false
+	}
+	for i := range addrs {
+		if !netconf.FindIPNet(addrs[i], ifaceAddrs) {
+			return false
+		}
+	}
+	return true
+}
+
+// stateHistory return hysteresis history from persisted state
+func stateHistory(s *statefile.State) hysteresis.History {
+	h := hysteresis.History{Locked: s.FlapLocked, LockReason: s.FlapLockReason}
+	if s.LastTransition > 0 {
+		h.Last = time.Unix(s.LastTransition, 0)
+	}
+	for _, t := range s.Downs {
+		h.Downs = append(h.Downs, time.Unix(t, 0))
+	}
+	return h
+}
+
+// restoreState restore checkers and global state from state file, return global state, hysteresis history
+// and true, if ip addresses owned (configured by relaymon).
+// Success or warn state is restored only if ip addresses still configured.
+// Flap lock is restored from stale state too (it's cleared only manually).
+func restoreState(cfg *config.Config, checkers *Checkers, addrs []*net.IPNet, now time.Time) (checker.State, hysteresis.History, bool) {
+	if len(cfg.StateFile) == 0 {
+		return checker.CollectingState, hysteresis.History{}, false
+	}
+	s, err := statefile.Load(cfg.StateFile, cfg.StateMaxAge, now)
+	if err != nil {
+		var h hysteresis.History
+		if os.IsNotExist(err) {
+			log.Info().Str("action", actionState).Msg("state file not exist")
+		} else if err == statefile.ErrStale {
+			log.Warn().Str("action", actionState).Str("saved", time.Unix(s.Timestamp, 0).Format(time.RFC3339)).Msg("state file is stale, ignored")
+			if s.FlapLocked {
+				h = hysteresis.History{Locked: true, LockReason: s.FlapLockReason}
+				log.Warn().Str("action", actionState).Str("reason", s.FlapLockReason).Msg("flap lock restored")
+			}
+		} else {
+			log.Error().Str("action", actionState).Msg(err.Error())
+		}
+		return checker.CollectingState, h, false
+	}
+
+	restored := 0
+	for _, checkers := range [][]CheckStatus{checkers.Services, checkers.Network} {
+		for i := range checkers {
+			c, ok := s.Checker(checkers[i].Checker.Name())
+			if !ok {
+				continue
+			}
+			if r, ok := checkers[i].Checker.(checker.Restorer); ok {
+				r.Restore(c.State, c.Counters)
+				checkers[i].Status = c.State
+				restored++
+			}
+		}
+	}
+
+	status := s.Status
+	owned := len(s.IPs) > 0
+	if status == checker.SuccessState || status == checker.WarnState {
+		if ipManaged(cfg) {
+			configured := s.Iface == cfg.Iface && ipsConfigured(cfg.Iface, addrs)
+			if cfg.Peers.Election.Enabled {
+				// backup node not own ip addresses
+				owned = owned && configured
+			} else if !configured {
+				log.Warn().Str("action", actionState).Msg("ip addresses not configured, global state not restored")
+				status = checker.CollectingState
+				owned = false
+			}
+		}
+	} else {
+		status = checker.CollectingState
+		owned = false
+	}
+	log.Info().Str("action", actionState).Str("state", status.String()).Int("checkers", restored).Bool("ips_owned", owned).
+		Bool("flap_locked", s.FlapLocked).Msg("state restored")
+	return status, stateHistory(s), owned
+}
+
+// ownedIPs return true, if ip addresses configured on interface by relaymon
+func ownedIPs(cfg *config.Config, election bool, status checker.State, ipOwned bool) bool {
+	if election {
+		return ipOwned
+	}
+	return ipManaged(cfg) && (status == checker.SuccessState || status == checker.WarnState)
+}
+
+// saveState persist checkers and global state to state file
+func saveState(cfg *config.Config, status checker.State, history hysteresis.History, checkers *Checkers, addrs []*net.IPNet,
+	owned bool, now time.Time) {
+	if len(cfg.StateFile) == 0 {
+		return
+	}
+	s := statefile.State{
+		Timestamp: now.Unix(),
+		Status:    status,
+		Checkers:  make([]statefile.Checker, 0, checkers.Len()),
+		Iface:     cfg.Iface,
+		IPs:       []string{},
+	}
+	if !history.Last.IsZero() {
+		s.LastTransition = history.Last.Unix()
+	}
+	for _, t := range history.Downs {
+		s.Downs = append(s.Downs, t.Unix())
+	}
+	s.FlapLocked = history.Locked
+	s.FlapLockReason = history.LockReason
+	for _, checkers := range [][]CheckStatus{checkers.Services, checkers.Network} {
+		for i := range checkers {
+			s.Checkers = append(s.Checkers, statefile.Checker{
+				Name: checkers[i].Checker.Name(), State: checkers[i].Status, Counters: checkers[i].Checker.Counters(),
+			})
+		}
+	}
+	if owned {
+		for i := range addrs {
+			s.IPs = append(s.IPs, addrs[i].String())
+		}
+	}
+	if err := statefile.Save(cfg.StateFile, &s); err != nil {
+		log.Error().Str("action", actionState).Msg(err.Error())
+	}
+}
@@ -4,15 +4,335 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/msaf1980/relaymon/pkg/aggregator"
 	"github.com/msaf1980/relaymon/pkg/checker"
+	"github.com/msaf1980/relaymon/pkg/netconf"
+	"github.com/msaf1980/relaymon/pkg/peers"
 	"gopkg.in/yaml.v2"
 )
 
+// Action describe action on state transition
+type Action struct {
+	// Type of action: ip (add ips on success, remove on error), route (announce on success, withdraw on error),
+	// exec, systemd, http or touch
+	Type string `yaml:"type"`
+
+	// Args for exec (command with arguments, without shell)
+	Args []string `yaml:"args"`
+	// Env for exec (in KEY=VALUE format)
+	Env []string `yaml:"env"`
+
+	// Op for systemd (start, stop, restart or reload)
+	Op string `yaml:"op"`
+	// Unit for systemd
+	Unit string `yaml:"unit"`
+
+	// Method for http (GET by default, POST if body not empty)
+	Method  string            `yaml:"method"`
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	Body    string            `yaml:"body"`
+
+	// Path for touch (create file or update modification time)
+	Path string `yaml:"path"`
+	// Remove file instead of touch
+	Remove bool `yaml:"remove"`
+
+	// Timeout for exec, systemd and http
+	Timeout time.Duration `yaml:"timeout"`
+	// Retry count after failure
+	Retry         int           `yaml:"retry"`
+	RetryInterval time.Duration `yaml:"retry_interval"`
+	// AbortOnFailure skip next actions, if action failed (after retries)
+	AbortOnFailure bool `yaml:"abort_on_failure"`
+}
+
+// Actions describe ordered actions on transitions
+type Actions struct {
+	Success []Action `yaml:"success"`
+	Error   []Action `yaml:"error"`
+	// Warn on transition to warning state (node degraded, but not evicted)
+	Warn []Action `yaml:"warn"`
+	// Recover on transition from warning to success state
+	Recover []Action `yaml:"recover"`
+	// Shutdown on relaymon stop (ip addresses and routes are withdrawn with ip and route error actions,
+	// if on_shutdown is withdraw)
+	Shutdown []Action `yaml:"shutdown"`
+}
+
+// legacyActions translate ips, announcer, success_cmd and error_cmd to actions
+func legacyActions(cfg *Config) Actions {
+	var actions Actions
+	if len(cfg.Announcer.Type) > 0 {
+		// withdraw routes before ip addresses remove
+		actions.Error = append(actions.Error, Action{Type: "route"})
+	}
+	if len(cfg.IPs) > 0 {
+		actions.Error = append(actions.Error, Action{Type: "ip"})
+		actions.Success = append(actions.Success, Action{Type: "ip"})
+	}
+	if len(cfg.Announcer.Type) > 0 {
+		// announce routes after ip addresses configured
+		actions.Success = append(actions.Success, Action{Type: "route"})
+	}
+	if len(cfg.ErrorCmd) > 0 {
+		actions.Error = append(actions.Error, Action{Type: "exec", Args: []string{"sh", "-c", cfg.ErrorCmd}})
+	}
+	if len(cfg.SuccessCmd) > 0 {
+		actions.Success = append(actions.Success, Action{Type: "exec", Args: []string{"sh", "-c", cfg.SuccessCmd}})
+	}
+	if len(cfg.WarnCmd) > 0 {
+		actions.Warn = append(actions.Warn, Action{Type: "exec", Args: []string{"sh", "-c", cfg.WarnCmd}})
+	}
+	if len(cfg.RecoverCmd) > 0 {
+		actions.Recover = append(actions.Recover, Action{Type: "exec", Args: []string{"sh", "-c", cfg.RecoverCmd}})
+	}
+	if len(cfg.ShutdownCmd) > 0 {
+		actions.Shutdown = append(actions.Shutdown, Action{Type: "exec", Args: []string{"sh", "-c", cfg.ShutdownCmd}})
+	}
+	return actions
+}
+
+func validateActions(cfg *Config, transition string, actions []Action) error {
+	for i := range actions {
+		a := &actions[i]
+		switch a.Type {
+		case "ip", "route":
+			if transition == "warn" || transition == "recover" || transition == "shutdown" {
+				return fmt.Errorf("configuration: actions %s %s not supported", transition, a.Type)
+			}
+		}
+		switch a.Type {
+		case "ip":
+			if len(cfg.IPs) == 0 {
+				return fmt.Errorf("configuration: actions %s ip without ips", transition)
+			}
+		case "route":
+			if len(cfg.Announcer.Type) == 0 {
+				return fmt.Errorf("configuration: actions %s route without announcer", transition)
+			}
+		case "exec":
+			if len(a.Args) == 0 {
+				return fmt.Errorf("configuration: actions %s exec args empthy", transition)
+			}
+		case "systemd":
+			if len(a.Unit) == 0 {
+				return fmt.Errorf("configuration: actions %s systemd unit empthy", transition)
+			}
+		case "http":
+			if len(a.URL) == 0 {
+				return fmt.Errorf("configuration: actions %s http url empthy", transition)
+			}
+		case "touch":
+			if len(a.Path) == 0 {
+				return fmt.Errorf("configuration: actions %s touch path empthy", transition)
+			}
+		default:
+			return fmt.Errorf("configuration: actions %s type '%s' unknown", transition, a.Type)
+		}
+		if a.Retry < 0 {
+			return fmt.Errorf("configuration: actions %s %s retry negative", transition, a.Type)
+		}
+	}
+	return nil
+}
+
+// Webhook describe notification webhook (POST on state transitions)
+type Webhook struct {
+	URL string `yaml:"url"`
+	// Format of body: generic (JSON payload), slack or telegram (ignored, if template is set)
+	Format string `yaml:"format"`
+	// Template for body (Go text/template with payload, .Text for short description and json function)
+	Template string `yaml:"template"`
+	// ChatID for telegram
+	ChatID  string            `yaml:"chat_id"`
+	Headers map[string]string `yaml:"headers"`
+	Timeout time.Duration     `yaml:"timeout"`
+	// Retries count after failure (with exponential backoff)
+	Retries int           `yaml:"retries"`
+	Backoff time.Duration `yaml:"backoff"`
+}
+
+// Announcer describe routes announcer for anycast (exabgp or bgp)
+type Announcer struct {
+	// Type exabgp (write commands to ExaBGP API pipe) or bgp (embedded BGP speaker), disabled if empty
+	Type string `yaml:"type"`
+	// Prefixes for announce (ips, if empty)
+	Prefixes []string `yaml:"prefixes"`
+	// NextHop for announced routes (self, if empty)
+	NextHop string `yaml:"next_hop"`
+
+	// Pipe for ExaBGP API
+	Pipe string `yaml:"pipe"`
+
+	// Peer address (host:port) for BGP speaker
+	Peer     string        `yaml:"peer"`
+	LocalAS  uint32        `yaml:"local_as"`
+	PeerAS   uint32        `yaml:"peer_as"`
+	RouterID string        `yaml:"router_id"`
+	HoldTime time.Duration `yaml:"hold_time"`
+}
+
+// AggregationGroup describe named quorum of checkers
+type AggregationGroup struct {
+	Name     string   `yaml:"name"`
+	Checkers []string `yaml:"checkers"`
+	// MinSuccess checkers count (all, if 0)
+	MinSuccess int `yaml:"min_success"`
+	// Required group failure evict node, failure of not required group is only warning
+	Required bool `yaml:"required"`
+}
+
+// UnmarshalYAML unmarshal group (required by default)
+func (g *AggregationGroup) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type group AggregationGroup
+	v := group{Required: true}
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	*g = AggregationGroup(v)
+	return nil
+}
+
+// Aggregation describe global state policy (all required checkers must succeed, if not set)
+type Aggregation struct {
+	// Weights of checkers (by default 1 for required checkers, 0 for advisory)
+	Weights map[string]float64 `yaml:"weights"`
+	// MinWeight of succeeded checkers (disabled if 0)
+	MinWeight float64            `yaml:"min_weight"`
+	Groups    []AggregationGroup `yaml:"groups"`
+	// Expression over checkers and groups names, like 'relay && atleast(1, storage1, storage2)'
+	Expression string `yaml:"expression"`
+}
+
+// Hysteresis describe up/down transitions limits (disabled with zero values)
+type Hysteresis struct {
+	// MinHold is minimum time in state before up/down transition
+	MinHold time.Duration `yaml:"min_hold"`
+	// Window for count down transitions
+	Window time.Duration `yaml:"window"`
+	// Backoff is base delay before up transition after repeated down transitions within window (doubled for every next)
+	Backoff    time.Duration `yaml:"backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+	// FlapThreshold is down transitions count within window for flap lock (node held down until manual unlock)
+	FlapThreshold int `yaml:"flap_threshold"`
+}
+
+// Peers describe peer-aware failover (relaymon instances exchange states over UDP)
+type Peers struct {
+	// Listen address for peers messages (disabled if empty)
+	Listen string `yaml:"listen"`
+	// Addresses of peers (host:port)
+	Addresses []string `yaml:"addresses"`
+	// Interval between state messages
+	Interval time.Duration `yaml:"interval"`
+	// Timeout after last message for peer dead
+	Timeout time.Duration `yaml:"timeout"`
+	// Secret for messages authentication (must be same on all peers)
+	Secret string `yaml:"secret"`
+	// Policy for withdraw: none, min_healthy (at least min_healthy peers must remain healthy and in service)
+	// or local_only (withdraw only if some alive peer is healthy)
+	Policy     string `yaml:"policy"`
+	MinHealthy int    `yaml:"min_healthy"`
+
+	// Election of ip addresses owner (active/passive mode)
+	Election Election `yaml:"election"`
+}
+
+// GraphiteDestination describe self-metrics destination
+type GraphiteDestination struct {
+	// Address in host[:port] format (port 2003, if not set)
+	Address string `yaml:"address"`
+	// Protocol: tcp (plaintext over tcp), udp (plaintext over udp) or pickle (pickle over tcp)
+	Protocol string `yaml:"protocol"`
+	// Tagged send tagged series (name;tag=value, with hostname as host tag) instead of dotted names
+	Tagged bool `yaml:"tagged"`
+}
+
+// GraphiteSpool describe disk spool for self-metrics (metrics are spooled while graphite_relay unavailable)
+type GraphiteSpool struct {
+	// Path is spool directory (disabled if empty)
+	Path string `yaml:"path"`
+	// SegmentSize is maximum segment file size in bytes
+	SegmentSize int64 `yaml:"segment_size"`
+	// MaxSize is maximum spool size in bytes (oldest segments are removed, if exceeded)
+	MaxSize int64 `yaml:"max_size"`
+}
+
+// Election describe active/passive ip addresses ownership (only elected owner configure ip addresses)
+type Election struct {
+	Enabled bool `yaml:"enabled"`
+	// Priority of node (node with highest priority is preferred owner)
+	Priority int `yaml:"priority"`
+	// Preempt owner with lower priority (after preempt_delay)
+	Preempt      bool          `yaml:"preempt"`
+	PreemptDelay time.Duration `yaml:"preempt_delay"`
+	// Quorum is minimal alive nodes count (with local node) for ownership (majority of nodes, if 0)
+	Quorum int `yaml:"quorum"`
+}
+
+// CarbonCRelay describe carbon-c-relay config check
 type CarbonCRelay struct {
 	Config   string   `yaml:"config"`
 	Required []string `yaml:"required"`
+	// detect ICMP port unreachable for udp endpoints
+	UDPUnreachable bool `yaml:"udp_unreachable"`
+	// skip check for clusters, not referenced by match/aggregate/statistics (except required)
+	SkipUnreferenced bool `yaml:"skip_unreferenced"`
+}
+
+// TCPTarget describe tcp check endpoint
+type TCPTarget struct {
+	Address  string        `yaml:"address"`
+	Timeout  time.Duration `yaml:"timeout"`
+	Required bool          `yaml:"required"`
+}
+
+// TCPCheck describe tcp ports check (N of M targets must succeed)
+type TCPCheck struct {
+	Name       string      `yaml:"name"`
+	MinSuccess int         `yaml:"min_success"`
+	Targets    []TCPTarget `yaml:"targets"`
+}
+
+// Service describe systemd service check (can be set as service name)
+type Service struct {
+	Name string `yaml:"name"`
+
+	// check thresholds (if 0, global used)
+	FailCount  int `yaml:"fail_count"`
+	CheckCount int `yaml:"check_count"`
+	ResetCount int `yaml:"reset_count"`
+
+	// MaxRestarts per RestartsWindow (if 0, global used, if < 0, disabled)
+	MaxRestarts    int           `yaml:"max_restarts"`
+	RestartsWindow time.Duration `yaml:"restarts_window"`
+
+	// Required service error evict node, advisory service error is only warning
+	Required bool `yaml:"required"`
+
+	// Process is expected main process name
+	Process string `yaml:"process"`
+}
+
+// UnmarshalYAML unmarshal service from name or object
+func (s *Service) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		*s = Service{Name: name, Required: true}
+		return nil
+	}
+
+	type service Service
+	v := service{Required: true}
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	*s = Service(v)
+	return nil
 }
 
 // Config structure
@@ -28,37 +348,118 @@ type Config struct {
 
 	ErrorCmd   string `yaml:"error_cmd"`
 	SuccessCmd string `yaml:"success_cmd"`
+	// WarnCmd on transition to warning state
+	WarnCmd string `yaml:"warn_cmd"`
+	// RecoverCmd on transition from warning to success state
+	RecoverCmd string `yaml:"recover_cmd"`
+	// ShutdownCmd on relaymon stop
+	ShutdownCmd string `yaml:"shutdown_cmd"`
 
 	Iface string   `yaml:"iface"`
 	IPs   []string `yaml:"ips"`
+	// IPBackend for configure ip addresses (netlink or ip)
+	IPBackend string `yaml:"ip_backend"`
+	// IPLabel for added IPv4 addresses (must be started with iface name, like lo:relaymon)
+	IPLabel string `yaml:"ip_label"`
+	// IPScope for added ip addresses (global, site, link, host or number)
+	IPScope string `yaml:"ip_scope"`
+	// IPFlags for added ip addresses (nodad, optimistic, home, mngtmpaddr, noprefixroute)
+	IPFlags []string `yaml:"ip_flags"`
+	// GARPCount gratuitous ARP/unsolicited NA packets count, sent after ip addresses add (disabled if 0)
+	GARPCount int `yaml:"garp_count"`
+	// GARPInterval interval between gratuitous ARP/unsolicited NA packets
+	GARPInterval time.Duration `yaml:"garp_interval"`
+
+	Announcer Announcer `yaml:"announcer"`
+
+	// Actions on success/error/warn/recover transitions
+	// (translated from ips, announcer, success_cmd, error_cmd, warn_cmd and recover_cmd, if success and error not set)
+	Actions Actions `yaml:"actions"`
+
+	Webhooks []Webhook `yaml:"webhooks"`
 
 	CarbonCRelay CarbonCRelay `yaml:"carbon_c_relay"`
 
-	Services []string `yaml:"services"`
+	TCPChecks []TCPCheck `yaml:"tcp_checks"`
+
+	Services []Service `yaml:"services"`
+
+	// Aggregation policy for global state
+	Aggregation Aggregation `yaml:"aggregation"`
+
+	// Hysteresis for up/down transitions
+	Hysteresis Hysteresis `yaml:"hysteresis"`
+
+	// Peers for peer-aware failover
+	Peers Peers `yaml:"peers"`
+	// MaxRestarts per RestartsWindow for services (service go to error state, if exceeded), disabled if 0
+	MaxRestarts    int           `yaml:"max_restarts"`
+	RestartsWindow time.Duration `yaml:"restarts_window"`
+	// SystemdBackend for get services state (systemctl, dbus or auto)
+	SystemdBackend string `yaml:"systemd_backend"`
 
 	Service string `yaml:"service"`
 
-	Relay    string `yaml:"graphite_relay"`
-	Prefix   string `yaml:"prefix"`
-	Hostname string `yaml:"hostname"`
+	// Relay is single destination (plaintext over tcp), used if graphite_destinations not set
+	Relay string `yaml:"graphite_relay"`
+	// GraphiteDestinations for self-metrics
+	GraphiteDestinations []GraphiteDestination `yaml:"graphite_destinations"`
+	// GraphiteMode: all (send to all available destinations) or failover (send to first available destination)
+	GraphiteMode string `yaml:"graphite_mode"`
+	Prefix       string `yaml:"prefix"`
+	Hostname     string `yaml:"hostname"`
+	// GraphiteSpool for self-metrics, not sended to graphite_relay
+	GraphiteSpool GraphiteSpool `yaml:"graphite_spool"`
+
+	Listen string `yaml:"listen"`
+
+	// StateFile for persist state across restarts (disabled if empty)
+	StateFile string `yaml:"state_file"`
+	// StateMaxAge is staleness limit for restored state
+	StateMaxAge time.Duration `yaml:"state_max_age"`
+
+	// OnShutdown: keep (leave ip addresses and routes) or withdraw (run error actions) on relaymon stop
+	OnShutdown string `yaml:"on_shutdown"`
+	// ShutdownTimeout is deadline for shutdown (withdraw, shutdown actions and queued metrics send)
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+
+	// WatchConfig reload config on relaymon and carbon-c-relay config files change (also reloaded on SIGHUP)
+	WatchConfig bool `yaml:"watch_config"`
 }
 
 func defaultConfig() *Config {
 	cfg := &Config{
-		LogLevel:      "INFO",
-		CheckInterval: 10 * time.Second,
-		CheckCount:    6,
-		FailCount:     3,
-		ResetCount:    4,
-		NetTimeout:    1 * time.Second,
-		Iface:         "lo",
-		IPs:           []string{},
-		Services:      []string{},
-		CarbonCRelay:  CarbonCRelay{Required: []string{}},
-		Relay:         "127.0.0.1",
-		Prefix:        "graphite.relaymon",
-		Hostname:      "",
-		Service:       "relaymon",
+		LogLevel:       "INFO",
+		CheckInterval:  10 * time.Second,
+		CheckCount:     6,
+		FailCount:      3,
+		ResetCount:     4,
+		NetTimeout:     1 * time.Second,
+		Iface:          "lo",
+		IPs:            []string{},
+		IPBackend:      "netlink",
+		IPFlags:        []string{},
+		GARPCount:      3,
+		GARPInterval:   500 * time.Millisecond,
+		Services:       []Service{},
+		SystemdBackend: "auto",
+		RestartsWindow: 10 * time.Minute,
+		CarbonCRelay:   CarbonCRelay{Required: []string{}},
+		TCPChecks:      []TCPCheck{},
+		Hysteresis:     Hysteresis{Window: time.Hour, MaxBackoff: 30 * time.Minute},
+		Peers: Peers{
+			Addresses: []string{}, Interval: time.Second, Timeout: 3 * time.Second, Policy: "none",
+			Election: Election{Priority: 100, Preempt: true},
+		},
+		Relay:           "127.0.0.1",
+		GraphiteMode:    "all",
+		Prefix:          "graphite.relaymon",
+		GraphiteSpool:   GraphiteSpool{SegmentSize: 1 << 20, MaxSize: 64 << 20},
+		Hostname:        "",
+		Service:         "relaymon",
+		StateMaxAge:     5 * time.Minute,
+		OnShutdown:      "keep",
+		ShutdownTimeout: 30 * time.Second,
 	}
 
 	return cfg
@@ -84,15 +485,213 @@ func LoadConfig(configFile string, overrideLogLevel string) (*Config, error) {
 	if len(cfg.Iface) == 0 {
 		return nil, fmt.Errorf("configuration: iface empthy")
 	}
+	if cfg.IPBackend != netconf.BackendNetlink && cfg.IPBackend != netconf.BackendIP {
+		return nil, fmt.Errorf("configuration: ip_backend %s unknown", cfg.IPBackend)
+	}
+	if len(cfg.IPLabel) > 0 && !strings.HasPrefix(cfg.IPLabel, cfg.Iface) {
+		return nil, fmt.Errorf("configuration: ip_label must be started with iface name")
+	}
+	if _, err = netconf.ParseScope(cfg.IPScope); err != nil {
+		return nil, fmt.Errorf("configuration: %s", err.Error())
+	}
+	if _, err = netconf.ParseFlags(cfg.IPFlags); err != nil {
+		return nil, fmt.Errorf("configuration: %s", err.Error())
+	}
+	if cfg.GARPCount < 0 {
+		return nil, fmt.Errorf("configuration: garp_count negative")
+	}
 	if len(cfg.Services) == 0 {
 		return nil, fmt.Errorf("configuration: services empthy")
 	}
-	if len(cfg.ErrorCmd) == 0 && len(cfg.IPs) == 0 {
-		return nil, fmt.Errorf("configuration: error_cmd or ips empthy")
+	services := make(map[string]bool)
+	for i := range cfg.Services {
+		service := &cfg.Services[i]
+		if len(service.Name) == 0 {
+			return nil, fmt.Errorf("configuration: services name empthy")
+		}
+		if services[service.Name] {
+			return nil, fmt.Errorf("configuration: services %s duplicated", service.Name)
+		}
+		services[service.Name] = true
+		if service.FailCount == 0 {
+			service.FailCount = cfg.FailCount
+		}
+		if service.CheckCount == 0 {
+			service.CheckCount = cfg.CheckCount
+		}
+		if service.ResetCount == 0 {
+			service.ResetCount = cfg.ResetCount
+		}
+		if service.MaxRestarts == 0 {
+			service.MaxRestarts = cfg.MaxRestarts
+		}
+		if service.RestartsWindow == 0 {
+			service.RestartsWindow = cfg.RestartsWindow
+		}
 	}
-	if len(cfg.SuccessCmd) == 0 && len(cfg.IPs) == 0 {
-		return nil, fmt.Errorf("configuration: recovery_cmd or ips empthy")
+	switch cfg.Announcer.Type {
+	case "":
+	case "exabgp", "bgp":
+		if len(cfg.Announcer.Prefixes) == 0 {
+			cfg.Announcer.Prefixes = cfg.IPs
+		}
+		if len(cfg.Announcer.Prefixes) == 0 {
+			return nil, fmt.Errorf("configuration: announcer prefixes empthy")
+		}
+	default:
+		return nil, fmt.Errorf("configuration: announcer type %s unknown", cfg.Announcer.Type)
+	}
+	if len(cfg.Actions.Success) == 0 && len(cfg.Actions.Error) == 0 {
+		cfg.Actions = legacyActions(cfg)
+	}
+	if len(cfg.Actions.Error) == 0 {
+		return nil, fmt.Errorf("configuration: error_cmd, ips or actions error empthy")
+	}
+	if len(cfg.Actions.Success) == 0 {
+		return nil, fmt.Errorf("configuration: success_cmd, ips or actions success empthy")
+	}
+	if err = validateActions(cfg, "success", cfg.Actions.Success); err != nil {
+		return nil, err
+	}
+	if err = validateActions(cfg, "error", cfg.Actions.Error); err != nil {
+		return nil, err
+	}
+	if err = validateActions(cfg, "warn", cfg.Actions.Warn); err != nil {
+		return nil, err
+	}
+	if err = validateActions(cfg, "recover", cfg.Actions.Recover); err != nil {
+		return nil, err
+	}
+	if err = validateActions(cfg, "shutdown", cfg.Actions.Shutdown); err != nil {
+		return nil, err
+	}
+	if cfg.OnShutdown != "keep" && cfg.OnShutdown != "withdraw" {
+		return nil, fmt.Errorf("configuration: on_shutdown '%s' unknown", cfg.OnShutdown)
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		return nil, fmt.Errorf("configuration: shutdown_timeout must be positive")
+	}
+	for i := range cfg.Webhooks {
+		if len(cfg.Webhooks[i].URL) == 0 {
+			return nil, fmt.Errorf("configuration: webhooks url empthy")
+		}
+		if cfg.Webhooks[i].Retries < 0 {
+			return nil, fmt.Errorf("configuration: webhooks %s retries negative", cfg.Webhooks[i].URL)
+		}
 	}
+	if len(cfg.Aggregation.Expression) > 0 {
+		if _, err = aggregator.ParseExpr(cfg.Aggregation.Expression); err != nil {
+			return nil, fmt.Errorf("configuration: aggregation %s", err.Error())
+		}
+	}
+	if cfg.Aggregation.MinWeight < 0 {
+		return nil, fmt.Errorf("configuration: aggregation min_weight negative")
+	}
+	for i := range cfg.Aggregation.Groups {
+		if len(cfg.Aggregation.Groups[i].Name) == 0 {
+			return nil, fmt.Errorf("configuration: aggregation groups name empthy")
+		}
+		if len(cfg.Aggregation.Groups[i].Checkers) == 0 {
+			return nil, fmt.Errorf("configuration: aggregation groups %s checkers empthy", cfg.Aggregation.Groups[i].Name)
+		}
+	}
+	if cfg.Hysteresis.MinHold < 0 || cfg.Hysteresis.Window < 0 || cfg.Hysteresis.Backoff < 0 || cfg.Hysteresis.MaxBackoff < 0 {
+		return nil, fmt.Errorf("configuration: hysteresis durations negative")
+	}
+	if cfg.Hysteresis.FlapThreshold < 0 {
+		return nil, fmt.Errorf("configuration: hysteresis flap_threshold negative")
+	}
+	if (cfg.Hysteresis.Backoff > 0 || cfg.Hysteresis.FlapThreshold > 0) && cfg.Hysteresis.Window == 0 {
+		return nil, fmt.Errorf("configuration: hysteresis window empthy")
+	}
+	if _, err = peers.ParsePolicy(cfg.Peers.Policy); err != nil {
+		return nil, fmt.Errorf("configuration: %s", err.Error())
+	}
+	if len(cfg.Peers.Listen) == 0 {
+		if cfg.Peers.Policy != "none" {
+			return nil, fmt.Errorf("configuration: peers listen empthy")
+		}
+	} else {
+		if len(cfg.Peers.Addresses) == 0 {
+			return nil, fmt.Errorf("configuration: peers addresses empthy")
+		}
+		if cfg.Peers.Interval <= 0 || cfg.Peers.Timeout <= cfg.Peers.Interval {
+			return nil, fmt.Errorf("configuration: peers timeout must be greater than interval")
+		}
+	}
+	if cfg.Peers.MinHealthy < 0 || (cfg.Peers.Policy == "min_healthy" && cfg.Peers.MinHealthy == 0) {
+		return nil, fmt.Errorf("configuration: peers min_healthy must be positive")
+	}
+	if cfg.Peers.Election.Enabled {
+		if len(cfg.Peers.Listen) == 0 {
+			return nil, fmt.Errorf("configuration: peers listen empthy")
+		}
+		ipAction := false
+		for i := range cfg.Actions.Success {
+			if cfg.Actions.Success[i].Type == "ip" {
+				ipAction = true
+			}
+		}
+		if !ipAction {
+			return nil, fmt.Errorf("configuration: peers election without ip action")
+		}
+		if cfg.Peers.Election.Priority < 0 || cfg.Peers.Election.PreemptDelay < 0 || cfg.Peers.Election.Quorum < 0 {
+			return nil, fmt.Errorf("configuration: peers election priority, preempt_delay or quorum negative")
+		}
+		if cfg.Peers.Election.Quorum == 0 {
+			cfg.Peers.Election.Quorum = (len(cfg.Peers.Addresses)+1)/2 + 1
+		}
+	}
+	if len(cfg.GraphiteDestinations) == 0 && len(cfg.Relay) > 0 {
+		cfg.GraphiteDestinations = []GraphiteDestination{{Address: cfg.Relay, Protocol: "tcp"}}
+	}
+	for i := range cfg.GraphiteDestinations {
+		d := &cfg.GraphiteDestinations[i]
+		if len(d.Address) == 0 {
+			return nil, fmt.Errorf("configuration: graphite_destinations address empthy")
+		}
+		if len(d.Protocol) == 0 {
+			d.Protocol = "tcp"
+		}
+		if d.Protocol != "tcp" && d.Protocol != "udp" && d.Protocol != "pickle" {
+			return nil, fmt.Errorf("configuration: graphite_destinations protocol '%s' unknown", d.Protocol)
+		}
+	}
+	if cfg.GraphiteMode != "all" && cfg.GraphiteMode != "failover" {
+		return nil, fmt.Errorf("configuration: graphite_mode '%s' unknown", cfg.GraphiteMode)
+	}
+	if len(cfg.GraphiteSpool.Path) > 0 && (cfg.GraphiteSpool.SegmentSize < 1 || cfg.GraphiteSpool.MaxSize < cfg.GraphiteSpool.SegmentSize) {
+		return nil, fmt.Errorf("configuration: graphite_spool segment_size must be positive and not greater than max_size")
+	}
+	if cfg.StateMaxAge < 0 {
+		return nil, fmt.Errorf("configuration: state_max_age negative")
+	}
+	tcpChecks := make(map[string]bool)
+	for i := range cfg.TCPChecks {
+		name := cfg.TCPChecks[i].Name
+		if len(name) == 0 {
+			return nil, fmt.Errorf("configuration: tcp_checks name empthy")
+		}
+		if tcpChecks[name] {
+			return nil, fmt.Errorf("configuration: tcp_checks %s duplicated", name)
+		}
+		tcpChecks[name] = true
+		if len(cfg.TCPChecks[i].Targets) == 0 {
+			return nil, fmt.Errorf("configuration: tcp_checks %s targets empthy", name)
+		}
+		if cfg.TCPChecks[i].MinSuccess < 0 || cfg.TCPChecks[i].MinSuccess > len(cfg.TCPChecks[i].Targets) {
+			return nil, fmt.Errorf("configuration: tcp_checks %s min_success out of range", name)
+		}
+		for j := range cfg.TCPChecks[i].Targets {
+			if len(cfg.TCPChecks[i].Targets[j].Address) == 0 {
+				return nil, fmt.Errorf("configuration: tcp_checks %s target address empthy", name)
+			}
+			if cfg.TCPChecks[i].Targets[j].Timeout == 0 {
+				cfg.TCPChecks[i].Targets[j].Timeout = cfg.NetTimeout
+			}
+		}
+	}
+
 	if len(cfg.Hostname) == 0 {
 		var err error
 		cfg.Hostname, err = os.Hostname()
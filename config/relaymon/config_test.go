@@ -0,0 +1,490 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigServices(t *testing.T) {
+	f, err := ioutil.TempFile("", "relaymon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(`
+fail_count: 3
+check_count: 6
+reset_count: 4
+max_restarts: 5
+ips: [ "192.168.155.10/24" ]
+services:
+  - "carbon-c-relay"
+  - name: "auxiliary"
+    fail_count: 10
+    required: false
+    max_restarts: -1
+  - name: "go-carbon"
+    check_count: 2
+    reset_count: 1
+    restarts_window: 1m
+    process: "go-carbon"
+`)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(f.Name(), "")
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %s", err.Error())
+	}
+	want := []Service{
+		{Name: "carbon-c-relay", FailCount: 3, CheckCount: 6, ResetCount: 4, MaxRestarts: 5, RestartsWindow: 10 * time.Minute, Required: true},
+		{Name: "auxiliary", FailCount: 10, CheckCount: 6, ResetCount: 4, MaxRestarts: -1, RestartsWindow: 10 * time.Minute, Required: false},
+		{Name: "go-carbon", FailCount: 3, CheckCount: 2, ResetCount: 1, MaxRestarts: 5, RestartsWindow: time.Minute, Required: true, Process: "go-carbon"},
+	}
+	if !reflect.DeepEqual(cfg.Services, want) {
+		t.Errorf("LoadConfig() services got\n%+v\nwant\n%+v", cfg.Services, want)
+	}
+}
+
+func TestLoadConfigIPOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    string
+		wantErr bool
+	}{
+		{"default", "", false},
+		{"options", "ip_backend: ip\nip_label: lo:relaymon\nip_scope: host\nip_flags: [ nodad ]\n", false},
+		{"invalid backend", "ip_backend: invalid\n", true},
+		{"invalid label", "ip_label: eth0:relaymon\n", true},
+		{"invalid scope", "ip_scope: invalid\n", true},
+		{"invalid flags", "ip_flags: [ invalid ]\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ioutil.TempFile("", "relaymon")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(f.Name())
+			_, err = f.WriteString("ips: [ \"192.168.155.10/24\" ]\nservices: [ \"carbon-c-relay\" ]\n" + tt.opts)
+			f.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = LoadConfig(f.Name(), "")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadConfigHysteresis(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    string
+		want    Hysteresis
+		wantErr bool
+	}{
+		{name: "default", want: Hysteresis{Window: time.Hour, MaxBackoff: 30 * time.Minute}},
+		{
+			name: "options",
+			opts: "hysteresis: { min_hold: 1m, backoff: 30s, flap_threshold: 5 }\n",
+			want: Hysteresis{MinHold: time.Minute, Window: time.Hour, Backoff: 30 * time.Second, MaxBackoff: 30 * time.Minute, FlapThreshold: 5},
+		},
+		{name: "without window", opts: "hysteresis: { window: 0s, flap_threshold: 5 }\n", wantErr: true},
+		{name: "negative", opts: "hysteresis: { min_hold: -1s }\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ioutil.TempFile("", "relaymon")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(f.Name())
+			_, err = f.WriteString("ips: [ \"192.168.155.10/24\" ]\nservices: [ \"carbon-c-relay\" ]\n" + tt.opts)
+			f.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := LoadConfig(f.Name(), "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && cfg.Hysteresis != tt.want {
+				t.Errorf("LoadConfig() hysteresis = %+v, want %+v", cfg.Hysteresis, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigStateFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       string
+		wantFile   string
+		wantMaxAge time.Duration
+		wantErr    bool
+	}{
+		{name: "default", wantMaxAge: 5 * time.Minute},
+		{name: "options", opts: "state_file: /var/lib/relaymon/state.json\nstate_max_age: 1m\n", wantFile: "/var/lib/relaymon/state.json", wantMaxAge: time.Minute},
+		{name: "negative", opts: "state_file: /var/lib/relaymon/state.json\nstate_max_age: -1m\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ioutil.TempFile("", "relaymon")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(f.Name())
+			_, err = f.WriteString("ips: [ \"192.168.155.10/24\" ]\nservices: [ \"carbon-c-relay\" ]\n" + tt.opts)
+			f.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := LoadConfig(f.Name(), "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (cfg.StateFile != tt.wantFile || cfg.StateMaxAge != tt.wantMaxAge) {
+				t.Errorf("LoadConfig() state_file = '%s', state_max_age = %v, want '%s', %v", cfg.StateFile, cfg.StateMaxAge, tt.wantFile, tt.wantMaxAge)
+			}
+		})
+	}
+}
+
+func TestLoadConfigGraphiteDestinations(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     string
+		want     []GraphiteDestination
+		wantMode string
+		wantErr  bool
+	}{
+		{name: "default", want: []GraphiteDestination{{Address: "127.0.0.1", Protocol: "tcp"}}, wantMode: "all"},
+		{name: "disabled", opts: "graphite_relay: \"\"\n", want: nil, wantMode: "all"},
+		{
+			name: "destinations",
+			opts: "graphite_destinations: [ { address: \"127.0.0.1:2003\" }, { address: \"graphite:2003\", protocol: udp } ]\ngraphite_mode: failover\n",
+			want: []GraphiteDestination{{Address: "127.0.0.1:2003", Protocol: "tcp"}, {Address: "graphite:2003", Protocol: "udp"}}, wantMode: "failover",
+		},
+		{
+			name: "pickle tagged",
+			opts: "graphite_destinations: [ { address: \"graphite:2004\", protocol: pickle, tagged: true } ]\n",
+			want: []GraphiteDestination{{Address: "graphite:2004", Protocol: "pickle", Tagged: true}}, wantMode: "all",
+		},
+		{name: "invalid protocol", opts: "graphite_destinations: [ { address: \"127.0.0.1:2003\", protocol: http } ]\n", wantErr: true},
+		{name: "without address", opts: "graphite_destinations: [ { protocol: tcp } ]\n", wantErr: true},
+		{name: "invalid mode", opts: "graphite_mode: random\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ioutil.TempFile("", "relaymon")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(f.Name())
+			_, err = f.WriteString("ips: [ \"192.168.155.10/24\" ]\nservices: [ \"carbon-c-relay\" ]\n" + tt.opts)
+			f.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := LoadConfig(f.Name(), "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(cfg.GraphiteDestinations, tt.want) || cfg.GraphiteMode != tt.wantMode {
+				t.Errorf("LoadConfig() graphite_destinations = %+v, graphite_mode = '%s', want %+v, '%s'",
+					cfg.GraphiteDestinations, cfg.GraphiteMode, tt.want, tt.wantMode)
+			}
+		})
+	}
+}
+
+func TestLoadConfigGraphiteSpool(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    string
+		want    GraphiteSpool
+		wantErr bool
+	}{
+		{name: "default", want: GraphiteSpool{SegmentSize: 1 << 20, MaxSize: 64 << 20}},
+		{
+			name: "options", opts: "graphite_spool: { path: /var/spool/relaymon, segment_size: 65536, max_size: 1048576 }\n",
+			want: GraphiteSpool{Path: "/var/spool/relaymon", SegmentSize: 65536, MaxSize: 1048576},
+		},
+		{name: "segment greater than max", opts: "graphite_spool: { path: /var/spool/relaymon, segment_size: 65536, max_size: 1024 }\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ioutil.TempFile("", "relaymon")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(f.Name())
+			_, err = f.WriteString("ips: [ \"192.168.155.10/24\" ]\nservices: [ \"carbon-c-relay\" ]\n" + tt.opts)
+			f.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := LoadConfig(f.Name(), "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && cfg.GraphiteSpool != tt.want {
+				t.Errorf("LoadConfig() graphite_spool = %+v, want %+v", cfg.GraphiteSpool, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigShutdown(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        string
+		wantOn      string
+		wantTimeout time.Duration
+		wantActions int
+		wantErr     bool
+	}{
+		{name: "default", wantOn: "keep", wantTimeout: 30 * time.Second},
+		{name: "withdraw", opts: "on_shutdown: withdraw\nshutdown_timeout: 10s\nshutdown_cmd: \"echo stop\"\n", wantOn: "withdraw", wantTimeout: 10 * time.Second, wantActions: 1},
+		{name: "invalid", opts: "on_shutdown: remove\n", wantErr: true},
+		{name: "zero timeout", opts: "shutdown_timeout: 0s\n", wantErr: true},
+		{
+			name:    "ip action",
+			opts:    "actions: { success: [ { type: ip } ], error: [ { type: ip } ], shutdown: [ { type: ip } ] }\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ioutil.TempFile("", "relaymon")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(f.Name())
+			_, err = f.WriteString("ips: [ \"192.168.155.10/24\" ]\nservices: [ \"carbon-c-relay\" ]\n" + tt.opts)
+			f.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := LoadConfig(f.Name(), "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if cfg.OnShutdown != tt.wantOn || cfg.ShutdownTimeout != tt.wantTimeout || len(cfg.Actions.Shutdown) != tt.wantActions {
+				t.Errorf("LoadConfig() on_shutdown = '%s', shutdown_timeout = %v, shutdown actions = %d, want '%s', %v, %d",
+					cfg.OnShutdown, cfg.ShutdownTimeout, len(cfg.Actions.Shutdown), tt.wantOn, tt.wantTimeout, tt.wantActions)
+			}
+		})
+	}
+}
+
+func TestLoadConfigPeers(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    string
+		wantErr bool
+	}{
+		{"default", "", false},
+		{"min healthy", "peers: { listen: \":7946\", addresses: [ \"relay2:7946\" ], policy: min_healthy, min_healthy: 1 }\n", false},
+		{"local only", "peers: { listen: \":7946\", addresses: [ \"relay2:7946\" ], policy: local_only, secret: test }\n", false},
+		{"without listen", "peers: { addresses: [ \"relay2:7946\" ], policy: local_only }\n", true},
+		{"without addresses", "peers: { listen: \":7946\" }\n", true},
+		{"min healthy empthy", "peers: { listen: \":7946\", addresses: [ \"relay2:7946\" ], policy: min_healthy }\n", true},
+		{"invalid policy", "peers: { listen: \":7946\", addresses: [ \"relay2:7946\" ], policy: invalid }\n", true},
+		{"election", "peers: { listen: \":7946\", addresses: [ \"relay2:7946\" ], election: { enabled: true, priority: 50 } }\n", false},
+		{"election without listen", "peers: { election: { enabled: true } }\n", true},
+		{"election without ips", "actions: { success: [ { type: touch, path: /tmp/a } ], error: [ { type: touch, path: /tmp/a, remove: true } ] }\npeers: { listen: \":7946\", addresses: [ \"relay2:7946\" ], election: { enabled: true } }\n", true},
+		{"invalid timeout", "peers: { listen: \":7946\", addresses: [ \"relay2:7946\" ], timeout: 1s }\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ioutil.TempFile("", "relaymon")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(f.Name())
+			_, err = f.WriteString("ips: [ \"192.168.155.10/24\" ]\nservices: [ \"carbon-c-relay\" ]\n" + tt.opts)
+			f.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = LoadConfig(f.Name(), "")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadConfigAnnouncer(t *testing.T) {
+	f, err := ioutil.TempFile("", "relaymon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(`
+ips: [ "192.168.155.10/32" ]
+services: [ "carbon-c-relay" ]
+announcer:
+  type: bgp
+  peer: 127.0.0.1
+  local_as: 65000
+  router_id: 192.168.155.1
+`)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(f.Name(), "")
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %s", err.Error())
+	}
+	if !reflect.DeepEqual(cfg.Announcer.Prefixes, cfg.IPs) {
+		t.Errorf("LoadConfig() announcer prefixes = %v, want %v", cfg.Announcer.Prefixes, cfg.IPs)
+	}
+}
+
+func TestLoadConfigAggregation(t *testing.T) {
+	f, err := ioutil.TempFile("", "relaymon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(`
+ips: [ "192.168.155.10/32" ]
+services: [ "carbon-c-relay" ]
+aggregation:
+  weights: { carbon-c-relay: 10 }
+  groups:
+    - name: storage
+      checkers: [ storage1, storage2 ]
+      min_success: 1
+    - name: graphite
+      checkers: [ graphite ]
+      required: false
+  expression: carbon-c-relay && storage
+`)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(f.Name(), "")
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %s", err.Error())
+	}
+	want := Aggregation{
+		Weights: map[string]float64{"carbon-c-relay": 10},
+		Groups: []AggregationGroup{
+			{Name: "storage", Checkers: []string{"storage1", "storage2"}, MinSuccess: 1, Required: true},
+			{Name: "graphite", Checkers: []string{"graphite"}},
+		},
+		Expression: "carbon-c-relay && storage",
+	}
+	if !reflect.DeepEqual(cfg.Aggregation, want) {
+		t.Errorf("LoadConfig() aggregation = %+v, want %+v", cfg.Aggregation, want)
+	}
+}
+
+func TestLoadConfigActions(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         string
+		wantSuccess []Action
+		wantError   []Action
+		wantErr     bool
+	}{
+		{
+			name: "legacy",
+			cfg:  "ips: [ \"192.168.155.10/32\" ]\nsuccess_cmd: \"echo up\"\nerror_cmd: \"echo down\"\n",
+			wantSuccess: []Action{
+				{Type: "ip"}, {Type: "exec", Args: []string{"sh", "-c", "echo up"}},
+			},
+			wantError: []Action{
+				{Type: "ip"}, {Type: "exec", Args: []string{"sh", "-c", "echo down"}},
+			},
+		},
+		{
+			name: "actions",
+			cfg: `
+actions:
+  success:
+    - type: systemd
+      op: start
+      unit: carbon-c-relay
+      retry: 1
+  error:
+    - type: touch
+      path: /tmp/relaymon.down
+      abort_on_failure: true
+`,
+			wantSuccess: []Action{{Type: "systemd", Op: "start", Unit: "carbon-c-relay", Retry: 1}},
+			wantError:   []Action{{Type: "touch", Path: "/tmp/relaymon.down", AbortOnFailure: true}},
+		},
+		{
+			name:    "ip without ips",
+			cfg:     "actions: { success: [ { type: ip } ], error: [ { type: ip } ] }\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown type",
+			cfg:     "actions: { success: [ { type: unknown } ], error: [ { type: touch, path: /tmp/a } ] }\n",
+			wantErr: true,
+		},
+		{
+			name:    "empthy",
+			cfg:     "\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ioutil.TempFile("", "relaymon")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(f.Name())
+			_, err = f.WriteString("services: [ \"carbon-c-relay\" ]\n" + tt.cfg)
+			f.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := LoadConfig(f.Name(), "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(cfg.Actions.Success, tt.wantSuccess) {
+				t.Errorf("LoadConfig() success actions got\n%+v\nwant\n%+v", cfg.Actions.Success, tt.wantSuccess)
+			}
+			if !reflect.DeepEqual(cfg.Actions.Error, tt.wantError) {
+				t.Errorf("LoadConfig() error actions got\n%+v\nwant\n%+v", cfg.Actions.Error, tt.wantError)
+			}
+		})
+	}
+}
@@ -3,8 +3,8 @@ module github.com/msaf1980/relaymon
 go 1.13
 
 require (
+	github.com/godbus/dbus/v5 v5.1.0
 	github.com/msaf1980/go-lockfree-queue v0.0.0-20200822061714-35c92fde4d45
-	github.com/msaf1980/graphite-golang v0.0.0-20200825115628-d67eaf9b8158
 	github.com/rs/zerolog v1.19.0
 	gopkg.in/yaml.v2 v2.3.0
 )
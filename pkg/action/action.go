@@ -0,0 +1,81 @@
+package action
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Action executed on state transition
+type Action interface {
+	// Type of action (ip, route, exec, systemd, http, touch)
+	Type() string
+	// Run action, return output (for log) and error
+	Run(ctx context.Context) (string, error)
+}
+
+// Step is action with retry and abort policy
+type Step struct {
+	Action Action
+	// Retry count after failure
+	Retry         int
+	RetryInterval time.Duration
+	// AbortOnFailure skip next steps, if action failed (after retries)
+	AbortOnFailure bool
+}
+
+// Result of step run
+type Result struct {
+	Action   Action
+	Out      string
+	Err      error
+	Attempts int
+}
+
+// Errors is multiple errors from one action
+type Errors []error
+
+// Error get error description
+func (e Errors) Error() string {
+	s := make([]string, len(e))
+	for i := range e {
+		s[i] = e[i].Error()
+	}
+	return strings.Join(s, "; ")
+}
+
+// Run steps in order, return results and true, if steps aborted
+func Run(ctx context.Context, steps []Step) ([]Result, bool) {
+	results := make([]Result, 0, len(steps))
+	for i := range steps {
+		r := Result{Action: steps[i].Action}
+		for r.Attempts <= steps[i].Retry {
+			if r.Attempts > 0 && steps[i].RetryInterval > 0 {
+				select {
+				case <-ctx.Done():
+				case <-time.After(steps[i].RetryInterval):
+				}
+			}
+			r.Attempts++
+			r.Out, r.Err = steps[i].Action.Run(ctx)
+			if r.Err == nil || ctx.Err() != nil {
+				break
+			}
+		}
+		results = append(results, r)
+		if r.Err != nil && steps[i].AbortOnFailure {
+			return results, true
+		}
+	}
+	return results, false
+}
+
+// Failed return true, if any result failed
+func Failed(results []Result) bool {
+	for i := range results {
+		if results[i].Err != nil {
+			return true
+		}
+	}
+	return false
+}
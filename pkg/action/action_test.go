@@ -0,0 +1,88 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+type fakeAction struct {
+	name  string
+	fails int
+	runs  int
+}
+
+func (f *fakeAction) Type() string {
+	return f.name
+}
+
+func (f *fakeAction) Run(ctx context.Context) (string, error) {
+	f.runs++
+	if f.runs <= f.fails {
+		return "", fmt.Errorf("%s failed", f.name)
+	}
+	return f.name + " done", nil
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name         string
+		steps        []Step
+		wantAttempts []int
+		wantFailed   []bool
+		wantAborted  bool
+	}{
+		{
+			name: "success after retry",
+			steps: []Step{
+				{Action: &fakeAction{name: "a", fails: 2}, Retry: 2},
+				{Action: &fakeAction{name: "b"}},
+			},
+			wantAttempts: []int{3, 1},
+			wantFailed:   []bool{false, false},
+		},
+		{
+			name: "failed without abort",
+			steps: []Step{
+				{Action: &fakeAction{name: "a", fails: 2}, Retry: 1},
+				{Action: &fakeAction{name: "b"}},
+			},
+			wantAttempts: []int{2, 1},
+			wantFailed:   []bool{true, false},
+		},
+		{
+			name: "abort on failure",
+			steps: []Step{
+				{Action: &fakeAction{name: "a", fails: 1}, AbortOnFailure: true},
+				{Action: &fakeAction{name: "b"}},
+			},
+			wantAttempts: []int{1},
+			wantFailed:   []bool{true},
+			wantAborted:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, aborted := Run(context.Background(), tt.steps)
+			if aborted != tt.wantAborted {
+				t.Errorf("Run() aborted = %v, want %v", aborted, tt.wantAborted)
+			}
+			if len(results) != len(tt.wantAttempts) {
+				t.Fatalf("Run() results = %d, want %d", len(results), len(tt.wantAttempts))
+			}
+			wantFailed := false
+			for i := range results {
+				wantFailed = wantFailed || tt.wantFailed[i]
+				if results[i].Attempts != tt.wantAttempts[i] {
+					t.Errorf("Run() results[%d] attempts = %d, want %d", i, results[i].Attempts, tt.wantAttempts[i])
+				}
+				if (results[i].Err != nil) != tt.wantFailed[i] {
+					t.Errorf("Run() results[%d] error = %v, want failed %v", i, results[i].Err, tt.wantFailed[i])
+				}
+			}
+			if Failed(results) != wantFailed {
+				t.Errorf("Failed() = %v, want %v", Failed(results), wantFailed)
+			}
+		})
+	}
+}
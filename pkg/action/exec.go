@@ -0,0 +1,98 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout for exec, systemd and http actions
+const DefaultTimeout = 20 * time.Second
+
+// Exec action run command (without shell)
+type Exec struct {
+	Args    []string
+	Env     []string
+	Timeout time.Duration
+}
+
+// NewExec create exec action (env in KEY=VALUE format, appended to relaymon environment)
+func NewExec(args []string, env []string, timeout time.Duration) (*Exec, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("exec args empthy")
+	}
+	for i := range env {
+		if strings.IndexByte(env[i], '=') < 1 {
+			return nil, fmt.Errorf("exec env %s invalid", env[i])
+		}
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Exec{Args: args, Env: env, Timeout: timeout}, nil
+}
+
+// NewShell create exec action for run command with sh -c
+func NewShell(command string, timeout time.Duration) (*Exec, error) {
+	return NewExec([]string{"sh", "-c", command}, nil, timeout)
+}
+
+// Type of action
+func (e *Exec) Type() string {
+	return "exec"
+}
+
+// Run command
+func (e *Exec) Run(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, e.Args[0], e.Args[1:]...)
+	if len(e.Env) > 0 {
+		cmd.Env = append(os.Environ(), e.Env...)
+	}
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("command timeout")
+	} else if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if ok {
+			err = fmt.Errorf("command exit with %d", exitErr.ExitCode())
+		} else {
+			err = fmt.Errorf("command execute error with %s", err.Error())
+		}
+	}
+	return string(out), err
+}
+
+// Systemd action start/stop/restart/reload service with systemctl
+type Systemd struct {
+	Exec
+	Op   string
+	Unit string
+}
+
+// NewSystemd create systemd action
+func NewSystemd(op string, unit string, timeout time.Duration) (*Systemd, error) {
+	switch op {
+	case "start", "stop", "restart", "reload":
+	default:
+		return nil, fmt.Errorf("systemd op %s unknown", op)
+	}
+	if len(unit) == 0 {
+		return nil, fmt.Errorf("systemd unit empthy")
+	}
+	e, err := NewExec([]string{"systemctl", op, unit}, nil, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &Systemd{Exec: *e, Op: op, Unit: unit}, nil
+}
+
+// Type of action
+func (s *Systemd) Type() string {
+	return "systemd"
+}
@@ -0,0 +1,61 @@
+package action
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestExec(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		env     []string
+		timeout time.Duration
+		wantOut string
+		wantErr string
+	}{
+		{"args", []string{"echo", "-n", "a b"}, nil, 0, "a b", ""},
+		{"env", []string{"sh", "-c", "echo -n $RELAYMON_TEST"}, []string{"RELAYMON_TEST=up"}, 0, "up", ""},
+		{"exit code", []string{"sh", "-c", "exit 3"}, nil, 0, "", "command exit with 3"},
+		{"timeout", []string{"sleep", "10"}, nil, 100 * time.Millisecond, "", "command timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewExec(tt.args, tt.env, tt.timeout)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := e.Run(context.Background())
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Run() error = %v", err)
+				}
+			} else if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("Run() error = %v, want %s", err, tt.wantErr)
+			}
+			if out != tt.wantOut {
+				t.Errorf("Run() = '%s', want '%s'", out, tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestNewExecInvalid(t *testing.T) {
+	if _, err := NewExec(nil, nil, 0); err == nil {
+		t.Errorf("NewExec() must fail with empthy args")
+	}
+	if _, err := NewExec([]string{"true"}, []string{"INVALID"}, 0); err == nil {
+		t.Errorf("NewExec() must fail with invalid env")
+	}
+	if _, err := NewSystemd("kill", "carbon-c-relay", 0); err == nil {
+		t.Errorf("NewSystemd() must fail with unknown op")
+	}
+	s, err := NewSystemd("restart", "carbon-c-relay", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Timeout != DefaultTimeout || len(s.Args) != 3 || s.Args[1] != "restart" {
+		t.Errorf("NewSystemd() = %+v", s)
+	}
+}
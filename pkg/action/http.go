@@ -0,0 +1,75 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HTTP action send http request (2xx response code is success)
+type HTTP struct {
+	Method  string
+	URL     string
+	Headers map[string]string
+	Body    string
+	Timeout time.Duration
+}
+
+// NewHTTP create http action (GET by default, POST if body not empty)
+func NewHTTP(method, url string, headers map[string]string, body string, timeout time.Duration) (*HTTP, error) {
+	if len(url) == 0 {
+		return nil, fmt.Errorf("http url empthy")
+	}
+	if len(method) == 0 {
+		if len(body) == 0 {
+			method = http.MethodGet
+		} else {
+			method = http.MethodPost
+		}
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	if _, err := http.NewRequest(method, url, nil); err != nil {
+		return nil, err
+	}
+	return &HTTP{Method: method, URL: url, Headers: headers, Body: body, Timeout: timeout}, nil
+}
+
+// Type of action
+func (h *HTTP) Type() string {
+	return "http"
+}
+
+// Run send http request
+func (h *HTTP) Run(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	var body io.Reader
+	if len(h.Body) > 0 {
+		body = strings.NewReader(h.Body)
+	}
+	req, err := http.NewRequest(h.Method, h.URL, body)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range h.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	out, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return string(out), fmt.Errorf("%s %s: %s", h.Method, h.URL, resp.Status)
+	}
+	return string(out), nil
+}
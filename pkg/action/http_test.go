@@ -0,0 +1,49 @@
+package action
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTP(t *testing.T) {
+	var (
+		method, body, header string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		header = r.Header.Get("X-Weight")
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	h, err := NewHTTP("", ts.URL+"/weight", map[string]string{"X-Weight": "0"}, `{"weight":0}`, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := h.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if out != "ok" || method != http.MethodPost || header != "0" || body != `{"weight":0}` {
+		t.Errorf("Run() out '%s', request %s with header '%s' and body '%s'", out, method, header, body)
+	}
+
+	h, err = NewHTTP("", ts.URL+"/fail", nil, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = h.Run(context.Background()); err == nil {
+		t.Errorf("Run() must fail with 500 response")
+	}
+	if method != http.MethodGet {
+		t.Errorf("Run() method %s, want %s", method, http.MethodGet)
+	}
+}
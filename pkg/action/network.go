@@ -0,0 +1,77 @@
+package action
+
+import (
+	"context"
+	"net"
+
+	"github.com/msaf1980/relaymon/pkg/announcer"
+	"github.com/msaf1980/relaymon/pkg/netconf"
+)
+
+// IP action add or remove ip addresses on interface
+type IP struct {
+	Iface string
+	Addrs []*net.IPNet
+	Add   bool
+}
+
+// NewIP create ip action
+func NewIP(iface string, addrs []*net.IPNet, add bool) *IP {
+	return &IP{Iface: iface, Addrs: addrs, Add: add}
+}
+
+// Type of action
+func (a *IP) Type() string {
+	return "ip"
+}
+
+// Run add or remove ip addresses (error is Errors)
+func (a *IP) Run(ctx context.Context) (string, error) {
+	var errs []error
+	if a.Add {
+		errs = netconf.IfaceAddrAdd(a.Iface, a.Addrs)
+	} else {
+		errs = netconf.IfaceAddrDel(a.Iface, a.Addrs)
+	}
+	if len(errs) > 0 {
+		return "", Errors(errs)
+	}
+	if a.Add {
+		return "IP addresses configured", nil
+	}
+	return "IP addresses deconfigured", nil
+}
+
+// Route action announce or withdraw routes (no-op if announcer is nil)
+type Route struct {
+	Announcer announcer.Announcer
+	Prefixes  []*net.IPNet
+	Announce  bool
+}
+
+// NewRoute create route action
+func NewRoute(a announcer.Announcer, prefixes []*net.IPNet, announce bool) *Route {
+	return &Route{Announcer: a, Prefixes: prefixes, Announce: announce}
+}
+
+// Type of action
+func (r *Route) Type() string {
+	return "route"
+}
+
+// Run announce or withdraw routes
+func (r *Route) Run(ctx context.Context) (string, error) {
+	if r.Announcer == nil {
+		return "", nil
+	}
+	if r.Announce {
+		if err := r.Announcer.Announce(r.Prefixes); err != nil {
+			return "", err
+		}
+		return "routes announced", nil
+	}
+	if err := r.Announcer.Withdraw(r.Prefixes); err != nil {
+		return "", err
+	}
+	return "routes withdrawn", nil
+}
@@ -0,0 +1,46 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Touch action create file (or update modification time) or remove it (for load balancers health checks)
+type Touch struct {
+	Path   string
+	Remove bool
+}
+
+// NewTouch create touch action
+func NewTouch(path string, remove bool) (*Touch, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("touch path empthy")
+	}
+	return &Touch{Path: path, Remove: remove}, nil
+}
+
+// Type of action
+func (t *Touch) Type() string {
+	return "touch"
+}
+
+// Run touch or remove file
+func (t *Touch) Run(ctx context.Context) (string, error) {
+	if t.Remove {
+		if err := os.Remove(t.Path); err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		return "", nil
+	}
+	f, err := os.OpenFile(t.Path, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return "", err
+	}
+	if err = f.Close(); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	return "", os.Chtimes(t.Path, now, now)
+}
@@ -0,0 +1,38 @@
+package action
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestTouch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "relaymon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := path.Join(dir, "health")
+
+	touch, _ := NewTouch(file, false)
+	remove, _ := NewTouch(file, true)
+
+	for i := 0; i < 2; i++ {
+		if _, err = touch.Run(context.Background()); err != nil {
+			t.Fatalf("touch Run() error = %v", err)
+		}
+		if _, err = os.Stat(file); err != nil {
+			t.Fatalf("touch Run() file not created: %v", err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if _, err = remove.Run(context.Background()); err != nil {
+			t.Fatalf("remove Run() error = %v", err)
+		}
+		if _, err = os.Stat(file); !os.IsNotExist(err) {
+			t.Fatalf("remove Run() file not removed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package aggregator
+
+import (
+	"fmt"
+
+	"github.com/msaf1980/relaymon/pkg/checker"
+)
+
+// Group is named quorum of checkers (at least MinSuccess checkers must succeed)
+type Group struct {
+	Name     string
+	Checkers []string
+	// MinSuccess checkers count (all, if 0)
+	MinSuccess int
+	// Required group failure evict node, failure of not required group only degrade global state to warn
+	Required bool
+}
+
+// Policy describe aggregation policy. Empty policy require success of all not advisory checkers.
+type Policy struct {
+	// Weights of checkers (by default 1 for required checkers, 0 for advisory)
+	Weights map[string]float64
+	// MinWeight of succeeded checkers (weight rule disabled, if 0)
+	MinWeight float64
+	Groups    []Group
+	// Expression over checkers and groups names (replace groups required flag and weight rule)
+	Expression string
+}
+
+// Empty return true, if policy not configured
+func (p *Policy) Empty() bool {
+	return p.MinWeight == 0 && len(p.Groups) == 0 && len(p.Expression) == 0
+}
+
+// Input is checker state for aggregation
+type Input struct {
+	Name     string
+	State    checker.State
+	Advisory bool
+}
+
+// Aggregator calculate global state from checkers states
+type Aggregator struct {
+	policy  Policy
+	weights map[string]float64
+	groups  map[string]*Group
+	expr    Expr
+}
+
+// New create aggregator for checkers (inputs states are ignored)
+func New(policy Policy, inputs []Input) (*Aggregator, error) {
+	a := &Aggregator{
+		policy:  policy,
+		weights: make(map[string]float64),
+		groups:  make(map[string]*Group),
+	}
+	names := make(map[string]bool)
+	for i := range inputs {
+		name := inputs[i].Name
+		if names[name] && !policy.Empty() {
+			return nil, fmt.Errorf("aggregation: checker %s duplicated", name)
+		}
+		names[name] = true
+		if inputs[i].Advisory {
+			a.weights[name] = 0
+		} else {
+			a.weights[name] = 1
+		}
+	}
+	for name, w := range policy.Weights {
+		if !names[name] {
+			return nil, fmt.Errorf("aggregation: weight for unknown checker %s", name)
+		}
+		if w < 0 {
+			return nil, fmt.Errorf("aggregation: checker %s weight negative", name)
+		}
+		a.weights[name] = w
+	}
+	if policy.MinWeight < 0 {
+		return nil, fmt.Errorf("aggregation: min_weight negative")
+	}
+	for i := range policy.Groups {
+		g := &policy.Groups[i]
+		if len(g.Name) == 0 {
+			return nil, fmt.Errorf("aggregation: group name empthy")
+		}
+		if names[g.Name] || a.groups[g.Name] != nil {
+			return nil, fmt.Errorf("aggregation: group %s duplicated", g.Name)
+		}
+		if len(g.Checkers) == 0 {
+			return nil, fmt.Errorf("aggregation: group %s checkers empthy", g.Name)
+		}
+		for _, name := range g.Checkers {
+			if !names[name] {
+				return nil, fmt.Errorf("aggregation: group %s unknown checker %s", g.Name, name)
+			}
+		}
+		if g.MinSuccess < 0 || g.MinSuccess > len(g.Checkers) {
+			return nil, fmt.Errorf("aggregation: group %s min_success out of range", g.Name)
+		}
+		a.groups[g.Name] = g
+	}
+	if len(policy.Expression) > 0 {
+		expr, err := ParseExpr(policy.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("aggregation: %s", err.Error())
+		}
+		for _, name := range expr.Names() {
+			if !names[name] && a.groups[name] == nil {
+				return nil, fmt.Errorf("aggregation: expression unknown name %s", name)
+			}
+		}
+		a.expr = expr
+	}
+	return a, nil
+}
+
+// value return checker value, warn state is success in lenient mode and failure in strict mode
+func value(state checker.State, strict bool) Value {
+	switch state {
+	case checker.SuccessState:
+		return True
+	case checker.WarnState:
+		if strict {
+			return False
+		}
+		return True
+	case checker.ErrorState:
+		return False
+	default:
+		return Unknown
+	}
+}
+
+func (a *Aggregator) group(g *Group, checkerValue func(name string) Value) Value {
+	values := make([]Value, len(g.Checkers))
+	for i, name := range g.Checkers {
+		values[i] = checkerValue(name)
+	}
+	n := g.MinSuccess
+	if n == 0 {
+		n = len(g.Checkers)
+	}
+	return AtLeast(n, values...)
+}
+
+// weight return weight rule result
+func (a *Aggregator) weight(checkerValue func(name string) Value) Value {
+	var t, u float64
+	for name, w := range a.weights {
+		switch checkerValue(name) {
+		case True:
+			t += w
+		case Unknown:
+			u += w
+		}
+	}
+	if t >= a.policy.MinWeight {
+		return True
+	}
+	if t+u < a.policy.MinWeight {
+		return False
+	}
+	return Unknown
+}
+
+func (a *Aggregator) eval(inputs []Input, states map[string]checker.State, strict bool) Value {
+	checkerValue := func(name string) Value {
+		state, ok := states[name]
+		if !ok {
+			return Unknown
+		}
+		return value(state, strict)
+	}
+	nameValue := func(name string) Value {
+		if g, ok := a.groups[name]; ok {
+			return a.group(g, checkerValue)
+		}
+		return checkerValue(name)
+	}
+
+	if a.expr != nil {
+		return a.expr.Eval(nameValue)
+	}
+	if a.policy.Empty() {
+		result := True
+		for i := range inputs {
+			if !inputs[i].Advisory {
+				result = And(result, value(inputs[i].State, strict))
+			}
+		}
+		return result
+	}
+	result := True
+	for i := range a.policy.Groups {
+		if a.policy.Groups[i].Required {
+			result = And(result, a.group(&a.policy.Groups[i], checkerValue))
+		}
+	}
+	if a.policy.MinWeight > 0 {
+		result = And(result, a.weight(checkerValue))
+	}
+	return result
+}
+
+// Aggregate return global state: error, if policy failed, collecting, if policy result unknown or some checker
+// is collecting, success, if all checkers succeeded, warn in other cases (node degraded).
+// Ready is true, if policy succeeded without checkers in warn state (node can be brought up in warn state).
+func (a *Aggregator) Aggregate(inputs []Input) (checker.State, bool) {
+	states := make(map[string]checker.State, len(inputs))
+	allSuccess := true
+	collecting := false
+	for i := range inputs {
+		states[inputs[i].Name] = inputs[i].State
+		switch inputs[i].State {
+		case checker.SuccessState:
+		case checker.CollectingState:
+			collecting = true
+			allSuccess = false
+		default:
+			allSuccess = false
+		}
+	}
+	switch a.eval(inputs, states, false) {
+	case False:
+		return checker.ErrorState, false
+	case Unknown:
+		return checker.CollectingState, false
+	}
+	if collecting {
+		return checker.CollectingState, false
+	}
+	ready := a.eval(inputs, states, true) == True
+	if ready && allSuccess {
+		return checker.SuccessState, true
+	}
+	return checker.WarnState, ready
+}
@@ -0,0 +1,157 @@
+package aggregator
+
+import (
+	"testing"
+
+	"github.com/msaf1980/relaymon/pkg/checker"
+)
+
+func inputs(names []string, states ...checker.State) []Input {
+	in := make([]Input, len(names))
+	for i := range names {
+		in[i] = Input{Name: names[i], State: states[i]}
+	}
+	return in
+}
+
+func TestAggregatorDefault(t *testing.T) {
+	tests := []struct {
+		name      string
+		inputs    []Input
+		want      checker.State
+		wantReady bool
+	}{
+		{"success", []Input{{Name: "a", State: checker.SuccessState}, {Name: "b", State: checker.SuccessState}}, checker.SuccessState, true},
+		{"collecting", []Input{{Name: "a", State: checker.CollectingState}, {Name: "b", State: checker.SuccessState}}, checker.CollectingState, false},
+		{"error", []Input{{Name: "a", State: checker.CollectingState}, {Name: "b", State: checker.ErrorState}}, checker.ErrorState, false},
+		{"warn", []Input{{Name: "a", State: checker.WarnState}, {Name: "b", State: checker.SuccessState}}, checker.WarnState, false},
+		{"advisory error", []Input{{Name: "a", State: checker.SuccessState}, {Name: "b", State: checker.ErrorState, Advisory: true}}, checker.WarnState, true},
+		{"advisory not found", []Input{{Name: "a", State: checker.SuccessState}, {Name: "b", State: checker.NotFoundState, Advisory: true}}, checker.WarnState, true},
+		{"not found", []Input{{Name: "a", State: checker.NotFoundState}}, checker.CollectingState, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(Policy{}, tt.inputs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ready := a.Aggregate(tt.inputs)
+			if got != tt.want || ready != tt.wantReady {
+				t.Errorf("Aggregate() = (%s, %v), want (%s, %v)", got.String(), ready, tt.want.String(), tt.wantReady)
+			}
+		})
+	}
+}
+
+func TestAggregatorPolicy(t *testing.T) {
+	names := []string{"relay", "storage1", "storage2", "graphite"}
+	storage := Group{Name: "storage", Checkers: []string{"storage1", "storage2"}, MinSuccess: 1, Required: true}
+	tests := []struct {
+		name      string
+		policy    Policy
+		states    []checker.State
+		want      checker.State
+		wantReady bool
+	}{
+		{
+			"expression success",
+			Policy{Expression: "relay && atleast(1, storage1, storage2)"},
+			[]checker.State{checker.SuccessState, checker.SuccessState, checker.SuccessState, checker.SuccessState},
+			checker.SuccessState, true,
+		},
+		{
+			"expression degraded",
+			Policy{Expression: "relay && atleast(1, storage1, storage2)"},
+			[]checker.State{checker.SuccessState, checker.ErrorState, checker.SuccessState, checker.ErrorState},
+			checker.WarnState, true,
+		},
+		{
+			"expression error",
+			Policy{Expression: "relay && atleast(1, storage1, storage2)"},
+			[]checker.State{checker.SuccessState, checker.ErrorState, checker.ErrorState, checker.SuccessState},
+			checker.ErrorState, false,
+		},
+		{
+			"expression unknown",
+			Policy{Expression: "relay && atleast(1, storage1, storage2)"},
+			[]checker.State{checker.SuccessState, checker.ErrorState, checker.NotFoundState, checker.SuccessState},
+			checker.CollectingState, false,
+		},
+		{
+			"expression group",
+			Policy{Groups: []Group{storage}, Expression: "relay && storage"},
+			[]checker.State{checker.SuccessState, checker.WarnState, checker.ErrorState, checker.SuccessState},
+			checker.WarnState, false,
+		},
+		{
+			"required group",
+			Policy{Groups: []Group{storage}},
+			[]checker.State{checker.ErrorState, checker.ErrorState, checker.SuccessState, checker.ErrorState},
+			checker.WarnState, true,
+		},
+		{
+			"required group error",
+			Policy{Groups: []Group{storage}},
+			[]checker.State{checker.SuccessState, checker.ErrorState, checker.ErrorState, checker.SuccessState},
+			checker.ErrorState, false,
+		},
+		{
+			"not required group",
+			Policy{Groups: []Group{{Name: "storage", Checkers: []string{"storage1", "storage2"}}}, MinWeight: 1, Weights: map[string]float64{"storage1": 0, "storage2": 0, "graphite": 0}},
+			[]checker.State{checker.SuccessState, checker.ErrorState, checker.ErrorState, checker.ErrorState},
+			checker.WarnState, true,
+		},
+		{
+			"weights",
+			Policy{Weights: map[string]float64{"relay": 10}, MinWeight: 11},
+			[]checker.State{checker.SuccessState, checker.ErrorState, checker.SuccessState, checker.ErrorState},
+			checker.WarnState, true,
+		},
+		{
+			"weights error",
+			Policy{Weights: map[string]float64{"relay": 10}, MinWeight: 11},
+			[]checker.State{checker.ErrorState, checker.SuccessState, checker.SuccessState, checker.SuccessState},
+			checker.ErrorState, false,
+		},
+		{
+			"weights unknown",
+			Policy{Weights: map[string]float64{"relay": 10}, MinWeight: 11},
+			[]checker.State{checker.CollectingState, checker.SuccessState, checker.SuccessState, checker.SuccessState},
+			checker.CollectingState, false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := inputs(names, tt.states...)
+			a, err := New(tt.policy, in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ready := a.Aggregate(in)
+			if got != tt.want || ready != tt.wantReady {
+				t.Errorf("Aggregate() = (%s, %v), want (%s, %v)", got.String(), ready, tt.want.String(), tt.wantReady)
+			}
+		})
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	in := inputs([]string{"relay", "storage"}, checker.SuccessState, checker.SuccessState)
+	policies := map[string]Policy{
+		"unknown weight":     {Weights: map[string]float64{"graphite": 1}},
+		"negative weight":    {Weights: map[string]float64{"relay": -1}},
+		"unknown in group":   {Groups: []Group{{Name: "g", Checkers: []string{"graphite"}}}},
+		"group min_success":  {Groups: []Group{{Name: "g", Checkers: []string{"relay"}, MinSuccess: 2}}},
+		"group name":         {Groups: []Group{{Name: "relay", Checkers: []string{"relay"}}}},
+		"unknown expression": {Expression: "relay && graphite"},
+		"invalid expression": {Expression: "relay &&"},
+	}
+	for name, p := range policies {
+		if _, err := New(p, in); err == nil {
+			t.Errorf("New() with %s must fail", name)
+		}
+	}
+	if _, err := New(Policy{MinWeight: 1}, append(in, in[0])); err == nil {
+		t.Errorf("New() with duplicated checker must fail")
+	}
+}
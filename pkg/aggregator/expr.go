@@ -0,0 +1,313 @@
+package aggregator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Expr is boolean expression over checkers and groups names
+type Expr interface {
+	// Eval expression with names values
+	Eval(value func(name string) Value) Value
+	// Names return referenced names
+	Names() []string
+	String() string
+}
+
+type identExpr string
+
+func (e identExpr) Eval(value func(name string) Value) Value {
+	return value(string(e))
+}
+
+func (e identExpr) Names() []string {
+	return []string{string(e)}
+}
+
+func (e identExpr) String() string {
+	return string(e)
+}
+
+type notExpr struct {
+	e Expr
+}
+
+func (e *notExpr) Eval(value func(name string) Value) Value {
+	return Not(e.e.Eval(value))
+}
+
+func (e *notExpr) Names() []string {
+	return e.e.Names()
+}
+
+func (e *notExpr) String() string {
+	return "!" + e.e.String()
+}
+
+type binaryExpr struct {
+	and  bool
+	l, r Expr
+}
+
+func (e *binaryExpr) Eval(value func(name string) Value) Value {
+	if e.and {
+		return And(e.l.Eval(value), e.r.Eval(value))
+	}
+	return Or(e.l.Eval(value), e.r.Eval(value))
+}
+
+func (e *binaryExpr) Names() []string {
+	return append(e.l.Names(), e.r.Names()...)
+}
+
+func (e *binaryExpr) String() string {
+	op := " || "
+	if e.and {
+		op = " && "
+	}
+	return "(" + e.l.String() + op + e.r.String() + ")"
+}
+
+type atLeastExpr struct {
+	n    int
+	args []Expr
+}
+
+func (e *atLeastExpr) Eval(value func(name string) Value) Value {
+	values := make([]Value, len(e.args))
+	for i := range e.args {
+		values[i] = e.args[i].Eval(value)
+	}
+	return AtLeast(e.n, values...)
+}
+
+func (e *atLeastExpr) Names() []string {
+	var names []string
+	for i := range e.args {
+		names = append(names, e.args[i].Names()...)
+	}
+	return names
+}
+
+func (e *atLeastExpr) String() string {
+	args := make([]string, len(e.args))
+	for i := range e.args {
+		args[i] = e.args[i].String()
+	}
+	return "atleast(" + strconv.Itoa(e.n) + ", " + strings.Join(args, ", ") + ")"
+}
+
+// expression tokens
+const (
+	tokEOF = iota
+	tokIdent
+	tokNumber
+	tokAnd
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	typ int
+	val string
+	pos int
+}
+
+func isIdentChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '-' || c == '.' || c == ':' || c == '@' || c == '/'
+}
+
+func lex(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokRParen, ")", i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{tokComma, ",", i})
+			i++
+		case c == '!':
+			tokens = append(tokens, token{tokNot, "!", i})
+			i++
+		case c == '&' || c == '|':
+			if i+1 >= len(s) || s[i+1] != c {
+				return nil, fmt.Errorf("expression: invalid operator at %d", i)
+			}
+			typ := tokAnd
+			if c == '|' {
+				typ = tokOr
+			}
+			tokens = append(tokens, token{typ, s[i : i+2], i})
+			i += 2
+		case c == '"':
+			end := strings.IndexByte(s[i+1:], '"')
+			if end == -1 {
+				return nil, fmt.Errorf("expression: unterminated quoted name at %d", i)
+			}
+			tokens = append(tokens, token{tokIdent, s[i+1 : i+1+end], i})
+			i += end + 2
+		case isIdentChar(c):
+			start := i
+			for i < len(s) && isIdentChar(s[i]) {
+				i++
+			}
+			val := s[start:i]
+			typ := tokIdent
+			if _, err := strconv.Atoi(val); err == nil {
+				typ = tokNumber
+			}
+			tokens = append(tokens, token{typ, val, start})
+		default:
+			return nil, fmt.Errorf("expression: unexpected '%c' at %d", c, i)
+		}
+	}
+	return append(tokens, token{tokEOF, "", len(s)}), nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.typ != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(typ int, what string) (token, error) {
+	t := p.next()
+	if t.typ != typ {
+		if t.typ == tokEOF {
+			return t, fmt.Errorf("expression: %s expected at end", what)
+		}
+		return t, fmt.Errorf("expression: %s expected at %d, got '%s'", what, t.pos, t.val)
+	}
+	return t, nil
+}
+
+// or = and { "||" and }
+func (p *parser) parseOr() (Expr, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().typ == tokOr {
+		p.next()
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{and: false, l: l, r: r}
+	}
+	return l, nil
+}
+
+// and = unary { "&&" unary }
+func (p *parser) parseAnd() (Expr, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().typ == tokAnd {
+		p.next()
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{and: true, l: l, r: r}
+	}
+	return l, nil
+}
+
+// unary = "!" unary | "(" or ")" | "atleast" "(" number { "," or } ")" | ident
+func (p *parser) parseUnary() (Expr, error) {
+	t := p.next()
+	switch t.typ {
+	case tokNot:
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{e: e}, nil
+	case tokLParen:
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err = p.expect(tokRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return e, nil
+	case tokIdent:
+		if t.val == "atleast" && p.peek().typ == tokLParen {
+			return p.parseAtLeast()
+		}
+		return identExpr(t.val), nil
+	case tokEOF:
+		return nil, fmt.Errorf("expression: operand expected at end")
+	default:
+		return nil, fmt.Errorf("expression: operand expected at %d, got '%s'", t.pos, t.val)
+	}
+}
+
+func (p *parser) parseAtLeast() (Expr, error) {
+	p.next()
+	t, err := p.expect(tokNumber, "number")
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(t.val)
+	e := &atLeastExpr{n: n}
+	for p.peek().typ == tokComma {
+		p.next()
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		e.args = append(e.args, arg)
+	}
+	if _, err = p.expect(tokRParen, "')'"); err != nil {
+		return nil, err
+	}
+	if len(e.args) == 0 || n < 1 || n > len(e.args) {
+		return nil, fmt.Errorf("expression: atleast(%d) with %d arguments", n, len(e.args))
+	}
+	return e, nil
+}
+
+// ParseExpr parse boolean expression with operators &&, ||, !, parentheses and atleast(N, expr, ...) function.
+// Names may be quoted ("name").
+func ParseExpr(s string) (Expr, error) {
+	tokens, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.typ != tokEOF {
+		return nil, fmt.Errorf("expression: unexpected '%s' at %d", t.val, t.pos)
+	}
+	return e, nil
+}
@@ -0,0 +1,81 @@
+package aggregator
+
+import (
+	"testing"
+)
+
+func TestKleene(t *testing.T) {
+	if And(True, Unknown) != Unknown || And(False, Unknown) != False || And(True, True) != True {
+		t.Errorf("And() invalid")
+	}
+	if Or(False, Unknown) != Unknown || Or(True, Unknown) != True || Or(False, False) != False {
+		t.Errorf("Or() invalid")
+	}
+	if Not(True) != False || Not(False) != True || Not(Unknown) != Unknown {
+		t.Errorf("Not() invalid")
+	}
+	if AtLeast(1, False, Unknown) != Unknown || AtLeast(1, False, True) != True || AtLeast(2, False, Unknown, True) != Unknown ||
+		AtLeast(2, False, False, True) != False {
+		t.Errorf("AtLeast() invalid")
+	}
+}
+
+func TestParseExpr(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    string
+		wantErr bool
+	}{
+		{expr: "relay", want: "relay"},
+		{expr: "carbon-c-relay && (storage1 || storage2)", want: "(carbon-c-relay && (storage1 || storage2))"},
+		{expr: "a || b && !c", want: "(a || (b && !c))"},
+		{expr: `"carbon-c-relay clusters" && relay.service`, want: "(carbon-c-relay clusters && relay.service)"},
+		{expr: "relay && atleast(2, s1, s2, s3 || s4)", want: "(relay && atleast(2, s1, s2, (s3 || s4)))"},
+		{expr: "", wantErr: true},
+		{expr: "a &&", wantErr: true},
+		{expr: "a & b", wantErr: true},
+		{expr: "(a || b", wantErr: true},
+		{expr: "a b", wantErr: true},
+		{expr: `"a`, wantErr: true},
+		{expr: "atleast(3, a, b)", wantErr: true},
+		{expr: "atleast(a, b)", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := ParseExpr(tt.expr)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseExpr() = %s, want error", e.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e.String() != tt.want {
+				t.Errorf("ParseExpr() = %s, want %s", e.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestExprEval(t *testing.T) {
+	e, err := ParseExpr("relay && atleast(1, s1, s2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		values map[string]Value
+		want   Value
+	}{
+		{map[string]Value{"relay": True, "s1": False, "s2": True}, True},
+		{map[string]Value{"relay": True, "s1": False, "s2": False}, False},
+		{map[string]Value{"relay": True, "s1": False, "s2": Unknown}, Unknown},
+		{map[string]Value{"relay": False, "s1": Unknown, "s2": Unknown}, False},
+	}
+	for _, tt := range tests {
+		if got := e.Eval(func(name string) Value { return tt.values[name] }); got != tt.want {
+			t.Errorf("Eval(%v) = %s, want %s", tt.values, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package aggregator
+
+// Value is three-valued (Kleene) logic value
+type Value int8
+
+const (
+	// False value (checker failed)
+	False Value = iota
+	// Unknown value (checker state not collected yet)
+	Unknown
+	// True value (checker succeeded)
+	True
+)
+
+func (v Value) String() string {
+	switch v {
+	case False:
+		return "false"
+	case True:
+		return "true"
+	default:
+		return "unknown"
+	}
+}
+
+// And return Kleene conjunction
+func And(a, b Value) Value {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Or return Kleene disjunction
+func Or(a, b Value) Value {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Not return Kleene negation
+func Not(a Value) Value {
+	return True - a
+}
+
+// AtLeast return true, if at least n values is true, false, if it's impossible (even for unknown values)
+func AtLeast(n int, values ...Value) Value {
+	var t, u int
+	for _, v := range values {
+		switch v {
+		case True:
+			t++
+		case Unknown:
+			u++
+		}
+	}
+	if t >= n {
+		return True
+	}
+	if t+u < n {
+		return False
+	}
+	return Unknown
+}
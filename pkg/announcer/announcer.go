@@ -0,0 +1,46 @@
+package announcer
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// Announcer announce/withdraw routes for prefixes (for anycast)
+type Announcer interface {
+	// Announce routes for prefixes
+	Announce(prefixes []*net.IPNet) error
+	// Withdraw routes for prefixes
+	Withdraw(prefixes []*net.IPNet) error
+	// Established return true if announcer ready for announce
+	Established() bool
+	// Close announcer (with routes withdraw, if possible)
+	Close() error
+}
+
+// Options announcer options
+type Options struct {
+	// Pipe for ExaBGP API (exabgp)
+	Pipe string
+	// NextHop for announced routes (self, if empty)
+	NextHop string
+
+	// Peer address (host:port) for BGP speaker (bgp)
+	Peer     string
+	LocalAS  uint32
+	PeerAS   uint32
+	RouterID string
+	HoldTime time.Duration
+}
+
+// New create announcer (exabgp or bgp)
+func New(typ string, opts Options) (Announcer, error) {
+	switch typ {
+	case "exabgp":
+		return NewExaBGP(opts.Pipe, opts.NextHop)
+	case "bgp":
+		return NewSpeaker(opts)
+	default:
+		return nil, fmt.Errorf("unknown announcer %s", typ)
+	}
+}
@@ -0,0 +1,280 @@
+package announcer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// BGP-4 (RFC 4271) messages
+const (
+	bgpHeaderLen = 19
+	bgpMaxLen    = 4096
+	bgpVersion   = 4
+
+	bgpMsgOpen         = 1
+	bgpMsgUpdate       = 2
+	bgpMsgNotification = 3
+	bgpMsgKeepalive    = 4
+
+	bgpCapMultiprotocol = 1
+	bgpCapAS4           = 65
+	bgpASTrans          = 23456
+
+	bgpAttrFlagOptional   = 0x80
+	bgpAttrFlagTransitive = 0x40
+	bgpAttrFlagExtLen     = 0x10
+
+	bgpAttrOrigin    = 1
+	bgpAttrASPath    = 2
+	bgpAttrNextHop   = 3
+	bgpAttrLocalPref = 5
+	bgpAttrMPReach   = 14
+	bgpAttrMPUnreach = 15
+
+	bgpOriginIGP     = 0
+	bgpASPathSegment = 2 // AS_SEQUENCE
+	bgpLocalPref     = 100
+
+	bgpAFIIPv4      = 1
+	bgpAFIIPv6      = 2
+	bgpSAFIUnicast  = 1
+	bgpErrCease     = 6
+	bgpErrAdminDown = 2
+)
+
+var errBGPMarker = errors.New("bgp: invalid message marker")
+
+// bgpOpen BGP OPEN message parameters
+type bgpOpen struct {
+	AS       uint32
+	HoldTime uint16
+	RouterID net.IP
+	AS4      bool
+}
+
+func bgpMessage(typ uint8, body []byte) []byte {
+	b := make([]byte, bgpHeaderLen, bgpHeaderLen+len(body))
+	for i := 0; i < 16; i++ {
+		b[i] = 0xff
+	}
+	binary.BigEndian.PutUint16(b[16:18], uint16(bgpHeaderLen+len(body)))
+	b[18] = typ
+	return append(b, body...)
+}
+
+func bgpOpenMessage(o bgpOpen) []byte {
+	caps := []byte{
+		bgpCapMultiprotocol, 4, 0, bgpAFIIPv4, 0, bgpSAFIUnicast,
+		bgpCapMultiprotocol, 4, 0, bgpAFIIPv6, 0, bgpSAFIUnicast,
+		bgpCapAS4, 4, 0, 0, 0, 0,
+	}
+	binary.BigEndian.PutUint32(caps[14:18], o.AS)
+
+	b := make([]byte, 10, 10+2+len(caps))
+	b[0] = bgpVersion
+	as := o.AS
+	if as > 0xffff {
+		as = bgpASTrans
+	}
+	binary.BigEndian.PutUint16(b[1:3], uint16(as))
+	binary.BigEndian.PutUint16(b[3:5], o.HoldTime)
+	copy(b[5:9], o.RouterID.To4())
+	// optional parameters: capabilities
+	b[9] = uint8(2 + len(caps))
+	b = append(b, 2, uint8(len(caps)))
+	b = append(b, caps...)
+
+	return bgpMessage(bgpMsgOpen, b)
+}
+
+func parseBGPOpen(b []byte) (bgpOpen, error) {
+	var o bgpOpen
+	if len(b) < 10 {
+		return o, fmt.Errorf("bgp: invalid open message length %d", len(b))
+	}
+	if b[0] != bgpVersion {
+		return o, fmt.Errorf("bgp: unsupported version %d", b[0])
+	}
+	o.AS = uint32(binary.BigEndian.Uint16(b[1:3]))
+	o.HoldTime = binary.BigEndian.Uint16(b[3:5])
+	o.RouterID = net.IP(append([]byte{}, b[5:9]...))
+	params := b[10:]
+	if len(params) < int(b[9]) {
+		return o, fmt.Errorf("bgp: invalid open parameters length %d", b[9])
+	}
+	params = params[:b[9]]
+	for len(params) >= 2 {
+		typ, l := params[0], int(params[1])
+		if len(params) < 2+l {
+			return o, fmt.Errorf("bgp: invalid open parameter length %d", l)
+		}
+		if typ == 2 {
+			caps := params[2 : 2+l]
+			for len(caps) >= 2 {
+				code, cl := caps[0], int(caps[1])
+				if len(caps) < 2+cl {
+					return o, fmt.Errorf("bgp: invalid capability length %d", cl)
+				}
+				if code == bgpCapAS4 && cl == 4 {
+					o.AS4 = true
+					o.AS = binary.BigEndian.Uint32(caps[2:6])
+				}
+				caps = caps[2+cl:]
+			}
+		}
+		params = params[2+l:]
+	}
+	return o, nil
+}
+
+func bgpNotificationMessage(code, subcode uint8) []byte {
+	return bgpMessage(bgpMsgNotification, []byte{code, subcode})
+}
+
+func bgpKeepaliveMessage() []byte {
+	return bgpMessage(bgpMsgKeepalive, nil)
+}
+
+// readBGPMessage read BGP message, return type and body
+func readBGPMessage(r io.Reader) (uint8, []byte, error) {
+	h := make([]byte, bgpHeaderLen)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return 0, nil, err
+	}
+	for i := 0; i < 16; i++ {
+		if h[i] != 0xff {
+			return 0, nil, errBGPMarker
+		}
+	}
+	l := int(binary.BigEndian.Uint16(h[16:18]))
+	if l < bgpHeaderLen || l > bgpMaxLen {
+		return 0, nil, fmt.Errorf("bgp: invalid message length %d", l)
+	}
+	body := make([]byte, l-bgpHeaderLen)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return h[18], body, nil
+}
+
+func appendPrefix(b []byte, p *net.IPNet) []byte {
+	ones, _ := p.Mask.Size()
+	ip := p.IP.Mask(p.Mask)
+	b = append(b, uint8(ones))
+	return append(b, ip[:(ones+7)/8]...)
+}
+
+func appendAttr(b []byte, flags, typ uint8, data []byte) []byte {
+	if len(data) > 255 {
+		b = append(b, flags|bgpAttrFlagExtLen, typ, uint8(len(data)>>8), uint8(len(data)))
+	} else {
+		b = append(b, flags, typ, uint8(len(data)))
+	}
+	return append(b, data...)
+}
+
+// bgpUpdate UPDATE message parameters for one address family
+type bgpUpdate struct {
+	LocalAS uint32
+	// IBGP session (local AS equal to peer AS)
+	IBGP bool
+	AS4  bool
+	// NextHop (IPv4 or IPv6 address, must be same family as prefixes)
+	NextHop net.IP
+}
+
+// announceMessage build UPDATE message for announce prefixes (all prefixes must be in one address family)
+func (u bgpUpdate) announceMessage(prefixes []*net.IPNet) []byte {
+	ipv6 := prefixes[0].IP.To4() == nil
+
+	attrs := appendAttr(nil, bgpAttrFlagTransitive, bgpAttrOrigin, []byte{bgpOriginIGP})
+	if u.IBGP {
+		attrs = appendAttr(attrs, bgpAttrFlagTransitive, bgpAttrASPath, nil)
+	} else {
+		var path []byte
+		if u.AS4 {
+			path = []byte{bgpASPathSegment, 1, 0, 0, 0, 0}
+			binary.BigEndian.PutUint32(path[2:], u.LocalAS)
+		} else {
+			as := u.LocalAS
+			if as > 0xffff {
+				as = bgpASTrans
+			}
+			path = []byte{bgpASPathSegment, 1, 0, 0}
+			binary.BigEndian.PutUint16(path[2:], uint16(as))
+		}
+		attrs = appendAttr(attrs, bgpAttrFlagTransitive, bgpAttrASPath, path)
+	}
+	if !ipv6 {
+		attrs = appendAttr(attrs, bgpAttrFlagTransitive, bgpAttrNextHop, u.NextHop.To4())
+	}
+	if u.IBGP {
+		lp := make([]byte, 4)
+		binary.BigEndian.PutUint32(lp, bgpLocalPref)
+		attrs = appendAttr(attrs, bgpAttrFlagTransitive, bgpAttrLocalPref, lp)
+	}
+
+	var nlri []byte
+	for _, p := range prefixes {
+		nlri = appendPrefix(nlri, p)
+	}
+
+	if ipv6 {
+		mp := []byte{0, bgpAFIIPv6, bgpSAFIUnicast, net.IPv6len}
+		mp = append(mp, u.NextHop.To16()...)
+		mp = append(mp, 0)
+		mp = append(mp, nlri...)
+		attrs = appendAttr(attrs, bgpAttrFlagOptional, bgpAttrMPReach, mp)
+		nlri = nil
+	}
+
+	b := make([]byte, 4, 4+len(attrs)+len(nlri))
+	// withdrawn routes length is zero
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(attrs)))
+	b = append(b, attrs...)
+	b = append(b, nlri...)
+
+	return bgpMessage(bgpMsgUpdate, b)
+}
+
+// withdrawMessage build UPDATE message for withdraw prefixes (all prefixes must be in one address family)
+func withdrawMessage(prefixes []*net.IPNet) []byte {
+	var withdrawn []byte
+	for _, p := range prefixes {
+		withdrawn = appendPrefix(withdrawn, p)
+	}
+
+	var b []byte
+	if prefixes[0].IP.To4() == nil {
+		mp := []byte{0, bgpAFIIPv6, bgpSAFIUnicast}
+		mp = append(mp, withdrawn...)
+		attrs := appendAttr(nil, bgpAttrFlagOptional, bgpAttrMPUnreach, mp)
+		b = make([]byte, 4, 4+len(attrs))
+		binary.BigEndian.PutUint16(b[2:4], uint16(len(attrs)))
+		b = append(b, attrs...)
+	} else {
+		b = make([]byte, 2, 4+len(withdrawn))
+		binary.BigEndian.PutUint16(b[0:2], uint16(len(withdrawn)))
+		b = append(b, withdrawn...)
+		// path attributes length is zero
+		b = append(b, 0, 0)
+	}
+
+	return bgpMessage(bgpMsgUpdate, b)
+}
+
+// splitFamily split prefixes to IPv4 and IPv6
+func splitFamily(prefixes []*net.IPNet) ([]*net.IPNet, []*net.IPNet) {
+	var v4, v6 []*net.IPNet
+	for _, p := range prefixes {
+		if p.IP.To4() == nil {
+			v6 = append(v6, p)
+		} else {
+			v4 = append(v4, p)
+		}
+	}
+	return v4, v6
+}
@@ -0,0 +1,81 @@
+package announcer
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// ExaBGP announcer with ExaBGP API (commands written to named pipe)
+type ExaBGP struct {
+	pipe    string
+	nextHop string
+
+	lock sync.Mutex
+}
+
+// NewExaBGP create ExaBGP announcer
+func NewExaBGP(pipe string, nextHop string) (*ExaBGP, error) {
+	if len(pipe) == 0 {
+		return nil, fmt.Errorf("exabgp pipe empthy")
+	}
+	if len(nextHop) == 0 {
+		nextHop = "self"
+	} else if net.ParseIP(nextHop) == nil {
+		return nil, fmt.Errorf("exabgp next hop %s invalid", nextHop)
+	}
+	return &ExaBGP{pipe: pipe, nextHop: nextHop}, nil
+}
+
+func (e *ExaBGP) write(cmd string, prefixes []*net.IPNet) error {
+	if len(prefixes) == 0 {
+		return nil
+	}
+	var sb strings.Builder
+	for _, p := range prefixes {
+		sb.WriteString(cmd)
+		sb.WriteString(" route ")
+		sb.WriteString((&net.IPNet{IP: p.IP.Mask(p.Mask), Mask: p.Mask}).String())
+		sb.WriteString(" next-hop ")
+		sb.WriteString(e.nextHop)
+		sb.WriteString("\n")
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	// non-blocking open fails with ENXIO, if ExaBGP not read the pipe
+	f, err := os.OpenFile(e.pipe, os.O_WRONLY|os.O_APPEND|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(sb.String())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Announce routes for prefixes
+func (e *ExaBGP) Announce(prefixes []*net.IPNet) error {
+	return e.write("announce", prefixes)
+}
+
+// Withdraw routes for prefixes
+func (e *ExaBGP) Withdraw(prefixes []*net.IPNet) error {
+	return e.write("withdraw", prefixes)
+}
+
+// Established return true if pipe exist
+func (e *ExaBGP) Established() bool {
+	_, err := os.Stat(e.pipe)
+	return err == nil
+}
+
+// Close announcer (routes not withdrawn, ExaBGP is a separate process)
+func (e *ExaBGP) Close() error {
+	return nil
+}
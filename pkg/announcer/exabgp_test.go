@@ -0,0 +1,58 @@
+package announcer
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+)
+
+func TestExaBGP(t *testing.T) {
+	dir, err := ioutil.TempDir("", "relaymon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pipe := path.Join(dir, "exabgp.in")
+
+	e, err := NewExaBGP(pipe, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Established() {
+		t.Errorf("Established() must be false for not exist pipe")
+	}
+
+	if err = syscall.Mkfifo(pipe, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// no reader
+	if err = e.Announce(parseCIDRs(t, "10.0.0.1/32")); err == nil {
+		t.Errorf("Announce() must fail without pipe reader")
+	}
+
+	r, err := os.OpenFile(pipe, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if err = e.Announce(parseCIDRs(t, "10.0.0.1/32", "fd00::1/128")); err != nil {
+		t.Fatal(err)
+	}
+	if err = e.Withdraw(parseCIDRs(t, "10.0.0.1/32")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1024)
+	n, err := r.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "announce route 10.0.0.1/32 next-hop self\n" +
+		"announce route fd00::1/128 next-hop self\n" +
+		"withdraw route 10.0.0.1/32 next-hop self\n"
+	if string(buf[:n]) != want {
+		t.Errorf("ExaBGP commands got\n%s\nwant\n%s", string(buf[:n]), want)
+	}
+}
@@ -0,0 +1,347 @@
+package announcer
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultHoldTime = 90 * time.Second
+	connectRetry    = 5 * time.Second
+	bgpPort         = "179"
+)
+
+// Speaker minimal BGP speaker (single peer, only announce routes, received routes are ignored).
+// Announced routes are resent on session reestablish and implicitly withdrawn on session close.
+type Speaker struct {
+	peer     string
+	localAS  uint32
+	peerAS   uint32
+	routerID net.IP
+	holdTime time.Duration
+	nextHop  net.IP
+
+	lock        sync.Mutex
+	conn        net.Conn
+	update      bgpUpdate
+	established bool
+	announced   map[string]*net.IPNet
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewSpeaker create and start BGP speaker
+func NewSpeaker(opts Options) (*Speaker, error) {
+	if len(opts.Peer) == 0 {
+		return nil, fmt.Errorf("bgp peer empthy")
+	}
+	peer := opts.Peer
+	if _, _, err := net.SplitHostPort(peer); err != nil {
+		peer = net.JoinHostPort(peer, bgpPort)
+	}
+	if opts.LocalAS == 0 {
+		return nil, fmt.Errorf("bgp local as empthy")
+	}
+	routerID := net.ParseIP(opts.RouterID).To4()
+	if routerID == nil {
+		return nil, fmt.Errorf("bgp router id %s invalid", opts.RouterID)
+	}
+	var nextHop net.IP
+	if len(opts.NextHop) > 0 && opts.NextHop != "self" {
+		if nextHop = net.ParseIP(opts.NextHop); nextHop == nil {
+			return nil, fmt.Errorf("bgp next hop %s invalid", opts.NextHop)
+		}
+	}
+	holdTime := opts.HoldTime
+	if holdTime == 0 {
+		holdTime = defaultHoldTime
+	} else if holdTime < 3*time.Second {
+		return nil, fmt.Errorf("bgp hold time must be at least 3s")
+	}
+	peerAS := opts.PeerAS
+	if peerAS == 0 {
+		peerAS = opts.LocalAS
+	}
+
+	s := &Speaker{
+		peer:      peer,
+		localAS:   opts.LocalAS,
+		peerAS:    peerAS,
+		routerID:  routerID,
+		holdTime:  holdTime,
+		nextHop:   nextHop,
+		announced: make(map[string]*net.IPNet),
+		stop:      make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.run()
+
+	return s, nil
+}
+
+func (s *Speaker) run() {
+	defer s.wg.Done()
+	for {
+		err := s.session()
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		log.Error().Str("announcer", "bgp").Str("peer", s.peer).Str("error", err.Error()).Msg("session closed")
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(connectRetry):
+		}
+	}
+}
+
+// write message to session connection (lock must be held)
+func (s *Speaker) write(msg []byte) error {
+	s.conn.SetWriteDeadline(time.Now().Add(s.holdTime))
+	_, err := s.conn.Write(msg)
+	return err
+}
+
+// session connect and process BGP session until error
+func (s *Speaker) session() error {
+	conn, err := net.DialTimeout("tcp", s.peer, connectRetry)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	holdTime := uint16(s.holdTime / time.Second)
+
+	s.lock.Lock()
+	s.conn = conn
+	// may be closed before conn is set
+	select {
+	case <-s.stop:
+		err = fmt.Errorf("bgp: speaker closed")
+	default:
+		err = s.write(bgpOpenMessage(bgpOpen{AS: s.localAS, HoldTime: holdTime, RouterID: s.routerID}))
+	}
+	s.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(s.holdTime))
+	typ, body, err := readBGPMessage(conn)
+	if err != nil {
+		return err
+	}
+	if typ == bgpMsgNotification {
+		return notificationError(body)
+	} else if typ != bgpMsgOpen {
+		return fmt.Errorf("bgp: unexpected message type %d, want open", typ)
+	}
+	open, err := parseBGPOpen(body)
+	if err != nil {
+		return err
+	}
+	if open.AS != s.peerAS {
+		s.lock.Lock()
+		s.write(bgpNotificationMessage(2, 2)) // OPEN Message Error, Bad Peer AS
+		s.lock.Unlock()
+		return fmt.Errorf("bgp: peer as %d, want %d", open.AS, s.peerAS)
+	}
+	if open.HoldTime < holdTime {
+		holdTime = open.HoldTime
+	}
+
+	nextHop := s.nextHop
+	if nextHop == nil {
+		nextHop = conn.LocalAddr().(*net.TCPAddr).IP
+	}
+
+	s.lock.Lock()
+	err = s.write(bgpKeepaliveMessage())
+	s.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// OpenConfirm: session established after peer KEEPALIVE
+	conn.SetReadDeadline(time.Now().Add(s.holdTime))
+	typ, body, err = readBGPMessage(conn)
+	if err != nil {
+		return err
+	}
+	if typ == bgpMsgNotification {
+		return notificationError(body)
+	} else if typ != bgpMsgKeepalive {
+		return fmt.Errorf("bgp: unexpected message type %d, want keepalive", typ)
+	}
+
+	s.lock.Lock()
+	s.update = bgpUpdate{LocalAS: s.localAS, IBGP: s.localAS == s.peerAS, AS4: open.AS4, NextHop: nextHop}
+	s.established = true
+	prefixes := make([]*net.IPNet, 0, len(s.announced))
+	for _, p := range s.announced {
+		prefixes = append(prefixes, p)
+	}
+	err = s.sendAnnounce(prefixes)
+	s.lock.Unlock()
+	if err != nil {
+		if _, ok := err.(nextHopError); !ok {
+			return err
+		}
+		log.Error().Str("announcer", "bgp").Str("peer", s.peer).Str("error", err.Error()).Msg("announce")
+	}
+	log.Info().Str("announcer", "bgp").Str("peer", s.peer).Msg("session established")
+
+	defer func() {
+		s.lock.Lock()
+		s.established = false
+		s.lock.Unlock()
+	}()
+
+	// keepalive sender
+	done := make(chan struct{})
+	defer close(done)
+	if holdTime > 0 {
+		keepalive := time.Duration(holdTime) * time.Second / 3
+		go func() {
+			t := time.NewTicker(keepalive)
+			defer t.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-t.C:
+					s.lock.Lock()
+					err := s.write(bgpKeepaliveMessage())
+					s.lock.Unlock()
+					if err != nil {
+						conn.Close()
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	for {
+		if holdTime > 0 {
+			conn.SetReadDeadline(time.Now().Add(time.Duration(holdTime) * time.Second))
+		} else {
+			conn.SetReadDeadline(time.Time{})
+		}
+		typ, body, err := readBGPMessage(conn)
+		if err != nil {
+			return err
+		}
+		if typ == bgpMsgNotification {
+			return notificationError(body)
+		}
+	}
+}
+
+type nextHopError string
+
+func (e nextHopError) Error() string {
+	return "bgp: next hop for " + string(e) + " prefixes not set"
+}
+
+func notificationError(body []byte) error {
+	if len(body) < 2 {
+		return fmt.Errorf("bgp: notification received")
+	}
+	return fmt.Errorf("bgp: notification received, code %d, subcode %d", body[0], body[1])
+}
+
+// sendAnnounce send UPDATE messages (lock must be held)
+func (s *Speaker) sendAnnounce(prefixes []*net.IPNet) error {
+	var err error
+	v4, v6 := splitFamily(prefixes)
+	if len(v4) > 0 {
+		if s.update.NextHop.To4() == nil {
+			err = nextHopError("IPv4")
+		} else if werr := s.write(s.update.announceMessage(v4)); werr != nil {
+			return werr
+		}
+	}
+	if len(v6) > 0 {
+		if s.update.NextHop.To4() != nil {
+			err = nextHopError("IPv6")
+		} else if werr := s.write(s.update.announceMessage(v6)); werr != nil {
+			return werr
+		}
+	}
+	return err
+}
+
+// sendWithdraw send UPDATE messages (lock must be held)
+func (s *Speaker) sendWithdraw(prefixes []*net.IPNet) error {
+	v4, v6 := splitFamily(prefixes)
+	if len(v4) > 0 {
+		if err := s.write(withdrawMessage(v4)); err != nil {
+			return err
+		}
+	}
+	if len(v6) > 0 {
+		if err := s.write(withdrawMessage(v6)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Announce routes for prefixes (sent after session established, if not yet)
+func (s *Speaker) Announce(prefixes []*net.IPNet) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, p := range prefixes {
+		s.announced[p.String()] = p
+	}
+	if !s.established || len(prefixes) == 0 {
+		return nil
+	}
+	return s.sendAnnounce(prefixes)
+}
+
+// Withdraw routes for prefixes
+func (s *Speaker) Withdraw(prefixes []*net.IPNet) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, p := range prefixes {
+		delete(s.announced, p.String())
+	}
+	if !s.established || len(prefixes) == 0 {
+		return nil
+	}
+	return s.sendWithdraw(prefixes)
+}
+
+// Established return true if BGP session established
+func (s *Speaker) Established() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.established
+}
+
+// Close session (announced routes are withdrawn by peer)
+func (s *Speaker) Close() error {
+	close(s.stop)
+	s.lock.Lock()
+	if s.conn != nil {
+		if s.established {
+			s.write(bgpNotificationMessage(bgpErrCease, bgpErrAdminDown))
+		}
+		s.conn.Close()
+	}
+	s.lock.Unlock()
+	s.wg.Wait()
+	return nil
+}
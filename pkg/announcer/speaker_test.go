@@ -0,0 +1,273 @@
+package announcer
+
+import (
+	"encoding/binary"
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func parseCIDRs(t *testing.T, cidrs ...string) []*net.IPNet {
+	prefixes := make([]*net.IPNet, len(cidrs))
+	for i := range cidrs {
+		ip, p, err := net.ParseCIDR(cidrs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.IP = ip
+		prefixes[i] = p
+	}
+	return prefixes
+}
+
+func decodePrefixes(b []byte, ipLen int) []string {
+	prefixes := make([]string, 0)
+	for len(b) > 0 {
+		ones := int(b[0])
+		n := (ones + 7) / 8
+		ip := make(net.IP, ipLen)
+		copy(ip, b[1:1+n])
+		prefixes = append(prefixes, (&net.IPNet{IP: ip, Mask: net.CIDRMask(ones, ipLen*8)}).String())
+		b = b[1+n:]
+	}
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+// peerUpdate decoded UPDATE message
+type peerUpdate struct {
+	Announced []string
+	Withdrawn []string
+	NextHop   string
+	ASPath    []byte
+}
+
+func decodeUpdate(t *testing.T, body []byte) peerUpdate {
+	var u peerUpdate
+	wl := int(binary.BigEndian.Uint16(body[0:2]))
+	u.Withdrawn = decodePrefixes(body[2:2+wl], 4)
+	body = body[2+wl:]
+	al := int(binary.BigEndian.Uint16(body[0:2]))
+	attrs := body[2 : 2+al]
+	u.Announced = decodePrefixes(body[2+al:], 4)
+	for len(attrs) > 0 {
+		flags, typ := attrs[0], attrs[1]
+		var l, h int
+		if flags&bgpAttrFlagExtLen != 0 {
+			l, h = int(binary.BigEndian.Uint16(attrs[2:4])), 4
+		} else {
+			l, h = int(attrs[2]), 3
+		}
+		data := attrs[h : h+l]
+		switch typ {
+		case bgpAttrASPath:
+			u.ASPath = data
+		case bgpAttrNextHop:
+			u.NextHop = net.IP(data).String()
+		case bgpAttrMPReach:
+			nhl := int(data[3])
+			u.NextHop = net.IP(data[4 : 4+nhl]).String()
+			u.Announced = decodePrefixes(data[4+nhl+1:], 16)
+		case bgpAttrMPUnreach:
+			u.Withdrawn = decodePrefixes(data[3:], 16)
+		}
+		attrs = attrs[h+l:]
+	}
+	return u
+}
+
+// standInPeer accept one BGP session and send received updates to channel
+func standInPeer(t *testing.T, ln net.Listener, as uint32, updates chan<- peerUpdate) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	typ, body, err := readBGPMessage(conn)
+	if err != nil || typ != bgpMsgOpen {
+		t.Errorf("peer: open not received: %v, type %d", err, typ)
+		return
+	}
+	if _, err := parseBGPOpen(body); err != nil {
+		t.Errorf("peer: %v", err)
+		return
+	}
+	conn.Write(bgpOpenMessage(bgpOpen{AS: as, HoldTime: 30, RouterID: net.ParseIP("127.0.0.2")}))
+	conn.Write(bgpKeepaliveMessage())
+	for {
+		typ, body, err := readBGPMessage(conn)
+		if err != nil {
+			close(updates)
+			return
+		}
+		switch typ {
+		case bgpMsgUpdate:
+			updates <- decodeUpdate(t, body)
+		case bgpMsgNotification:
+			close(updates)
+			return
+		}
+	}
+}
+
+func waitUpdate(t *testing.T, updates <-chan peerUpdate) peerUpdate {
+	select {
+	case u := <-updates:
+		return u
+	case <-time.After(5 * time.Second):
+		t.Fatal("update not received")
+	}
+	return peerUpdate{}
+}
+
+func TestSpeaker(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	updates := make(chan peerUpdate, 10)
+	go standInPeer(t, ln, 65001, updates)
+
+	s, err := NewSpeaker(Options{
+		Peer: ln.Addr().String(), LocalAS: 65000, PeerAS: 65001, RouterID: "127.0.0.1",
+		NextHop: "192.168.1.1", HoldTime: 30 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// announced before session established
+	if err = s.Announce(parseCIDRs(t, "10.0.0.1/32", "10.1.0.0/16")); err != nil {
+		t.Fatal(err)
+	}
+	u := waitUpdate(t, updates)
+	want := peerUpdate{
+		Announced: []string{"10.0.0.1/32", "10.1.0.0/16"}, Withdrawn: []string{},
+		NextHop: "192.168.1.1", ASPath: []byte{bgpASPathSegment, 1, 0, 0, 0xfd, 0xe8},
+	}
+	if !reflect.DeepEqual(u, want) {
+		t.Errorf("announce got\n%+v\nwant\n%+v", u, want)
+	}
+	if !s.Established() {
+		t.Errorf("session not established")
+	}
+
+	if err = s.Withdraw(parseCIDRs(t, "10.0.0.1/32")); err != nil {
+		t.Fatal(err)
+	}
+	u = waitUpdate(t, updates)
+	want = peerUpdate{Announced: []string{}, Withdrawn: []string{"10.0.0.1/32"}}
+	if !reflect.DeepEqual(u, want) {
+		t.Errorf("withdraw got\n%+v\nwant\n%+v", u, want)
+	}
+
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-updates; ok {
+		t.Errorf("session not closed")
+	}
+}
+
+func TestSpeakerOpenConfirm(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	s, err := NewSpeaker(Options{Peer: ln.Addr().String(), LocalAS: 65000, RouterID: "127.0.0.1", NextHop: "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err = s.Announce(parseCIDRs(t, "10.0.0.1/32")); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if typ, _, err := readBGPMessage(conn); err != nil || typ != bgpMsgOpen {
+		t.Fatalf("open not received: %v, type %d", err, typ)
+	}
+	conn.Write(bgpOpenMessage(bgpOpen{AS: 65000, HoldTime: 30, RouterID: net.ParseIP("127.0.0.2")}))
+	if typ, _, err := readBGPMessage(conn); err != nil || typ != bgpMsgKeepalive {
+		t.Fatalf("keepalive not received: %v, type %d", err, typ)
+	}
+	// peer keepalive not received yet
+	time.Sleep(100 * time.Millisecond)
+	if s.Established() {
+		t.Errorf("session established before peer keepalive")
+	}
+
+	conn.Write(bgpKeepaliveMessage())
+	typ, body, err := readBGPMessage(conn)
+	if err != nil || typ != bgpMsgUpdate {
+		t.Fatalf("update not received: %v, type %d", err, typ)
+	}
+	if u := decodeUpdate(t, body); !reflect.DeepEqual(u.Announced, []string{"10.0.0.1/32"}) {
+		t.Errorf("announce got %+v", u)
+	}
+	if !s.Established() {
+		t.Errorf("session not established")
+	}
+}
+
+func TestSpeakerIPv6(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	updates := make(chan peerUpdate, 10)
+	go standInPeer(t, ln, 65000, updates)
+
+	s, err := NewSpeaker(Options{Peer: ln.Addr().String(), LocalAS: 65000, RouterID: "127.0.0.1", NextHop: "fd00::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err = s.Announce(parseCIDRs(t, "fd00:1::1/128")); err != nil {
+		t.Fatal(err)
+	}
+	u := waitUpdate(t, updates)
+	// IBGP: empty AS_PATH
+	want := peerUpdate{Announced: []string{"fd00:1::1/128"}, Withdrawn: []string{}, NextHop: "fd00::1", ASPath: []byte{}}
+	if !reflect.DeepEqual(u, want) {
+		t.Errorf("announce got\n%+v\nwant\n%+v", u, want)
+	}
+
+	if err = s.Withdraw(parseCIDRs(t, "fd00:1::1/128")); err != nil {
+		t.Fatal(err)
+	}
+	u = waitUpdate(t, updates)
+	want = peerUpdate{Announced: []string{}, Withdrawn: []string{"fd00:1::1/128"}}
+	if !reflect.DeepEqual(u, want) {
+		t.Errorf("withdraw got\n%+v\nwant\n%+v", u, want)
+	}
+}
+
+func Test_parseBGPOpen(t *testing.T) {
+	msg := bgpOpenMessage(bgpOpen{AS: 4200000000, HoldTime: 90, RouterID: net.ParseIP("10.0.0.1")})
+	o, err := parseBGPOpen(msg[bgpHeaderLen:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.AS != 4200000000 || !o.AS4 || o.HoldTime != 90 || !o.RouterID.Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("parseBGPOpen() = %+v", o)
+	}
+	if as := binary.BigEndian.Uint16(msg[bgpHeaderLen+1:]); as != bgpASTrans {
+		t.Errorf("bgpOpenMessage() 2-octet as = %d, want %d", as, bgpASTrans)
+	}
+}
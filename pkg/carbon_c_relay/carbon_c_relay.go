@@ -1,136 +1,50 @@
 package carboncrelay
 
 import (
-	"bufio"
-	"fmt"
-	"os"
-	"strings"
 	"time"
 
 	"github.com/msaf1980/relaymon/pkg/carbonnetwork"
 )
 
-var (
-	skipList1 = map[string]bool{"forward": true, "any_of": true, "failover": true, "useall": true,
-		"carbon_ch": true, "fnv1a_ch": true, "jump_fnv1a_ch": true, "lb": true,
-		"dynamic": true}
-	skipList2 = map[string]bool{"replication": true, "connections": true, "ttl": true, "ttl_jitter": true}
-	stopList  = map[string]bool{"proto": true, "type": true, "transport": true}
-)
+// NetworkClusters return clusters for network check (file clusters are skipped) and names of clusters, not referenced by routes.
+// If skipUnreferenced, unreferenced clusters not returned for check.
+func (c *Config) NetworkClusters(required []string, testPrefix string, timeout time.Duration, skipUnreferenced bool) ([]*carbonnetwork.Cluster, []string) {
+	clusters := make([]*carbonnetwork.Cluster, 0, len(c.Clusters))
+	unreferenced := make([]string, 0)
 
-func clusterEndpoints(fields []string, required map[string]bool, testPrefix string, timeout time.Duration) (*carbonnetwork.Cluster, error) {
-	if len(fields) < 4 {
-		return nil, fmt.Errorf("incomplete cluster")
+	r := map[string]bool{}
+	for i := range required {
+		r[required[i]] = true
 	}
-	var err error
 
-	name := fields[1]
-	_, ok := required[name]
-	cluster := carbonnetwork.NewCluster(name, ok, testPrefix, timeout)
-	i := 2
-	for i < len(fields) {
-		if fields[i] == "file" {
-			return nil, nil
-		}
-		_, ok := skipList1[fields[i]]
-		if ok {
-			i++
-			continue
-		}
+	referenced, dynamic := c.Referenced()
 
-		_, ok = skipList2[fields[i]]
-		if ok {
-			i += 2
+	for _, def := range c.Clusters {
+		if def.Type == "file" {
 			continue
 		}
-
-		_, ok = stopList[fields[i]]
-		if ok {
-			break
+		if !dynamic && !referenced[def.Name] {
+			unreferenced = append(unreferenced, def.Name)
+			if skipUnreferenced && !r[def.Name] {
+				continue
+			}
 		}
-
-		endpoint := strings.Split(fields[i], "=")
-		endpoint = strings.Split(endpoint[0], ":")
-		if len(endpoint) == 1 {
-			cluster.Append(endpoint[0] + ":2003")
-		} else {
-			cluster.Append(endpoint[0] + ":" + endpoint[1])
+		cluster := carbonnetwork.NewCluster(def.Name, r[def.Name], testPrefix, timeout)
+		for i := range def.Servers {
+			cluster.AppendProto(def.Servers[i].Address(), def.Servers[i].Proto)
 		}
-
-		i++
-	}
-
-	if len(cluster.Endpoints) == 0 {
-		err = fmt.Errorf("empthy cluster %s", cluster.Name)
+		clusters = append(clusters, cluster)
 	}
 
-	return cluster, err
+	return clusters, unreferenced
 }
 
 // Clusters parse config and return clusters
 func Clusters(config string, required []string, testPrefix string, timeout time.Duration, running *int32) ([]*carbonnetwork.Cluster, error) {
-	clusters := make([]*carbonnetwork.Cluster, 0, 2)
-	file, err := os.Open(config)
+	c, err := Parse(config)
 	if err != nil {
-		return clusters, err
+		return []*carbonnetwork.Cluster{}, err
 	}
-
-	defer file.Close()
-
-	r := map[string]bool{}
-	for i := range required {
-		r[required[i]] = true
-	}
-
-	reader := bufio.NewReader(file)
-
-	found := false
-	clusterFields := make([]string, 0)
-	for {
-		line, err := reader.ReadString('\n')
-		line = strings.Split(line, "#")[0]
-		line = strings.TrimRight(line, "\n")
-		if found {
-			fields := strings.Split(line, " ")
-			for i := range fields {
-				if fields[i] == "" {
-					continue
-				} else if fields[i] == ";" {
-					found = false
-					break
-				}
-				clusterFields = append(clusterFields, fields[i])
-			}
-		} else if strings.HasPrefix(line, "cluster ") {
-			if len(clusterFields) > 0 {
-				cluster, err := clusterEndpoints(clusterFields, r, testPrefix, timeout)
-				if cluster != nil && err == nil {
-					clusters = append(clusters, cluster)
-				}
-				clusterFields = make([]string, 0)
-			}
-			fields := strings.Split(line, " ")
-			for i := range fields {
-				if fields[i] == "" {
-					continue
-				} else if fields[i] == ";" {
-					break
-				}
-				clusterFields = append(clusterFields, fields[i])
-			}
-			found = true
-		}
-		if err != nil {
-			break
-		}
-	}
-
-	if len(clusterFields) > 0 {
-		cluster, err := clusterEndpoints(clusterFields, r, testPrefix, timeout)
-		if cluster != nil && err == nil {
-			clusters = append(clusters, cluster)
-		}
-	}
-
+	clusters, _ := c.NetworkClusters(required, testPrefix, timeout, false)
 	return clusters, nil
 }
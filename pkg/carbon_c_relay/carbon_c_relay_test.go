@@ -22,11 +22,15 @@ func TestClusters(t *testing.T) {
 	}{
 		{
 			"carbon-c-relay.conf",
-			[]string{"test2"},
+			[]string{"test2", "test3"},
 			[]carbonnetwork.Cluster{
-				{Name: "test1", Endpoints: []string{"test1:2003", "test2:2005"}, Required: false},
-				{Name: "test2", Endpoints: []string{"test3:2003", "test4:2005"}, Required: true},
-				{Name: "test3", Endpoints: []string{"test6:2008", "test5:2003"}, Required: true},
+				{Name: "test1", Endpoints: []string{"test1:2003", "test2:2005"}, Protos: []string{"tcp", "tcp"}, Required: false},
+				{Name: "test2", Endpoints: []string{"test3:2003", "test4:2005"}, Protos: []string{"tcp", "tcp"}, Required: true},
+				{Name: "test3", Endpoints: []string{"test6:2008", "test5:2003"}, Protos: []string{"tcp", "tcp"}, Required: true},
+				{
+					Name: "test4", Endpoints: []string{"test7:2003", "test8:2003", "test9:2003"},
+					Protos: []string{"udp", "tcp", "udp"}, Required: false,
+				},
 			},
 		},
 	}
@@ -45,6 +49,9 @@ func TestClusters(t *testing.T) {
 					if !reflect.DeepEqual(got[i].Endpoints, tt.want[i].Endpoints) {
 						t.Errorf("Clusters()[%d].Endpoints got = %v, want %v", i, got[i].Endpoints, tt.want[i].Endpoints)
 					}
+					if !reflect.DeepEqual(got[i].Protos, tt.want[i].Protos) {
+						t.Errorf("Clusters()[%d].Protos got = %v, want %v", i, got[i].Protos, tt.want[i].Protos)
+					}
 					if got[i].Required != tt.want[i].Required {
 						t.Errorf("Clusters()[%d].Required got = %s, want %s", i, strconv.FormatBool(got[i].Required), strconv.FormatBool(tt.want[i].Required))
 					}
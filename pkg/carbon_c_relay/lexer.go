@@ -0,0 +1,105 @@
+package carboncrelay
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Pos describe position in config file
+type Pos struct {
+	File string
+	Line int
+}
+
+// String get position string representation (file:line)
+func (p Pos) String() string {
+	return fmt.Sprintf("%s:%d", p.File, p.Line)
+}
+
+// ParseError config parse error with position
+type ParseError struct {
+	Pos Pos
+	Msg string
+}
+
+// Error get error description
+func (e *ParseError) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+func newParseError(pos Pos, format string, a ...interface{}) *ParseError {
+	return &ParseError{Pos: pos, Msg: fmt.Sprintf(format, a...)}
+}
+
+type token struct {
+	Pos    Pos
+	Value  string
+	Quoted bool
+}
+
+// is check token is unquoted keyword
+func (t *token) is(keyword string) bool {
+	return !t.Quoted && t.Value == keyword
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// tokenize split config to tokens (';' is a separate token, '#' at token start begin comment, "..." is a quoted string)
+func tokenize(file string, data string) ([]token, error) {
+	tokens := make([]token, 0, len(data)/4)
+	line := 1
+	i := 0
+	for i < len(data) {
+		c := data[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case isSpace(c):
+			i++
+		case c == '#':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+		case c == ';':
+			tokens = append(tokens, token{Pos: Pos{file, line}, Value: ";"})
+			i++
+		case c == '"':
+			start := line
+			var sb strings.Builder
+			i++
+			closed := false
+			for i < len(data) {
+				if data[i] == '\\' && i+1 < len(data) {
+					if data[i+1] != '"' && data[i+1] != '\\' {
+						sb.WriteByte(data[i])
+					}
+					sb.WriteByte(data[i+1])
+					i += 2
+					continue
+				} else if data[i] == '"' {
+					closed = true
+					i++
+					break
+				} else if data[i] == '\n' {
+					line++
+				}
+				sb.WriteByte(data[i])
+				i++
+			}
+			if !closed {
+				return nil, newParseError(Pos{file, start}, "unterminated quoted string")
+			}
+			tokens = append(tokens, token{Pos: Pos{file, start}, Value: sb.String(), Quoted: true})
+		default:
+			start := i
+			for i < len(data) && !isSpace(data[i]) && data[i] != ';' {
+				i++
+			}
+			tokens = append(tokens, token{Pos: Pos{file, line}, Value: data[start:i]})
+		}
+	}
+	return tokens, nil
+}
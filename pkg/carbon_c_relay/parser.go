@@ -0,0 +1,403 @@
+package carboncrelay
+
+import (
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	clusterTypes = map[string]bool{"forward": true, "any_of": true, "failover": true,
+		"carbon_ch": true, "fnv1a_ch": true, "jump_fnv1a_ch": true, "lb": true, "file": true}
+	// cluster options without argument
+	clusterOpts1 = map[string]bool{"useall": true, "dynamic": true}
+	// cluster options with argument
+	clusterOpts2 = map[string]bool{"replication": true, "connections": true, "ttl": true, "ttl_jitter": true}
+)
+
+// Server describe cluster member
+type Server struct {
+	Pos       Pos
+	Host      string
+	Port      string
+	Instance  string
+	Proto     string
+	Type      string
+	Transport string
+}
+
+// Address get server address (host:port)
+func (s *Server) Address() string {
+	return net.JoinHostPort(s.Host, s.Port)
+}
+
+// ClusterDef describe cluster statement
+type ClusterDef struct {
+	Pos     Pos
+	Name    string
+	Type    string
+	Servers []Server
+}
+
+// Route describe statement with destinations (match, aggregate or statistics)
+type Route struct {
+	Pos          Pos
+	Statement    string
+	Expressions  []string
+	Destinations []string
+	// Dynamic route (match ... route using), destination clusters unknown
+	Dynamic bool
+	Stop    bool
+}
+
+// Rewrite describe rewrite statement
+type Rewrite struct {
+	Pos         Pos
+	Expression  string
+	Replacement string
+}
+
+// Listen describe listen statement
+type Listen struct {
+	Pos  Pos
+	Args []string
+}
+
+// Config parsed carbon-c-relay config
+type Config struct {
+	// Files is a list of parsed files (main config and includes)
+	Files    []string
+	Clusters []*ClusterDef
+	Routes   []*Route
+	Rewrites []*Rewrite
+	Listens  []*Listen
+	// IncludePatterns is a list of include glob patterns (for detect new included files)
+	IncludePatterns []string
+
+	// files in include chain (for cycle detection)
+	includes []string
+	// already parsed files (included more than once are skipped)
+	parsed map[string]bool
+}
+
+// Parse parse carbon-c-relay config (with includes)
+func Parse(config string) (*Config, error) {
+	c := &Config{parsed: make(map[string]bool)}
+	if err := c.parseFile(config); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Config) parseFile(config string) error {
+	absPath, err := filepath.Abs(config)
+	if err != nil {
+		return err
+	}
+	for i := range c.includes {
+		if c.includes[i] == absPath {
+			chain := make([]string, 0, len(c.includes)-i+1)
+			chain = append(chain, c.includes[i:]...)
+			return &includeCycleError{chain: append(chain, absPath)}
+		}
+	}
+	if c.parsed[absPath] {
+		// diamond include
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(config)
+	if err != nil {
+		return err
+	}
+	c.Files = append(c.Files, config)
+	c.parsed[absPath] = true
+
+	c.includes = append(c.includes, absPath)
+	defer func() { c.includes = c.includes[:len(c.includes)-1] }()
+
+	tokens, err := tokenize(config, string(data))
+	if err != nil {
+		return err
+	}
+
+	start := 0
+	for i := range tokens {
+		if tokens[i].is(";") {
+			if i == start {
+				return newParseError(tokens[i].Pos, "unexpected ';'")
+			}
+			if err = c.parseStatement(tokens[start:i]); err != nil {
+				return err
+			}
+			start = i + 1
+		}
+	}
+	if start < len(tokens) {
+		return newParseError(tokens[len(tokens)-1].Pos, "missing ';' after %s statement", tokens[start].Value)
+	}
+
+	return nil
+}
+
+func (c *Config) parseStatement(tokens []token) error {
+	switch {
+	case tokens[0].is("cluster"):
+		cluster, err := parseCluster(tokens)
+		if err != nil {
+			return err
+		}
+		for i := range c.Clusters {
+			if c.Clusters[i].Name == cluster.Name {
+				return newParseError(cluster.Pos, "cluster %s redefined, previous definition at %s", cluster.Name,
+					c.Clusters[i].Pos.String())
+			}
+		}
+		c.Clusters = append(c.Clusters, cluster)
+	case tokens[0].is("match"), tokens[0].is("aggregate"), tokens[0].is("statistics"):
+		route, err := parseRoute(tokens)
+		if err != nil {
+			return err
+		}
+		c.Routes = append(c.Routes, route)
+	case tokens[0].is("rewrite"):
+		if len(tokens) != 4 || !tokens[2].is("into") {
+			return newParseError(tokens[0].Pos, "rewrite must be in form 'rewrite <expression> into <replacement>'")
+		}
+		c.Rewrites = append(c.Rewrites, &Rewrite{Pos: tokens[0].Pos, Expression: tokens[1].Value, Replacement: tokens[3].Value})
+	case tokens[0].is("listen"):
+		listen := &Listen{Pos: tokens[0].Pos, Args: make([]string, len(tokens)-1)}
+		for i := 1; i < len(tokens); i++ {
+			listen.Args[i-1] = tokens[i].Value
+		}
+		c.Listens = append(c.Listens, listen)
+	case tokens[0].is("include"):
+		if len(tokens) != 2 {
+			return newParseError(tokens[0].Pos, "include must be in form 'include <path>'")
+		}
+		if err := c.include(tokens[0].Pos, tokens[1].Value); err != nil {
+			return err
+		}
+	default:
+		return newParseError(tokens[0].Pos, "unknown statement %s", tokens[0].Value)
+	}
+	return nil
+}
+
+type includeCycleError struct {
+	chain []string
+}
+
+func (e *includeCycleError) Error() string {
+	return "cycle " + strings.Join(e.chain, " -> ")
+}
+
+// include parse included files (path is relative to including file dir, glob patterns are expanded)
+func (c *Config) include(pos Pos, path string) error {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(pos.File), path)
+	}
+	files := []string{path}
+	if strings.ContainsAny(path, "*?[") {
+		var err error
+		if files, err = filepath.Glob(path); err != nil {
+			return newParseError(pos, "include %s: %s", path, err.Error())
+		}
+		c.IncludePatterns = append(c.IncludePatterns, path)
+	}
+	for _, file := range files {
+		if err := c.parseFile(file); err != nil {
+			if _, ok := err.(*ParseError); ok {
+				return err
+			}
+			return newParseError(pos, "include %s", err.Error())
+		}
+	}
+	return nil
+}
+
+func parseServer(t *token) (Server, error) {
+	server := Server{Pos: t.Pos, Port: "2003", Proto: "tcp"}
+	address := t.Value
+	if n := strings.LastIndex(address, "="); n > 0 {
+		server.Instance = address[n+1:]
+		address = address[:n]
+	}
+	if strings.HasPrefix(address, "[") {
+		// [ipv6]:port
+		n := strings.Index(address, "]")
+		if n == -1 {
+			return server, newParseError(t.Pos, "invalid server address %s", t.Value)
+		}
+		server.Host = address[1:n]
+		address = address[n+1:]
+		if len(address) > 0 {
+			if address[0] != ':' {
+				return server, newParseError(t.Pos, "invalid server address %s", t.Value)
+			}
+			server.Port = address[1:]
+		}
+	} else if strings.Count(address, ":") == 1 {
+		n := strings.Index(address, ":")
+		server.Host = address[:n]
+		server.Port = address[n+1:]
+	} else {
+		server.Host = address
+	}
+	if len(server.Host) == 0 {
+		return server, newParseError(t.Pos, "invalid server address %s", t.Value)
+	}
+	if port, err := strconv.Atoi(server.Port); err != nil || port < 1 || port > 65535 {
+		return server, newParseError(t.Pos, "invalid server port in %s", t.Value)
+	}
+	return server, nil
+}
+
+func parseCluster(tokens []token) (*ClusterDef, error) {
+	if len(tokens) < 3 {
+		return nil, newParseError(tokens[0].Pos, "incomplete cluster")
+	}
+	cluster := &ClusterDef{Pos: tokens[0].Pos, Name: tokens[1].Value, Type: tokens[2].Value}
+	if !clusterTypes[cluster.Type] {
+		return nil, newParseError(tokens[2].Pos, "unknown cluster %s type %s", cluster.Name, cluster.Type)
+	}
+	if cluster.Type == "file" {
+		return cluster, nil
+	}
+
+	i := 3
+	for i < len(tokens) {
+		t := &tokens[i]
+		last := len(cluster.Servers) - 1
+		switch {
+		case clusterOpts1[t.Value] && !t.Quoted:
+			i++
+			continue
+		case clusterOpts2[t.Value] && !t.Quoted:
+			i += 2
+			continue
+		case t.is("proto"), t.is("type"), t.is("transport"):
+			if last == -1 {
+				return nil, newParseError(t.Pos, "%s without server in cluster %s", t.Value, cluster.Name)
+			}
+			if i+1 >= len(tokens) {
+				return nil, newParseError(t.Pos, "%s without value in cluster %s", t.Value, cluster.Name)
+			}
+			v := tokens[i+1].Value
+			i += 2
+			switch t.Value {
+			case "proto":
+				if v != "tcp" && v != "udp" {
+					return nil, newParseError(t.Pos, "unknown proto %s in cluster %s", v, cluster.Name)
+				}
+				cluster.Servers[last].Proto = v
+			case "type":
+				cluster.Servers[last].Type = v
+			default:
+				// transport <plain | gzip | lz4 | snappy> [ssl | mtls <pemcert> <pemkey>]
+				cluster.Servers[last].Transport = v
+				if i < len(tokens) {
+					if tokens[i].is("ssl") {
+						i++
+					} else if tokens[i].is("mtls") {
+						i += 3
+					}
+				}
+			}
+			continue
+		}
+
+		server, err := parseServer(t)
+		if err != nil {
+			return nil, err
+		}
+		cluster.Servers = append(cluster.Servers, server)
+		i++
+	}
+
+	if len(cluster.Servers) == 0 {
+		return nil, newParseError(tokens[0].Pos, "empthy cluster %s", cluster.Name)
+	}
+
+	return cluster, nil
+}
+
+func parseRoute(tokens []token) (*Route, error) {
+	route := &Route{Pos: tokens[0].Pos, Statement: tokens[0].Value}
+	expressions := true
+	i := 1
+	for i < len(tokens) {
+		t := &tokens[i]
+		switch {
+		case t.is("send") && i+1 < len(tokens) && tokens[i+1].is("to"):
+			expressions = false
+			i += 2
+			for i < len(tokens) && !tokens[i].is("stop") {
+				route.Destinations = append(route.Destinations, tokens[i].Value)
+				i++
+			}
+			if len(route.Destinations) == 0 {
+				return nil, newParseError(t.Pos, "%s send to without destinations", route.Statement)
+			}
+			continue
+		case t.is("route") && i+1 < len(tokens) && tokens[i+1].is("using"):
+			expressions = false
+			route.Dynamic = true
+			i += 3
+			continue
+		case t.is("stop"):
+			route.Stop = true
+			expressions = false
+		case t.is("validate"), t.is("every"), t.is("submit"), t.is("reset"), t.is("prefix"):
+			expressions = false
+		case expressions:
+			route.Expressions = append(route.Expressions, t.Value)
+		}
+		i++
+	}
+
+	if route.Statement == "match" {
+		if len(route.Expressions) == 0 {
+			return nil, newParseError(route.Pos, "match without expressions")
+		}
+		if len(route.Destinations) == 0 && !route.Dynamic {
+			return nil, newParseError(route.Pos, "match without send to or route using")
+		}
+	}
+
+	return route, nil
+}
+
+// Undefined return errors for routes destinations, not defined as cluster (carbon-c-relay refuse to start with such config)
+func (c *Config) Undefined() []error {
+	var errs []error
+	clusters := make(map[string]bool)
+	for i := range c.Clusters {
+		clusters[c.Clusters[i].Name] = true
+	}
+	for _, route := range c.Routes {
+		for _, dest := range route.Destinations {
+			if dest != "blackhole" && !clusters[dest] {
+				errs = append(errs, newParseError(route.Pos, "%s send to undefined cluster %s", route.Statement, dest))
+			}
+		}
+	}
+	return errs
+}
+
+// Referenced return clusters referenced by routes (send to) and dynamic routes existence (match ... route using)
+func (c *Config) Referenced() (map[string]bool, bool) {
+	referenced := make(map[string]bool)
+	dynamic := false
+	for _, route := range c.Routes {
+		if route.Dynamic {
+			dynamic = true
+		}
+		for _, dest := range route.Destinations {
+			referenced[dest] = true
+		}
+	}
+	return referenced, dynamic
+}
@@ -0,0 +1,294 @@
+package carboncrelay
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeConfigs(t *testing.T, configs map[string]string) string {
+	dir, err := ioutil.TempDir("", "relaymon")
+	if err != nil {
+		t.Fatalf("create temp dir: %s", err.Error())
+	}
+	for name, data := range configs {
+		path := filepath.Join(dir, name)
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("create dir: %s", err.Error())
+		}
+		if err = ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("write config: %s", err.Error())
+		}
+	}
+	return dir
+}
+
+func Test_tokenize(t *testing.T) {
+	data := "cluster test # comment ;\n  forward\n\t\"a b;\\\"c\" 127.0.0.1:2003;\nmatch * send to test ;"
+	want := []token{
+		{Pos{"test.conf", 1}, "cluster", false},
+		{Pos{"test.conf", 1}, "test", false},
+		{Pos{"test.conf", 2}, "forward", false},
+		{Pos{"test.conf", 3}, `a b;"c`, true},
+		{Pos{"test.conf", 3}, "127.0.0.1:2003", false},
+		{Pos{"test.conf", 3}, ";", false},
+		{Pos{"test.conf", 4}, "match", false},
+		{Pos{"test.conf", 4}, "*", false},
+		{Pos{"test.conf", 4}, "send", false},
+		{Pos{"test.conf", 4}, "to", false},
+		{Pos{"test.conf", 4}, "test", false},
+		{Pos{"test.conf", 4}, ";", false},
+	}
+	got, err := tokenize("test.conf", data)
+	if err != nil {
+		t.Fatalf("tokenize() error = %s", err.Error())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tokenize() got\n%+v\nwant\n%+v", got, want)
+	}
+
+	_, err = tokenize("test.conf", "match\n\"test")
+	if err == nil || err.Error() != "test.conf:2: unterminated quoted string" {
+		t.Errorf("tokenize() error = %v", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	configs := map[string]string{
+		"relay.conf": `
+listen type linemode 2003 proto tcp ;
+cluster graphite
+    carbon_ch replication 2
+        10.0.0.1:2003=a 10.0.0.2=b proto udp [::1]:2004
+    ;
+cluster moira forward moira:2003 transport gzip ssl ;
+cluster unused any_of useall 10.0.0.3 10.0.0.4 ;
+cluster dump file ip /tmp/relay.out ;
+rewrite ^(.*)\.bad$ into \1.good ;
+match "^sys\..*" send to graphite moira stop ;
+aggregate ^sys\.cpu\..* every 60 seconds expire after 75 seconds
+    compute sum write to sys.cpu.all send to graphite stop ;
+include sub.conf ;
+`,
+		"sub.conf": `statistics submit every 60 seconds send to dump ;`,
+	}
+	dir := writeConfigs(t, configs)
+	defer os.RemoveAll(dir)
+	cwd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	got, err := Parse("relay.conf")
+	if err != nil {
+		t.Fatalf("Parse() error = %s", err.Error())
+	}
+
+	wantClusters := []*ClusterDef{
+		{Pos: Pos{"relay.conf", 3}, Name: "graphite", Type: "carbon_ch", Servers: []Server{
+			{Pos: Pos{"relay.conf", 5}, Host: "10.0.0.1", Port: "2003", Instance: "a", Proto: "tcp"},
+			{Pos: Pos{"relay.conf", 5}, Host: "10.0.0.2", Port: "2003", Instance: "b", Proto: "udp"},
+			{Pos: Pos{"relay.conf", 5}, Host: "::1", Port: "2004", Proto: "tcp"},
+		}},
+		{Pos: Pos{"relay.conf", 7}, Name: "moira", Type: "forward", Servers: []Server{
+			{Pos: Pos{"relay.conf", 7}, Host: "moira", Port: "2003", Proto: "tcp", Transport: "gzip"},
+		}},
+		{Pos: Pos{"relay.conf", 8}, Name: "unused", Type: "any_of", Servers: []Server{
+			{Pos: Pos{"relay.conf", 8}, Host: "10.0.0.3", Port: "2003", Proto: "tcp"},
+			{Pos: Pos{"relay.conf", 8}, Host: "10.0.0.4", Port: "2003", Proto: "tcp"},
+		}},
+		{Pos: Pos{"relay.conf", 9}, Name: "dump", Type: "file"},
+	}
+	if !reflect.DeepEqual(got.Clusters, wantClusters) {
+		t.Errorf("Parse() clusters got\n%+v\nwant\n%+v", got.Clusters, wantClusters)
+	}
+
+	wantRoutes := []*Route{
+		{Pos: Pos{"relay.conf", 11}, Statement: "match", Expressions: []string{`^sys\..*`},
+			Destinations: []string{"graphite", "moira"}, Stop: true},
+		{Pos: Pos{"relay.conf", 12}, Statement: "aggregate", Expressions: []string{`^sys\.cpu\..*`},
+			Destinations: []string{"graphite"}, Stop: true},
+		{Pos: Pos{"sub.conf", 1}, Statement: "statistics", Destinations: []string{"dump"}},
+	}
+	if !reflect.DeepEqual(got.Routes, wantRoutes) {
+		t.Errorf("Parse() routes got\n%+v\nwant\n%+v", got.Routes, wantRoutes)
+	}
+
+	wantRewrites := []*Rewrite{{Pos: Pos{"relay.conf", 10}, Expression: `^(.*)\.bad$`, Replacement: `\1.good`}}
+	if !reflect.DeepEqual(got.Rewrites, wantRewrites) {
+		t.Errorf("Parse() rewrites got\n%+v\nwant\n%+v", got.Rewrites, wantRewrites)
+	}
+	if len(got.Listens) != 1 {
+		t.Errorf("Parse() listens got %d, want 1", len(got.Listens))
+	}
+	if !reflect.DeepEqual(got.Files, []string{"relay.conf", "sub.conf"}) {
+		t.Errorf("Parse() files got %v", got.Files)
+	}
+
+	clusters, unreferenced := got.NetworkClusters([]string{"moira"}, "relaymon", time.Second, true)
+	if len(clusters) != 2 || clusters[0].Name != "graphite" || clusters[1].Name != "moira" {
+		t.Errorf("Config.NetworkClusters() got %+v", clusters)
+	} else {
+		wantEndpoints := []string{"10.0.0.1:2003", "10.0.0.2:2003", "[::1]:2004"}
+		if !reflect.DeepEqual(clusters[0].Endpoints, wantEndpoints) {
+			t.Errorf("Config.NetworkClusters()[0].Endpoints got %v, want %v", clusters[0].Endpoints, wantEndpoints)
+		}
+		if !clusters[1].Required {
+			t.Errorf("Config.NetworkClusters()[1] must be required")
+		}
+	}
+	if !reflect.DeepEqual(unreferenced, []string{"unused"}) {
+		t.Errorf("Config.NetworkClusters() unreferenced got %v", unreferenced)
+	}
+	if errs := got.Undefined(); len(errs) > 0 {
+		t.Errorf("Config.Undefined() got %v", errs)
+	}
+}
+
+func TestConfig_Undefined(t *testing.T) {
+	dir := writeConfigs(t, map[string]string{"relay.conf": "cluster a forward a:2003 ;\nmatch * send to a b blackhole ;"})
+	defer os.RemoveAll(dir)
+
+	c, err := Parse(filepath.Join(dir, "relay.conf"))
+	if err != nil {
+		t.Fatalf("Parse() error = %s", err.Error())
+	}
+	errs := c.Undefined()
+	want := filepath.Join(dir, "relay.conf") + ":2: match send to undefined cluster b"
+	if len(errs) != 1 || errs[0].Error() != want {
+		t.Errorf("Config.Undefined() got %v, want [%s]", errs, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"unknown statement", "cluster a forward a:2003 ;\n\nsend to a ;", "relay.conf:3: unknown statement send"},
+		{"missing ;", "cluster a forward\n a:2003", "relay.conf:2: missing ';' after cluster statement"},
+		{"cluster type", "cluster a\n unknown a:2003 ;", "relay.conf:2: unknown cluster a type unknown"},
+		{"empthy cluster", "cluster a forward ;", "relay.conf:1: empthy cluster a"},
+		{"invalid port", "cluster a forward\n a:port ;", "relay.conf:2: invalid server port in a:port"},
+		{"invalid proto", "cluster a forward a:2003 proto sctp ;", "relay.conf:1: unknown proto sctp in cluster a"},
+		{"match without send", "cluster a forward a:2003 ;\nmatch * stop ;", "relay.conf:2: match without send to or route using"},
+		{"include", "include not_found.conf ;", "relay.conf:1: include open not_found.conf: no such file or directory"},
+		{"cluster redefined", "cluster a forward a:2003 ;\ncluster a any_of b:2003 ;", "relay.conf:2: cluster a redefined, previous definition at relay.conf:1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeConfigs(t, map[string]string{"relay.conf": tt.config})
+			defer os.RemoveAll(dir)
+			cwd, _ := os.Getwd()
+			if err := os.Chdir(dir); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Chdir(cwd)
+
+			_, err := Parse("relay.conf")
+			if err == nil {
+				t.Fatalf("Parse() error = nil, want '%s'", tt.wantErr)
+			}
+			if err.Error() != tt.wantErr {
+				t.Errorf("Parse() error = '%s', want '%s'", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseIncludes(t *testing.T) {
+	configs := map[string]string{
+		"relay.conf":               "include relay.d/*.conf ;\nmatch * send to a b ;",
+		"relay.d/01-a.conf":        "cluster a forward a:2003 ;\ninclude ../common/c.conf ;",
+		"relay.d/02-b.conf":        "cluster b forward b:2003 ;",
+		"relay.d/03-empthy.notcnf": "cluster d forward d:2003 ;",
+		"common/c.conf":            "cluster c forward c:2003 ;",
+	}
+	dir := writeConfigs(t, configs)
+	defer os.RemoveAll(dir)
+
+	c, err := Parse(filepath.Join(dir, "relay.conf"))
+	if err != nil {
+		t.Fatalf("Parse() error = %s", err.Error())
+	}
+	names := make([]string, len(c.Clusters))
+	for i := range c.Clusters {
+		names[i] = c.Clusters[i].Name
+	}
+	if !reflect.DeepEqual(names, []string{"a", "c", "b"}) {
+		t.Errorf("Parse() clusters got %v, want [a c b]", names)
+	}
+	wantFiles := []string{
+		filepath.Join(dir, "relay.conf"), filepath.Join(dir, "relay.d/01-a.conf"),
+		filepath.Join(dir, "common/c.conf"), filepath.Join(dir, "relay.d/02-b.conf"),
+	}
+	if !reflect.DeepEqual(c.Files, wantFiles) {
+		t.Errorf("Parse() files got %v, want %v", c.Files, wantFiles)
+	}
+	if want := []string{filepath.Join(dir, "relay.d/*.conf")}; !reflect.DeepEqual(c.IncludePatterns, want) {
+		t.Errorf("Parse() include patterns got %v, want %v", c.IncludePatterns, want)
+	}
+	if c.Clusters[1].Pos.File != filepath.Join(dir, "common/c.conf") {
+		t.Errorf("Parse() cluster c position got %s", c.Clusters[1].Pos.String())
+	}
+
+	// glob without matches
+	dir2 := writeConfigs(t, map[string]string{"relay.conf": "include relay.d/*.conf ;"})
+	defer os.RemoveAll(dir2)
+	if _, err = Parse(filepath.Join(dir2, "relay.conf")); err != nil {
+		t.Errorf("Parse() error = %s", err.Error())
+	}
+}
+
+func TestParseIncludeDiamond(t *testing.T) {
+	configs := map[string]string{
+		"relay.conf":          "include relay.d/a.conf ;\ninclude relay.d/b.conf ;\nmatch * send to a b c ;",
+		"relay.d/a.conf":      "cluster a forward a:2003 ;\ninclude common.conf ;",
+		"relay.d/b.conf":      "cluster b forward b:2003 ;\ninclude common.conf ;",
+		"relay.d/common.conf": "cluster c forward c:2003 ;",
+	}
+	dir := writeConfigs(t, configs)
+	defer os.RemoveAll(dir)
+
+	c, err := Parse(filepath.Join(dir, "relay.conf"))
+	if err != nil {
+		t.Fatalf("Parse() error = %s", err.Error())
+	}
+	names := make([]string, len(c.Clusters))
+	for i := range c.Clusters {
+		names[i] = c.Clusters[i].Name
+	}
+	if !reflect.DeepEqual(names, []string{"a", "c", "b"}) {
+		t.Errorf("Parse() clusters got %v, want [a c b]", names)
+	}
+	wantFiles := []string{
+		filepath.Join(dir, "relay.conf"), filepath.Join(dir, "relay.d/a.conf"),
+		filepath.Join(dir, "relay.d/common.conf"), filepath.Join(dir, "relay.d/b.conf"),
+	}
+	if !reflect.DeepEqual(c.Files, wantFiles) {
+		t.Errorf("Parse() files got %v, want %v", c.Files, wantFiles)
+	}
+}
+
+func TestParseIncludeCycle(t *testing.T) {
+	configs := map[string]string{
+		"relay.conf":     "include relay.d/a.conf ;",
+		"relay.d/a.conf": "include b.conf ;",
+		"relay.d/b.conf": "cluster b forward b:2003 ;\ninclude ../relay.conf ;",
+	}
+	dir := writeConfigs(t, configs)
+	defer os.RemoveAll(dir)
+
+	_, err := Parse(filepath.Join(dir, "relay.conf"))
+	want := filepath.Join(dir, "relay.d/b.conf") + ":2: include cycle " +
+		filepath.Join(dir, "relay.conf") + " -> " + filepath.Join(dir, "relay.d/a.conf") + " -> " +
+		filepath.Join(dir, "relay.d/b.conf") + " -> " + filepath.Join(dir, "relay.conf")
+	if err == nil || err.Error() != want {
+		t.Errorf("Parse() error = %v, want '%s'", err, want)
+	}
+}
@@ -3,7 +3,6 @@ package carbonnetwork
 import (
 	"context"
 	"fmt"
-	"math"
 	"net"
 	"strconv"
 	"time"
@@ -13,15 +12,22 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// udpUnreachableWait is max wait time for ICMP port unreachable
+const udpUnreachableWait = 200 * time.Millisecond
+
 // Cluster describe group of network endpoints
 type Cluster struct {
 	Name        string
 	Endpoints   []string
+	Protos      []string
 	TestMetrics []string
 	testPrefix  string
 	Errors      []error
 	timeout     time.Duration
 	Required    bool
+
+	// detect ICMP port unreachable for udp endpoints (with connected udp socket)
+	udpUnreachable bool
 }
 
 type check struct {
@@ -34,15 +40,62 @@ func NewCluster(name string, required bool, testPrefix string, timeout time.Dura
 	return &Cluster{Name: name, Required: required, testPrefix: testPrefix, timeout: timeout}
 }
 
-// Append append cluster endpoint
+// Append append cluster endpoint (tcp)
 func (c *Cluster) Append(endpoint string) *Cluster {
+	return c.AppendProto(endpoint, "tcp")
+}
+
+// AppendProto append cluster endpoint with protocol (tcp or udp)
+func (c *Cluster) AppendProto(endpoint string, proto string) *Cluster {
 	c.Endpoints = append(c.Endpoints, endpoint)
+	c.Protos = append(c.Protos, proto)
 	c.Errors = append(c.Errors, nil)
 	testMetric := fmt.Sprintf("%s.test.network.carbon.%s.%s", c.testPrefix, checker.Strip(c.Name), checker.Strip(endpoint))
 	c.TestMetrics = append(c.TestMetrics, testMetric)
 	return c
 }
 
+// SetUDPUnreachable enable ICMP port unreachable detection for udp endpoints
+func (c *Cluster) SetUDPUnreachable(enable bool) *Cluster {
+	c.udpUnreachable = enable
+	return c
+}
+
+// checkUDP send test metric in datagram and optionally wait for ICMP port unreachable
+func (c *Cluster) checkUDP(ctx context.Context, n int, timestamp int64) error {
+	ctxTout, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctxTout, "udp", c.Endpoints[n])
+	if err != nil {
+		return neterror.NewNetError(err)
+	}
+	defer conn.Close()
+
+	deadline, _ := ctxTout.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	log.Trace().Str("action", "check").Str("network_checker", "carbon").Int("n", n).Str("endpoint", c.Endpoints[n]).Msg("write")
+
+	_, err = conn.Write([]byte(c.TestMetrics[n] + " 1 " + strconv.FormatInt(timestamp, 10) + "\n"))
+	if err != nil || !c.udpUnreachable {
+		return neterror.NewNetError(err)
+	}
+
+	// connected udp socket return ECONNREFUSED on read after ICMP port unreachable
+	if wait := time.Now().Add(udpUnreachableWait); wait.Before(deadline) {
+		_ = conn.SetReadDeadline(wait)
+	}
+	buf := make([]byte, 1)
+	_, err = conn.Read(buf)
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return nil
+	}
+	return neterror.NewNetError(err)
+}
+
 // Check cluster status (success, errors)
 func (c *Cluster) Check(ctx context.Context, timestamp int64) (bool, []error) {
 	out := make(chan check, len(c.Endpoints))
@@ -52,6 +105,12 @@ func (c *Cluster) Check(ctx context.Context, timestamp int64) (bool, []error) {
 		go func(out chan check, n int) {
 			log.Trace().Str("action", "check").Str("network_checker", "carbon").Int("n", n).Str("endpoint", c.Endpoints[n]).Msg("next check iteration")
 
+			if c.Protos[n] == "udp" {
+				out <- check{n, c.checkUDP(ctx, n, timestamp)}
+				log.Trace().Str("action", "check").Str("network_checker", "carbon").Int("n", n).Str("endpoint", c.Endpoints[n]).Msg("end check iteration")
+				return
+			}
+
 			ctxTout, cancel := context.WithTimeout(ctx, c.timeout)
 			defer cancel()
 
@@ -121,16 +180,10 @@ type NetworkChecker struct {
 	name     string
 	clusters []*Cluster
 
-	// check results
-	failed  int
-	success int
-	checked int
+	// check results and thresholds
+	thresholds checker.Thresholds
 
-	// check thresholds
-	metrics    []checker.Metric
-	failCount  int
-	checkCount int
-	resetCount int
+	metrics []checker.Metric
 
 	notify bool
 }
@@ -147,9 +200,7 @@ func NewNetworkChecker(name string, clusters []*Cluster, timeout time.Duration,
 	network := &NetworkChecker{
 		name:       name,
 		clusters:   clusters,
-		failCount:  failCount,
-		checkCount: checkCount,
-		resetCount: resetCount,
+		thresholds: checker.NewThresholds(failCount, checkCount, resetCount),
 		metrics:    make([]checker.Metric, n),
 	}
 	n = 0
@@ -157,6 +208,11 @@ func NewNetworkChecker(name string, clusters []*Cluster, timeout time.Duration,
 		for j := range clusters[i].Endpoints {
 			network.metrics[n].Name = "network.carbon." + checker.Strip(clusters[i].Name) + "." + checker.Strip(clusters[i].Endpoints[j])
 			network.metrics[n].Value = strconv.Itoa(int(checker.CollectingState))
+			network.metrics[n].Family = "carbon_endpoint_state"
+			network.metrics[n].Labels = []checker.Label{
+				{Name: "cluster", Value: clusters[i].Name},
+				{Name: "endpoint", Value: clusters[i].Endpoints[j]},
+			}
 			n++
 		}
 	}
@@ -215,37 +271,55 @@ func (n *NetworkChecker) Status(ctx context.Context, timestamp int64) (checker.S
 		successCheck = false
 	}
 
-	if n.checked < math.MaxInt32 {
-		n.checked++
+	return n.thresholds.Update(successCheck), events
+}
+
+// Metrics get metric for status check
+func (n *NetworkChecker) Metrics() []checker.Metric {
+	return n.metrics
+}
+
+// Counters get network check results counters
+func (n *NetworkChecker) Counters() checker.Counters {
+	return n.thresholds.Counters
+}
+
+// Restore set counters (persisted before restart)
+func (n *NetworkChecker) Restore(state checker.State, counters checker.Counters) {
+	n.thresholds.Counters = counters
+}
+
+type endpointState struct {
+	err    error
+	metric string
+}
+
+// Merge copy state (counters and errors for same cluster endpoints) from previous network checker instance
+func (n *NetworkChecker) Merge(previous checker.Checker) {
+	p, ok := previous.(*NetworkChecker)
+	if !ok {
+		return
 	}
+	n.thresholds.Counters = p.thresholds.Counters
 
-	if successCheck {
-		if n.success < math.MaxInt32 {
-			n.success++
-		}
-		if n.failed > 0 && n.success >= n.resetCount {
-			n.failed = 0
-		}
-	} else {
-		if n.success > 0 {
-			n.success = 0
-		}
-		if n.failed < math.MaxInt32 {
-			n.failed++
+	endpoints := make(map[string]endpointState)
+	k := 0
+	for i := range p.clusters {
+		for j := range p.clusters[i].Endpoints {
+			endpoints[p.clusters[i].Name+" "+p.clusters[i].Endpoints[j]] = endpointState{
+				err: p.clusters[i].Errors[j], metric: p.metrics[k].Value,
+			}
+			k++
 		}
 	}
-	if n.checked < n.checkCount {
-		return checker.CollectingState, events
-	} else if n.failed > 0 {
-		if n.failed >= n.failCount {
-			return checker.ErrorState, events
+	k = 0
+	for i := range n.clusters {
+		for j := range n.clusters[i].Endpoints {
+			if e, ok := endpoints[n.clusters[i].Name+" "+n.clusters[i].Endpoints[j]]; ok {
+				n.clusters[i].Errors[j] = e.err
+				n.metrics[k].Value = e.metric
+			}
+			k++
 		}
-		return checker.WarnState, events
 	}
-	return checker.SuccessState, events
-}
-
-// Metrics get metric for status check
-func (n *NetworkChecker) Metrics() []checker.Metric {
-	return n.metrics
 }
@@ -319,3 +319,127 @@ func TestNetworkChecker_Status(t *testing.T) {
 		})
 	}
 }
+
+type udpServer struct {
+	address string
+	conn    net.PacketConn
+}
+
+func newUDPServer(t *testing.T, listen bool) server {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %s", err.Error())
+	}
+	s := &udpServer{address: conn.LocalAddr().String()}
+	if listen {
+		s.conn = conn
+		go func() {
+			buf := make([]byte, 1024)
+			for {
+				if _, _, err := conn.ReadFrom(buf); err != nil {
+					return
+				}
+			}
+		}()
+	} else {
+		conn.Close()
+	}
+	return s
+}
+
+func (s *udpServer) Address() string {
+	return s.address
+}
+
+func (s *udpServer) Stop() {
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+func TestCluster_CheckUDP(t *testing.T) {
+	prefix := "relaymon"
+	timeout := time.Second
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		unreachable bool
+		listen      []bool
+		want        bool
+		wantErr     []bool
+	}{
+		{
+			name:   "without unreachable detection",
+			listen: []bool{false, true},
+			want:   true, wantErr: []bool{false, false},
+		},
+		{
+			name: "one unreachable", unreachable: true,
+			listen: []bool{false, true},
+			want:   true, wantErr: []bool{true, false},
+		},
+		{
+			name: "all unreachable", unreachable: true,
+			listen: []bool{false, false},
+			want:   false, wantErr: []bool{true, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := NewCluster("udp", false, prefix, timeout).SetUDPUnreachable(tt.unreachable)
+			for i := range tt.listen {
+				s := newUDPServer(t, tt.listen[i])
+				defer s.Stop()
+				cluster.AppendProto(s.Address(), "udp")
+			}
+
+			got, gotErr := cluster.Check(ctx, 0)
+			if got != tt.want {
+				t.Errorf("%s Cluster.Check() got = %v, want %v", cluster.Name, got, tt.want)
+			}
+			for i := range gotErr {
+				if (gotErr[i] != nil) != tt.wantErr[i] {
+					t.Errorf("%s Cluster.Check() got error '%v' for %s (%d), want error %v", cluster.Name,
+						gotErr[i], cluster.Endpoints[i], i, tt.wantErr[i],
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestNetworkChecker_Merge(t *testing.T) {
+	prefix := "relaymon"
+	timeout := time.Second
+
+	prev := NewNetworkChecker("carbon", []*Cluster{
+		NewCluster("a", false, prefix, timeout).Append("127.0.0.1:2003").Append("127.0.0.1:2004"),
+	}, timeout, 2, 3, 2)
+	prev.thresholds.Counters = checker.Counters{Failed: 1, Success: 0, Checked: 5}
+	prev.clusters[0].Errors[1] = fmt.Errorf("connection refused")
+	prev.metrics[0].Value = strconv.Itoa(int(checker.SuccessState))
+	prev.metrics[1].Value = strconv.Itoa(int(checker.ErrorState))
+
+	c := NewNetworkChecker("carbon", []*Cluster{
+		NewCluster("a", false, prefix, timeout).Append("127.0.0.1:2004").Append("127.0.0.1:2005"),
+		NewCluster("b", false, prefix, timeout).Append("127.0.0.1:2003"),
+	}, timeout, 2, 3, 2)
+	c.Merge(prev)
+
+	if got := c.Counters(); got != (checker.Counters{Failed: 1, Success: 0, Checked: 5}) {
+		t.Errorf("NetworkChecker.Merge() counters got %+v", got)
+	}
+	wantErrs := []error{fmt.Errorf("connection refused"), nil}
+	for i := range wantErrs {
+		if checker.ErrorChanged(c.clusters[0].Errors[i], wantErrs[i]) {
+			t.Errorf("NetworkChecker.Merge() clusters[0].Errors[%d] got %v, want %v", i, c.clusters[0].Errors[i], wantErrs[i])
+		}
+	}
+	wantMetrics := []checker.State{checker.ErrorState, checker.CollectingState, checker.CollectingState}
+	for i := range wantMetrics {
+		if c.metrics[i].Value != strconv.Itoa(int(wantMetrics[i])) {
+			t.Errorf("NetworkChecker.Merge() metrics[%d] got %s, want %d", i, c.metrics[i].Value, wantMetrics[i])
+		}
+	}
+}
@@ -41,6 +41,8 @@ func ErrorChanged(previous error, current error) bool {
 // String get string for State
 func (s *State) String() string {
 	switch *s {
+	case CollectingState:
+		return "collecting"
 	case SuccessState:
 		return "success"
 	case WarnState:
@@ -60,10 +62,32 @@ func Strip(s string) string {
 	return reg.ReplaceAllString(s, "_")
 }
 
+// Label describe metric label
+type Label struct {
+	Name  string
+	Value string
+}
+
 // Metric describe checker metric
 type Metric struct {
+	// Name is dotted metric name (Graphite)
 	Name  string
 	Value string
+
+	// Family and Labels describe labeled metric (Prometheus)
+	Family string
+	Labels []Label
+	// Help for metric family (checker state help, if empty)
+	Help string
+	// Counter is true for monotonic counter family (gauge, if false)
+	Counter bool
+}
+
+// Counters describe checker results counters
+type Counters struct {
+	Failed  int `json:"failed"`
+	Success int `json:"success"`
+	Checked int `json:"checked"`
 }
 
 // Checker interface
@@ -73,4 +97,18 @@ type Checker interface {
 	Status(ctx context.Context, timestamp int64) (State, []string)
 	// Return checker metrics
 	Metrics() []Metric
+	// Return checker results counters
+	Counters() Counters
+}
+
+// Restorer interface for checkers, which can restore counters (persisted before restart)
+type Restorer interface {
+	// Restore set state and counters
+	Restore(state State, counters Counters)
+}
+
+// Merger interface for checkers, which can take state from previous instance (on config reload)
+type Merger interface {
+	// Merge copy state (counters, endpoints errors) from previous checker instance with same name
+	Merge(previous Checker)
 }
@@ -0,0 +1,52 @@
+package checker
+
+import "math"
+
+// Thresholds count check results and get state with fail, check and reset thresholds
+type Thresholds struct {
+	Counters
+
+	// FailCount is failed checks count for error state (warn state before)
+	FailCount int
+	// CheckCount is checks count for leave collecting state
+	CheckCount int
+	// ResetCount is success checks count for reset failed checks
+	ResetCount int
+}
+
+// NewThresholds return new thresholds instance
+func NewThresholds(failCount int, checkCount int, resetCount int) Thresholds {
+	return Thresholds{FailCount: failCount, CheckCount: checkCount, ResetCount: resetCount}
+}
+
+// Update count check result, return state
+func (t *Thresholds) Update(success bool) State {
+	if t.Checked < math.MaxInt32 {
+		t.Checked++
+	}
+
+	if success {
+		if t.Success < math.MaxInt32 {
+			t.Success++
+		}
+		if t.Failed > 0 && t.Success >= t.ResetCount {
+			t.Failed = 0
+		}
+	} else {
+		if t.Success > 0 {
+			t.Success = 0
+		}
+		if t.Failed < math.MaxInt32 {
+			t.Failed++
+		}
+	}
+	if t.Checked < t.CheckCount {
+		return CollectingState
+	} else if t.Failed > 0 {
+		if t.Failed >= t.FailCount {
+			return ErrorState
+		}
+		return WarnState
+	}
+	return SuccessState
+}
@@ -0,0 +1,29 @@
+package checker
+
+import "testing"
+
+func TestThresholds(t *testing.T) {
+	th := NewThresholds(2, 3, 2)
+	tests := []struct {
+		success bool
+		want    State
+	}{
+		{true, CollectingState},
+		{false, CollectingState},
+		{true, WarnState},
+		{false, ErrorState},
+		{false, ErrorState},
+		// failed checks reset after 2 success checks
+		{true, ErrorState},
+		{true, SuccessState},
+		{false, WarnState},
+	}
+	for i, tt := range tests {
+		if got := th.Update(tt.success); got != tt.want {
+			t.Errorf("[%d] Thresholds.Update(%v) = %s, want %s", i, tt.success, got.String(), tt.want.String())
+		}
+	}
+	if want := (Counters{Failed: 1, Success: 0, Checked: 8}); th.Counters != want {
+		t.Errorf("Thresholds.Counters = %+v, want %+v", th.Counters, want)
+	}
+}
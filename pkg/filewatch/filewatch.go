@@ -0,0 +1,129 @@
+package filewatch
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+const watchMask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_CREATE | syscall.IN_DELETE
+
+// Watcher watch files changes with inotify (parent directories are watched, so files replace is also detected)
+type Watcher struct {
+	file     *os.File
+	dirs     map[int32]string
+	files    map[string]bool
+	patterns []string
+}
+
+// NewWatcher start watch files and files matched by glob patterns (like includes, so new files are detected,
+// patterns in not existing directories are skipped), notify called from watcher goroutine with changed file name
+func NewWatcher(files []string, patterns []string, notify func(file string)) (*Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	w := &Watcher{
+		file:  os.NewFile(uintptr(fd), "inotify"),
+		dirs:  make(map[int32]string),
+		files: make(map[string]bool),
+	}
+
+	watched := make(map[string]bool)
+	for _, file := range files {
+		path, err := filepath.Abs(file)
+		if err != nil {
+			w.file.Close()
+			return nil, err
+		}
+		w.files[path] = true
+		dir := filepath.Dir(path)
+		if watched[dir] {
+			continue
+		}
+		wd, err := syscall.InotifyAddWatch(fd, dir, watchMask)
+		if err != nil {
+			w.file.Close()
+			return nil, &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+		}
+		watched[dir] = true
+		w.dirs[int32(wd)] = dir
+	}
+	for _, pattern := range patterns {
+		path, err := filepath.Abs(pattern)
+		if err != nil {
+			w.file.Close()
+			return nil, err
+		}
+		w.patterns = append(w.patterns, path)
+		dir := filepath.Dir(path)
+		if watched[dir] {
+			continue
+		}
+		wd, err := syscall.InotifyAddWatch(fd, dir, watchMask)
+		if err == syscall.ENOENT {
+			continue
+		} else if err != nil {
+			w.file.Close()
+			return nil, &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+		}
+		watched[dir] = true
+		w.dirs[int32(wd)] = dir
+	}
+
+	go w.read(notify)
+
+	return w, nil
+}
+
+func (w *Watcher) read(notify func(file string)) {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+		offset := 0
+		for offset+syscall.SizeofInotifyEvent <= n {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			nameEnd := nameStart + int(event.Len)
+			if nameEnd > n {
+				break
+			}
+			name := string(buf[nameStart:nameEnd])
+			for i := 0; i < len(name); i++ {
+				if name[i] == 0 {
+					name = name[:i]
+					break
+				}
+			}
+			if dir, ok := w.dirs[event.Wd]; ok && len(name) > 0 {
+				path := filepath.Join(dir, name)
+				if w.match(path) {
+					notify(path)
+				}
+			}
+			offset = nameEnd
+		}
+	}
+}
+
+// match check path is watched file or matched by pattern
+func (w *Watcher) match(path string) bool {
+	if w.files[path] {
+		return true
+	}
+	for _, pattern := range w.patterns {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Close stop watch
+func (w *Watcher) Close() error {
+	return w.file.Close()
+}
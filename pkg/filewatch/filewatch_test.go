@@ -0,0 +1,108 @@
+package filewatch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "relaymon")
+	if err != nil {
+		t.Fatalf("create temp dir: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	watched := filepath.Join(dir, "relaymon.yml")
+	other := filepath.Join(dir, "other.yml")
+	if err = ioutil.WriteFile(watched, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := make(chan string, 10)
+	w, err := NewWatcher([]string{watched}, nil, func(file string) { changed <- file })
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %s", err.Error())
+	}
+	defer w.Close()
+
+	if err = ioutil.WriteFile(other, []byte("b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// replace file (like editors)
+	tmp := filepath.Join(dir, ".relaymon.yml.tmp")
+	if err = ioutil.WriteFile(tmp, []byte("c"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Rename(tmp, watched); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case file := <-changed:
+		if file != watched {
+			t.Errorf("Watcher notify got %s, want %s", file, watched)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Watcher notify timeout")
+	}
+	for len(changed) > 0 {
+		if file := <-changed; file != watched {
+			t.Errorf("Watcher notify got %s, want %s", file, watched)
+		}
+	}
+}
+
+func TestWatcherPatterns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "relaymon")
+	if err != nil {
+		t.Fatalf("create temp dir: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	if err = os.Mkdir(filepath.Join(dir, "conf.d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := make(chan string, 10)
+	patterns := []string{filepath.Join(dir, "conf.d/*.conf"), filepath.Join(dir, "not_exist/*.conf")}
+	w, err := NewWatcher(nil, patterns, func(file string) { changed <- file })
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %s", err.Error())
+	}
+	defer w.Close()
+
+	// not matched
+	if err = ioutil.WriteFile(filepath.Join(dir, "conf.d/a.conf.bak"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// new file
+	created := filepath.Join(dir, "conf.d/b.conf")
+	if err = ioutil.WriteFile(created, []byte("b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// moved in
+	moved := filepath.Join(dir, "conf.d/c.conf")
+	tmp := filepath.Join(dir, "c.conf")
+	if err = ioutil.WriteFile(tmp, []byte("c"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Rename(tmp, moved); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]bool)
+	timeout := time.After(2 * time.Second)
+	for !got[created] || !got[moved] {
+		select {
+		case file := <-changed:
+			if file != created && file != moved {
+				t.Errorf("Watcher notify got %s", file)
+			}
+			got[file] = true
+		case <-timeout:
+			t.Fatalf("Watcher notify timeout, got %v", got)
+		}
+	}
+}
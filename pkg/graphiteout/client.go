@@ -0,0 +1,148 @@
+package graphiteout
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Protocols
+const (
+	// TCP is plaintext over tcp
+	TCP = "tcp"
+	// UDP is plaintext over udp
+	UDP = "udp"
+	// Pickle is pickle over tcp
+	Pickle = "pickle"
+)
+
+// maxDatagram is maximum udp datagram size (metrics are splitted to several datagrams)
+const maxDatagram = 1400
+
+// Client send metrics to graphite destination
+type Client struct {
+	address  string
+	protocol string
+	prefix   string
+	// tagged send tagged series instead of dotted names (with taggedPrefix)
+	tagged       bool
+	taggedPrefix string
+	timeout      time.Duration
+
+	conn net.Conn
+	buf  bytes.Buffer
+}
+
+// New return new graphite client (not connected), address in host[:port] format (port 2003, if not set)
+func New(address, protocol, prefix string, tagged bool, taggedPrefix string, timeout time.Duration) (*Client, error) {
+	switch protocol {
+	case TCP, UDP, Pickle:
+	default:
+		return nil, fmt.Errorf("graphite protocol '%s' unknown", protocol)
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "2003")
+	}
+	return &Client{
+		address: address, protocol: protocol, prefix: prefix, tagged: tagged, taggedPrefix: taggedPrefix, timeout: timeout,
+	}, nil
+}
+
+// Address get destination address
+func (c *Client) Address() string {
+	return c.address
+}
+
+// IsConnected return true, if client connected
+func (c *Client) IsConnected() bool {
+	return c.conn != nil
+}
+
+// Connect (or reconnect) to destination
+func (c *Client) Connect() error {
+	_ = c.Disconnect()
+	network := "tcp"
+	if c.protocol == UDP {
+		network = "udp"
+	}
+	conn, err := net.DialTimeout(network, c.address, c.timeout)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	return nil
+}
+
+// Disconnect close connection
+func (c *Client) Disconnect() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// name return metric name with prefix (tagged series, if enabled)
+func (c *Client) name(m *Metric) string {
+	if c.tagged && len(m.Series) > 0 {
+		if len(c.taggedPrefix) == 0 {
+			return m.Series
+		}
+		return c.taggedPrefix + "." + m.Series
+	}
+	if len(c.prefix) == 0 {
+		return m.Name
+	}
+	return c.prefix + "." + m.Name
+}
+
+// line format metric in plaintext protocol
+func (c *Client) line(m *Metric) string {
+	return c.name(m) + " " + m.Value + " " + strconv.FormatInt(m.Timestamp, 10) + "\n"
+}
+
+func (c *Client) write(b []byte) error {
+	if c.timeout > 0 {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	}
+	_, err := c.conn.Write(b)
+	return err
+}
+
+// Send metrics (connection must be established)
+func (c *Client) Send(metrics []*Metric) error {
+	if c.conn == nil {
+		return fmt.Errorf("%s not connected", c.address)
+	}
+	c.buf.Reset()
+	switch c.protocol {
+	case UDP:
+		for _, m := range metrics {
+			line := c.line(m)
+			if c.buf.Len() > 0 && c.buf.Len()+len(line) > maxDatagram {
+				if err := c.write(c.buf.Bytes()); err != nil {
+					return err
+				}
+				c.buf.Reset()
+			}
+			c.buf.WriteString(line)
+		}
+	case Pickle:
+		names := make([]string, len(metrics))
+		for i, m := range metrics {
+			names[i] = c.name(m)
+		}
+		appendPickle(&c.buf, names, metrics)
+	default:
+		for _, m := range metrics {
+			c.buf.WriteString(c.line(m))
+		}
+	}
+	if c.buf.Len() == 0 {
+		return nil
+	}
+	return c.write(c.buf.Bytes())
+}
@@ -0,0 +1,281 @@
+package graphiteout
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/msaf1980/relaymon/pkg/checker"
+)
+
+// unpickle decode pickled list of (name, (timestamp, value)) tuples (only opcodes, used by appendPickle)
+func unpickle(b []byte) ([]string, error) {
+	if len(b) < 4 || !bytes.Equal(b[:4], []byte{opProto, 2, opEmptyList, opMark}) {
+		return nil, fmt.Errorf("invalid header")
+	}
+	r := bytes.NewReader(b[4:])
+	var (
+		out   []string
+		stack []string
+	)
+	for {
+		op, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case opBinUnicode:
+			var n uint32
+			_ = binary.Read(r, binary.LittleEndian, &n)
+			s := make([]byte, n)
+			if _, err = io.ReadFull(r, s); err != nil {
+				return nil, err
+			}
+			stack = append(stack, string(s))
+		case opBinInt:
+			var v int32
+			_ = binary.Read(r, binary.LittleEndian, &v)
+			stack = append(stack, strconv.Itoa(int(v)))
+		case opLong1:
+			n, _ := r.ReadByte()
+			if n != 8 {
+				return nil, fmt.Errorf("long1 size %d", n)
+			}
+			var v int64
+			_ = binary.Read(r, binary.LittleEndian, &v)
+			stack = append(stack, strconv.FormatInt(v, 10))
+		case opBinFloat:
+			var v uint64
+			_ = binary.Read(r, binary.BigEndian, &v)
+			stack = append(stack, strconv.FormatFloat(math.Float64frombits(v), 'f', -1, 64))
+		case opTuple2:
+			// (timestamp, value) -> "value timestamp", (name, "value timestamp") -> "name value timestamp"
+			n := len(stack)
+			if n < 2 {
+				return nil, fmt.Errorf("tuple2 on short stack")
+			}
+			if strings.Contains(stack[n-1], " ") {
+				stack = append(stack[:n-2], stack[n-2]+" "+stack[n-1])
+			} else {
+				stack = append(stack[:n-2], stack[n-1]+" "+stack[n-2])
+			}
+		case opAppends:
+			out, stack = stack, nil
+		case opStop:
+			return out, nil
+		default:
+			return nil, fmt.Errorf("unknown opcode %x", op)
+		}
+	}
+}
+
+// listen start local listener and return received metrics as plaintext lines
+func listen(t *testing.T, protocol string) (string, chan string, func()) {
+	received := make(chan string, 100)
+	if protocol == UDP {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			buf := make([]byte, 65536)
+			for {
+				n, _, err := conn.ReadFrom(buf)
+				if err != nil {
+					return
+				}
+				if n > maxDatagram {
+					received <- "oversized datagram"
+				}
+				for _, line := range strings.Split(strings.TrimSuffix(string(buf[:n]), "\n"), "\n") {
+					received <- line
+				}
+			}
+		}()
+		return conn.LocalAddr().String(), received, func() { conn.Close() }
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				if protocol == TCP {
+					scanner := bufio.NewScanner(conn)
+					for scanner.Scan() {
+						received <- scanner.Text()
+					}
+					return
+				}
+				for {
+					var size uint32
+					if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+						return
+					}
+					payload := make([]byte, size)
+					if _, err := io.ReadFull(conn, payload); err != nil {
+						return
+					}
+					lines, err := unpickle(payload)
+					if err != nil {
+						received <- err.Error()
+						return
+					}
+					for _, line := range lines {
+						received <- line
+					}
+				}
+			}(conn)
+		}
+	}()
+	return ln.Addr().String(), received, func() { ln.Close() }
+}
+
+func TestClient_Send(t *testing.T) {
+	metrics := []*Metric{
+		{
+			Name: "network.tcp.backend.127_0_0_1_2003", Value: "1", Timestamp: 1600000000,
+			Series: Series("tcp_target_state", checker.Label{Name: "check", Value: "backend"},
+				checker.Label{Name: "target", Value: "127.0.0.1:2003"}, checker.Label{Name: "host", Value: "relay1"}),
+		},
+		{Name: "status", Value: "3", Timestamp: 1600000001},
+	}
+	tests := []struct {
+		protocol string
+		tagged   bool
+		want     []string
+	}{
+		{
+			protocol: TCP,
+			want: []string{
+				"graphite.relaymon.relay1.network.tcp.backend.127_0_0_1_2003 1 1600000000",
+				"graphite.relaymon.relay1.status 3 1600000001",
+			},
+		},
+		{
+			protocol: TCP, tagged: true,
+			want: []string{
+				"graphite.relaymon.tcp_target_state;check=backend;target=127.0.0.1:2003;host=relay1 1 1600000000",
+				// without series
+				"graphite.relaymon.relay1.status 3 1600000001",
+			},
+		},
+		{
+			protocol: UDP,
+			want: []string{
+				"graphite.relaymon.relay1.network.tcp.backend.127_0_0_1_2003 1 1600000000",
+				"graphite.relaymon.relay1.status 3 1600000001",
+			},
+		},
+		{
+			protocol: Pickle, tagged: true,
+			want: []string{
+				"graphite.relaymon.tcp_target_state;check=backend;target=127.0.0.1:2003;host=relay1 1 1600000000",
+				"graphite.relaymon.relay1.status 3 1600000001",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s tagged=%v", tt.protocol, tt.tagged), func(t *testing.T) {
+			address, received, stop := listen(t, tt.protocol)
+			defer stop()
+
+			c, err := New(address, tt.protocol, "graphite.relaymon.relay1", tt.tagged, "graphite.relaymon", time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = c.Connect(); err != nil {
+				t.Fatal(err)
+			}
+			defer c.Disconnect()
+			if err = c.Send(metrics); err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]string, 0, len(tt.want))
+			timeout := time.After(5 * time.Second)
+			for len(got) < len(tt.want) {
+				select {
+				case line := <-received:
+					got = append(got, line)
+				case <-timeout:
+					t.Fatalf("metrics not received, got %q", got)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Client.Send() got\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_SendUDPSplit(t *testing.T) {
+	address, received, stop := listen(t, UDP)
+	defer stop()
+
+	c, err := New(address, UDP, "graphite.relaymon.relay1", false, "", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	n := 100
+	metrics := make([]*Metric, n)
+	for i := range metrics {
+		metrics[i] = &Metric{Name: "metric" + strconv.Itoa(i), Value: "1", Timestamp: 1600000000}
+	}
+	if err = c.Send(metrics); err != nil {
+		t.Fatal(err)
+	}
+	timeout := time.After(5 * time.Second)
+	for i := 0; i < n; i++ {
+		select {
+		case line := <-received:
+			if want := "graphite.relaymon.relay1.metric" + strconv.Itoa(i) + " 1 1600000000"; line != want {
+				t.Fatalf("Client.Send() got '%s', want '%s'", line, want)
+			}
+		case <-timeout:
+			t.Fatalf("metrics not received, got %d", i)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	c, err := New("127.0.0.1", TCP, "", false, "", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Address() != "127.0.0.1:2003" {
+		t.Errorf("New() address got '%s'", c.Address())
+	}
+	if _, err = New("127.0.0.1:2003", "http", "", false, "", time.Second); err == nil {
+		t.Errorf("New() must fail on unknown protocol")
+	}
+}
+
+func TestSeries(t *testing.T) {
+	got := Series("service_state", checker.Label{Name: "service", Value: "carbon c;relay"}, checker.Label{Name: "empthy"},
+		checker.Label{Name: "tag", Value: "~value"})
+	if want := "service_state;service=carbon_c_relay;tag=_value"; got != want {
+		t.Errorf("Series() got '%s', want '%s'", got, want)
+	}
+}
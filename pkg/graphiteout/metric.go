@@ -0,0 +1,45 @@
+package graphiteout
+
+import (
+	"strings"
+
+	"github.com/msaf1980/relaymon/pkg/checker"
+)
+
+// Metric is graphite metric (name and series are without prefix)
+type Metric struct {
+	// Name is dotted path, like network.tcp.backend.127_0_0_1_2003
+	Name string
+	// Series is tagged series, like tcp_target_state;check=backend;target=127.0.0.1:2003
+	Series    string
+	Value     string
+	Timestamp int64
+}
+
+var tagEscaper = strings.NewReplacer(" ", "_", ";", "_", "\t", "_", "\n", "_")
+
+// TagValue sanitize tag value (spaces and ';' are not allowed, '~' is not allowed at start)
+func TagValue(value string) string {
+	value = tagEscaper.Replace(value)
+	if strings.HasPrefix(value, "~") {
+		value = "_" + value[1:]
+	}
+	return value
+}
+
+// Series build tagged series name from name and labels (labels with empthy value are skipped)
+func Series(name string, labels ...checker.Label) string {
+	var sb strings.Builder
+	sb.WriteString(name)
+	for i := range labels {
+		value := TagValue(labels[i].Value)
+		if len(value) == 0 {
+			continue
+		}
+		sb.WriteString(";")
+		sb.WriteString(labels[i].Name)
+		sb.WriteString("=")
+		sb.WriteString(value)
+	}
+	return sb.String()
+}
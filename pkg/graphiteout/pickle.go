@@ -0,0 +1,57 @@
+package graphiteout
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strconv"
+)
+
+// pickle opcodes (protocol 2)
+const (
+	opProto      = 0x80
+	opEmptyList  = ']'
+	opMark       = '('
+	opAppends    = 'e'
+	opBinUnicode = 'X'
+	opBinInt     = 'J'
+	opLong1      = 0x8a
+	opBinFloat   = 'G'
+	opTuple2     = 0x86
+	opStop       = '.'
+)
+
+func pickleInt(buf *bytes.Buffer, v int64) {
+	if v >= math.MinInt32 && v <= math.MaxInt32 {
+		buf.WriteByte(opBinInt)
+		_ = binary.Write(buf, binary.LittleEndian, int32(v))
+		return
+	}
+	buf.WriteByte(opLong1)
+	buf.WriteByte(8)
+	_ = binary.Write(buf, binary.LittleEndian, v)
+}
+
+// appendPickle append pickled list of (name, (timestamp, value)) tuples with 4-byte length header
+// (graphite pickle protocol), metrics with non-numeric values are skipped
+func appendPickle(buf *bytes.Buffer, names []string, metrics []*Metric) {
+	start := buf.Len()
+	buf.Write([]byte{0, 0, 0, 0})
+	buf.Write([]byte{opProto, 2, opEmptyList, opMark})
+	for i, m := range metrics {
+		v, err := strconv.ParseFloat(m.Value, 64)
+		if err != nil {
+			continue
+		}
+		buf.WriteByte(opBinUnicode)
+		_ = binary.Write(buf, binary.LittleEndian, uint32(len(names[i])))
+		buf.WriteString(names[i])
+		pickleInt(buf, m.Timestamp)
+		buf.WriteByte(opBinFloat)
+		_ = binary.Write(buf, binary.BigEndian, math.Float64bits(v))
+		buf.WriteByte(opTuple2)
+		buf.WriteByte(opTuple2)
+	}
+	buf.Write([]byte{opAppends, opStop})
+	binary.BigEndian.PutUint32(buf.Bytes()[start:], uint32(buf.Len()-start-4))
+}
@@ -0,0 +1,54 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Locker describe flap lock
+type Locker interface {
+	// Locked return lock state and reason
+	Locked() (bool, string)
+	// Unlock clear lock, return true, if lock was set
+	Unlock() bool
+}
+
+// FlapLock describe flap lock state
+type FlapLock struct {
+	Locked bool   `json:"locked"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// FlapLockHandler serve flap lock state (GET) and clear it (POST or DELETE)
+type FlapLockHandler struct {
+	locker   Locker
+	onUnlock func(wasLocked bool)
+}
+
+// NewFlapLockHandler return new flap lock handler instance, onUnlock is called after unlock request (can be nil)
+func NewFlapLockHandler(locker Locker, onUnlock func(wasLocked bool)) *FlapLockHandler {
+	return &FlapLockHandler{locker: locker, onUnlock: onUnlock}
+}
+
+func (h *FlapLockHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPost, http.MethodDelete:
+		wasLocked := h.locker.Unlock()
+		if h.onUnlock != nil {
+			h.onUnlock(wasLocked)
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var lock FlapLock
+	lock.Locked, lock.Reason = h.locker.Locked()
+	b, err := json.Marshal(lock)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
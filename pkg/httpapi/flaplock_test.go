@@ -0,0 +1,65 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testLocker struct {
+	locked bool
+}
+
+func (l *testLocker) Locked() (bool, string) {
+	if l.locked {
+		return true, "flapping"
+	}
+	return false, ""
+}
+
+func (l *testLocker) Unlock() bool {
+	locked := l.locked
+	l.locked = false
+	return locked
+}
+
+func TestFlapLockHandler(t *testing.T) {
+	locker := &testLocker{locked: true}
+	var unlocked []bool
+	h := NewFlapLockHandler(locker, func(wasLocked bool) {
+		unlocked = append(unlocked, wasLocked)
+	})
+
+	tests := []struct {
+		method   string
+		wantCode int
+		want     FlapLock
+	}{
+		{http.MethodGet, http.StatusOK, FlapLock{Locked: true, Reason: "flapping"}},
+		{http.MethodPut, http.StatusMethodNotAllowed, FlapLock{}},
+		{http.MethodPost, http.StatusOK, FlapLock{}},
+		{http.MethodDelete, http.StatusOK, FlapLock{}},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(tt.method, "/flap_lock", nil))
+		if w.Code != tt.wantCode {
+			t.Errorf("%s /flap_lock code = %d, want %d", tt.method, w.Code, tt.wantCode)
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		var got FlapLock
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s /flap_lock = %+v, want %+v", tt.method, got, tt.want)
+		}
+	}
+	if len(unlocked) != 2 || !unlocked[0] || unlocked[1] {
+		t.Errorf("onUnlock calls = %v, want [true false]", unlocked)
+	}
+}
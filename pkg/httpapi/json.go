@@ -0,0 +1,30 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// JSONHandler serve value, returned by function, in JSON
+type JSONHandler struct {
+	value func() interface{}
+}
+
+// NewJSONHandler return new JSON handler instance
+func NewJSONHandler(value func() interface{}) *JSONHandler {
+	return &JSONHandler{value: value}
+}
+
+func (h *JSONHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	b, err := json.Marshal(h.value())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
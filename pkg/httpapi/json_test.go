@@ -0,0 +1,23 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJSONHandler(t *testing.T) {
+	h := NewJSONHandler(func() interface{} {
+		return []string{"relay1"}
+	})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/peers", nil))
+	if w.Code != http.StatusOK || w.Body.String() != `["relay1"]` {
+		t.Errorf("GET /peers = (%d, %s)", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/peers", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /peers code = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
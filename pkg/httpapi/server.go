@@ -0,0 +1,65 @@
+package httpapi
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Server embedded http server
+type Server struct {
+	mux    *http.ServeMux
+	server *http.Server
+	ln     net.Listener
+}
+
+// NewServer return new http server instance
+func NewServer(listen string) *Server {
+	mux := http.NewServeMux()
+	return &Server{
+		mux: mux,
+		server: &http.Server{
+			Addr:         listen,
+			Handler:      mux,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// Handle register handler for the given pattern
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Addr return listen address
+func (s *Server) Addr() string {
+	if s.ln == nil {
+		return s.server.Addr
+	}
+	return s.ln.Addr().String()
+}
+
+// Start listen and serve in background, errors (except listen error) passed to errFunc
+func (s *Server) Start(errFunc func(error)) error {
+	var err error
+	s.ln, err = net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		err := s.server.Serve(s.ln)
+		if err != nil && err != http.ErrServerClosed && errFunc != nil {
+			errFunc(err)
+		}
+	}()
+	return nil
+}
+
+// Stop gracefully shutdown server
+func (s *Server) Stop(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
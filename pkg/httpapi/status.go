@@ -0,0 +1,125 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/msaf1980/relaymon/pkg/checker"
+	"github.com/msaf1980/relaymon/pkg/netconf"
+)
+
+// CheckerStatus describe checker state
+type CheckerStatus struct {
+	Name     string           `json:"name"`
+	State    string           `json:"state"`
+	Code     checker.State    `json:"code"`
+	Events   []string         `json:"events"`
+	Counters checker.Counters `json:"counters"`
+	Advisory bool             `json:"advisory,omitempty"`
+}
+
+// IPStatus describe configured ip address
+type IPStatus struct {
+	Address string `json:"address"`
+	Up      bool   `json:"up"`
+}
+
+// Status describe relaymon state
+type Status struct {
+	Hostname  string          `json:"hostname"`
+	Timestamp int64           `json:"timestamp"`
+	State     string          `json:"state"`
+	Code      checker.State   `json:"code"`
+	Checkers  []CheckerStatus `json:"checkers"`
+	Iface     string          `json:"iface"`
+	IfaceErr  string          `json:"iface_error,omitempty"`
+	IPs       []IPStatus      `json:"ips"`
+	// Held is reason of held state transition
+	Held string `json:"held,omitempty"`
+}
+
+// StatusHandler serve relaymon status in JSON
+type StatusHandler struct {
+	lock   sync.RWMutex
+	status Status
+
+	addrs      []*net.IPNet
+	ifaceAddrs func(iface string) ([]net.Addr, error)
+}
+
+// NewStatusHandler return new status handler instance
+func NewStatusHandler(hostname string, iface string, addrs []*net.IPNet) *StatusHandler {
+	h := &StatusHandler{
+		addrs:      addrs,
+		ifaceAddrs: netconf.IfaceAddrs,
+	}
+	h.status.Hostname = hostname
+	h.status.Iface = iface
+	h.status.State = "collecting"
+	h.status.Code = checker.CollectingState
+	h.status.Checkers = []CheckerStatus{}
+	return h
+}
+
+// SetIPs set interface and ip addresses (on config reload)
+func (h *StatusHandler) SetIPs(iface string, addrs []*net.IPNet) {
+	h.lock.Lock()
+	h.status.Iface = iface
+	h.addrs = addrs
+	h.lock.Unlock()
+}
+
+// Update set global state and checkers state
+func (h *StatusHandler) Update(state checker.State, timestamp int64, checkers []CheckerStatus) {
+	h.lock.Lock()
+	h.status.Timestamp = timestamp
+	h.status.State = state.String()
+	h.status.Code = state
+	h.status.Checkers = checkers
+	h.lock.Unlock()
+}
+
+// SetHeld set reason of held state transition (empty, if not held)
+func (h *StatusHandler) SetHeld(reason string) {
+	h.lock.Lock()
+	h.status.Held = reason
+	h.lock.Unlock()
+}
+
+// Status return copy of current status (with actual ip addresses state)
+func (h *StatusHandler) Status() Status {
+	h.lock.RLock()
+	status := h.status
+	addrs := h.addrs
+	h.lock.RUnlock()
+
+	status.IPs = make([]IPStatus, len(addrs))
+	ifaceAddrs, err := h.ifaceAddrs(status.Iface)
+	if err != nil {
+		status.IfaceErr = err.Error()
+	}
+	for i := range addrs {
+		status.IPs[i].Address = addrs[i].String()
+		if err == nil {
+			status.IPs[i].Up = netconf.FindIPNet(addrs[i], ifaceAddrs)
+		}
+	}
+
+	return status
+}
+
+func (h *StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	b, err := json.Marshal(h.Status())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
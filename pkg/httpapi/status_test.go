@@ -0,0 +1,90 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/msaf1980/relaymon/pkg/checker"
+)
+
+func parseCIDR(t *testing.T, s string) *net.IPNet {
+	ip, addr, err := net.ParseCIDR(s)
+	if err != nil {
+		t.Fatalf("parse %s: %s", s, err.Error())
+	}
+	addr.IP = ip
+	return addr
+}
+
+func TestStatusHandler(t *testing.T) {
+	addrs := []*net.IPNet{parseCIDR(t, "192.168.155.10/24"), parseCIDR(t, "192.168.155.11/24")}
+
+	tests := []struct {
+		name      string
+		ifaceAddr []net.Addr
+		ifaceErr  error
+		wantIPs   []IPStatus
+		wantErr   string
+	}{
+		{
+			name:      "one up",
+			ifaceAddr: []net.Addr{parseCIDR(t, "127.0.0.1/8"), parseCIDR(t, "192.168.155.11/24")},
+			wantIPs:   []IPStatus{{"192.168.155.10/24", false}, {"192.168.155.11/24", true}},
+		},
+		{
+			name:     "iface error",
+			ifaceErr: fmt.Errorf("route ip+net: no such network interface"),
+			wantIPs:  []IPStatus{{"192.168.155.10/24", false}, {"192.168.155.11/24", false}},
+			wantErr:  "route ip+net: no such network interface",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewStatusHandler("test", "lo", addrs)
+			h.ifaceAddrs = func(iface string) ([]net.Addr, error) {
+				return tt.ifaceAddr, tt.ifaceErr
+			}
+			checkers := []CheckerStatus{
+				{
+					Name: "carbon-c-relay", State: "success", Code: checker.SuccessState,
+					Events: []string{}, Counters: checker.Counters{Success: 4, Checked: 6},
+				},
+			}
+			h.Update(checker.SuccessState, 10, checkers)
+
+			srv := NewServer("127.0.0.1:0")
+			srv.Handle("/status", h)
+			if err := srv.Start(nil); err != nil {
+				t.Fatalf("Server.Start() error = %s", err.Error())
+			}
+			defer srv.Stop(time.Second)
+
+			resp, err := http.Get("http://" + srv.Addr() + "/status")
+			if err != nil {
+				t.Fatalf("GET /status error = %s", err.Error())
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("GET /status code = %d", resp.StatusCode)
+			}
+			b, _ := ioutil.ReadAll(resp.Body)
+			var got Status
+			if err = json.Unmarshal(b, &got); err != nil {
+				t.Fatalf("unmarshal %s: %s", string(b), err.Error())
+			}
+			want := Status{
+				Hostname: "test", Timestamp: 10, State: "success", Code: checker.SuccessState,
+				Checkers: checkers, Iface: "lo", IfaceErr: tt.wantErr, IPs: tt.wantIPs,
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("GET /status got\n%+v\nwant\n%+v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package hysteresis
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Reason of held transition
+type Reason string
+
+const (
+	// None transition allowed
+	None Reason = ""
+	// MinHold transition held until minimum time in state elapsed
+	MinHold Reason = "min_hold"
+	// Backoff up transition held after repeated down transitions within window
+	Backoff Reason = "backoff"
+	// FlapLock up transition held until manual unlock
+	FlapLock Reason = "flap_lock"
+)
+
+// Config describe hysteresis options (disabled with zero values)
+type Config struct {
+	// MinHold is minimum time in state before up/down transition
+	MinHold time.Duration
+	// Window for count down transitions
+	Window time.Duration
+	// Backoff is base delay before up transition after repeated down transitions within window
+	// (doubled for every next transition)
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// FlapThreshold is down transitions count within window for flap lock (disabled if 0)
+	FlapThreshold int
+}
+
+// Damper limit up/down transitions rate
+type Damper struct {
+	cfg Config
+
+	lock       sync.Mutex
+	last       time.Time
+	downs      []time.Time
+	locked     bool
+	lockReason string
+}
+
+// New create damper
+func New(cfg Config) *Damper {
+	return &Damper{cfg: cfg}
+}
+
+// SetConfig set new options (on config reload), transitions history is preserved
+func (d *Damper) SetConfig(cfg Config) {
+	d.lock.Lock()
+	d.cfg = cfg
+	d.lock.Unlock()
+}
+
+func (d *Damper) expire(now time.Time) {
+	if d.cfg.Window <= 0 {
+		d.downs = d.downs[:0]
+		return
+	}
+	i := 0
+	for i < len(d.downs) && now.Sub(d.downs[i]) > d.cfg.Window {
+		i++
+	}
+	d.downs = d.downs[i:]
+}
+
+// delay return backoff delay for up transition after n down transitions within window
+func (d *Damper) delay(n int) time.Duration {
+	if n < 2 || d.cfg.Backoff <= 0 {
+		return 0
+	}
+	delay := d.cfg.Backoff
+	for i := 2; i < n; i++ {
+		if d.cfg.MaxBackoff > 0 && delay >= d.cfg.MaxBackoff {
+			break
+		}
+		delay *= 2
+	}
+	if d.cfg.MaxBackoff > 0 && delay > d.cfg.MaxBackoff {
+		return d.cfg.MaxBackoff
+	}
+	return delay
+}
+
+func (d *Damper) held(now time.Time, hold time.Duration) time.Duration {
+	if d.last.IsZero() || hold <= 0 {
+		return 0
+	}
+	if remain := hold - now.Sub(d.last); remain > 0 {
+		return remain
+	}
+	return 0
+}
+
+// CanDown check down transition, return reason and remaining time, if transition is held
+func (d *Damper) CanDown(now time.Time) (Reason, time.Duration) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if remain := d.held(now, d.cfg.MinHold); remain > 0 {
+		return MinHold, remain
+	}
+	return None, 0
+}
+
+// CanUp check up transition, return reason and remaining time (0 for flap lock), if transition is held
+func (d *Damper) CanUp(now time.Time) (Reason, time.Duration) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.locked {
+		return FlapLock, 0
+	}
+	d.expire(now)
+	if remain := d.held(now, d.cfg.MinHold); remain > 0 {
+		return MinHold, remain
+	}
+	if remain := d.held(now, d.delay(len(d.downs))); remain > 0 {
+		return Backoff, remain
+	}
+	return None, 0
+}
+
+// History is transitions history and flap lock state (persisted across restarts)
+type History struct {
+	// Last is time of last up/down transition
+	Last time.Time
+	// Downs is down transitions within window
+	Downs      []time.Time
+	Locked     bool
+	LockReason string
+}
+
+// Restore set transitions history and flap lock (persisted before restart)
+func (d *Damper) Restore(h History) {
+	d.lock.Lock()
+	d.last = h.Last
+	d.downs = append([]time.Time(nil), h.Downs...)
+	d.locked = h.Locked
+	d.lockReason = h.LockReason
+	d.lock.Unlock()
+}
+
+// History return transitions history and flap lock state
+func (d *Damper) History() History {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return History{
+		Last: d.last, Downs: append([]time.Time(nil), d.downs...), Locked: d.locked, LockReason: d.lockReason,
+	}
+}
+
+// Up record up transition
+func (d *Damper) Up(now time.Time) {
+	d.lock.Lock()
+	d.last = now
+	d.lock.Unlock()
+}
+
+// Down record down transition, return true, if flap lock is set
+func (d *Damper) Down(now time.Time) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.last = now
+	d.expire(now)
+	d.downs = append(d.downs, now)
+	if d.cfg.FlapThreshold > 0 && len(d.downs) >= d.cfg.FlapThreshold && !d.locked {
+		d.locked = true
+		d.lockReason = strconv.Itoa(len(d.downs)) + " down transitions in " + now.Sub(d.downs[0]).Round(time.Second).String()
+		return true
+	}
+	return false
+}
+
+// Locked return flap lock state and reason
+func (d *Damper) Locked() (bool, string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.locked, d.lockReason
+}
+
+// Unlock clear flap lock and transitions history, return true, if flap lock was set
+func (d *Damper) Unlock() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	locked := d.locked
+	d.locked = false
+	d.lockReason = ""
+	d.downs = d.downs[:0]
+	return locked
+}
@@ -0,0 +1,123 @@
+package hysteresis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDamperMinHold(t *testing.T) {
+	d := New(Config{MinHold: time.Minute})
+	now := time.Unix(1600000000, 0)
+
+	// first transition is not held
+	if reason, _ := d.CanUp(now); reason != None {
+		t.Fatalf("CanUp() = %s, want allowed", reason)
+	}
+	d.Up(now)
+
+	if reason, remain := d.CanDown(now.Add(10 * time.Second)); reason != MinHold || remain != 50*time.Second {
+		t.Errorf("CanDown() = (%s, %s), want (%s, 50s)", reason, remain, MinHold)
+	}
+	if reason, _ := d.CanDown(now.Add(time.Minute)); reason != None {
+		t.Errorf("CanDown() = %s, want allowed", reason)
+	}
+	d.Down(now.Add(time.Minute))
+	if reason, _ := d.CanUp(now.Add(90 * time.Second)); reason != MinHold {
+		t.Errorf("CanUp() = %s, want %s", reason, MinHold)
+	}
+}
+
+func TestDamperBackoff(t *testing.T) {
+	d := New(Config{Window: time.Hour, Backoff: time.Minute, MaxBackoff: 3 * time.Minute})
+	now := time.Unix(1600000000, 0)
+
+	tests := []struct {
+		remain time.Duration
+	}{
+		{0}, {time.Minute}, {2 * time.Minute}, {3 * time.Minute}, {3 * time.Minute},
+	}
+	for i, tt := range tests {
+		d.Up(now)
+		now = now.Add(time.Second)
+		d.Down(now)
+		reason, remain := d.CanUp(now)
+		if tt.remain == 0 {
+			if reason != None {
+				t.Errorf("[%d] CanUp() = %s, want allowed", i, reason)
+			}
+		} else if reason != Backoff || remain != tt.remain {
+			t.Errorf("[%d] CanUp() = (%s, %s), want (%s, %s)", i, reason, remain, Backoff, tt.remain)
+		}
+		now = now.Add(time.Second)
+	}
+
+	// transitions expired
+	now = now.Add(2 * time.Hour)
+	if reason, _ := d.CanUp(now); reason != None {
+		t.Errorf("CanUp() after window = %s, want allowed", reason)
+	}
+}
+
+func TestDamperFlapLock(t *testing.T) {
+	d := New(Config{Window: time.Hour, FlapThreshold: 3})
+	now := time.Unix(1600000000, 0)
+
+	for i := 0; i < 3; i++ {
+		d.Up(now)
+		now = now.Add(10 * time.Second)
+		locked := d.Down(now)
+		if locked != (i == 2) {
+			t.Errorf("[%d] Down() = %v", i, locked)
+		}
+	}
+	if locked, reason := d.Locked(); !locked || reason != "3 down transitions in 20s" {
+		t.Errorf("Locked() = (%v, %q)", locked, reason)
+	}
+	if reason, _ := d.CanUp(now.Add(2 * time.Hour)); reason != FlapLock {
+		t.Errorf("CanUp() = %s, want %s", reason, FlapLock)
+	}
+	// down transitions not held by flap lock
+	if reason, _ := d.CanDown(now); reason != None {
+		t.Errorf("CanDown() = %s, want allowed", reason)
+	}
+
+	if !d.Unlock() {
+		t.Errorf("Unlock() = false, want true")
+	}
+	if d.Unlock() {
+		t.Errorf("Unlock() = true, want false")
+	}
+	if reason, _ := d.CanUp(now); reason != None {
+		t.Errorf("CanUp() after unlock = %s, want allowed", reason)
+	}
+}
+
+func TestDamperRestore(t *testing.T) {
+	cfg := Config{Window: time.Hour, Backoff: time.Minute, MaxBackoff: 3 * time.Minute, FlapThreshold: 3}
+	d := New(cfg)
+	now := time.Unix(1600000000, 0)
+	for i := 0; i < 3; i++ {
+		d.Up(now)
+		now = now.Add(10 * time.Second)
+		d.Down(now)
+	}
+
+	// restart
+	restored := New(cfg)
+	restored.Restore(d.History())
+	if locked, reason := restored.Locked(); !locked || reason != "3 down transitions in 20s" {
+		t.Errorf("Locked() after restore = (%v, %q)", locked, reason)
+	}
+	if reason, _ := restored.CanUp(now.Add(2 * time.Hour)); reason != FlapLock {
+		t.Errorf("CanUp() after restore = %s, want %s", reason, FlapLock)
+	}
+
+	// down transitions history restored
+	h := d.History()
+	h.Locked, h.LockReason = false, ""
+	restored = New(cfg)
+	restored.Restore(h)
+	if reason, remain := restored.CanUp(now); reason != Backoff || remain != 2*time.Minute {
+		t.Errorf("CanUp() after restore = (%s, %s), want (%s, %s)", reason, remain, Backoff, 2*time.Minute)
+	}
+}
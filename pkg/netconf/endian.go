@@ -0,0 +1,18 @@
+package netconf
+
+import (
+	"encoding/binary"
+	"unsafe"
+)
+
+// nativeEndian is host byte order (netlink messages and attributes are encoded in host byte order)
+var nativeEndian binary.ByteOrder
+
+func init() {
+	var v uint16 = 1
+	if *(*byte)(unsafe.Pointer(&v)) == 1 {
+		nativeEndian = binary.LittleEndian
+	} else {
+		nativeEndian = binary.BigEndian
+	}
+}
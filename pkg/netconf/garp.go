@@ -0,0 +1,146 @@
+package netconf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	ethPArp  = 0x0806
+	ethPIPv6 = 0x86DD
+
+	arpRequest = 1
+
+	icmpv6NeighborAdvert = 136
+	// Neighbor Advertisement Override flag
+	naFlagOverride = 0x20
+	// Target Link-Layer Address option
+	ndOptTargetLLAddr = 2
+)
+
+var (
+	ethBroadcast    = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	ipv6AllNodes    = net.ParseIP("ff02::1")
+	ethIPv6AllNodes = net.HardwareAddr{0x33, 0x33, 0x00, 0x00, 0x00, 0x01}
+)
+
+// htons convert v to network byte order (value stored in host byte order)
+func htons(v uint16) uint16 {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	return nativeEndian.Uint16(b[:])
+}
+
+// arpPacket build gratuitous ARP request (sender and target protocol address is ip)
+func arpPacket(mac net.HardwareAddr, ip net.IP) []byte {
+	b := make([]byte, 28)
+	binary.BigEndian.PutUint16(b[0:2], syscall.ARPHRD_ETHER)
+	binary.BigEndian.PutUint16(b[2:4], syscall.ETH_P_IP)
+	b[4] = 6
+	b[5] = 4
+	binary.BigEndian.PutUint16(b[6:8], arpRequest)
+	copy(b[8:14], mac)
+	copy(b[14:18], ip.To4())
+	// target hardware address is zero
+	copy(b[24:28], ip.To4())
+	return b
+}
+
+func checksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(b[i : i+2]))
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
+
+// naPacket build IPv6 packet with unsolicited neighbor advertisement for ip (to all-nodes multicast)
+func naPacket(mac net.HardwareAddr, ip net.IP) []byte {
+	icmp := make([]byte, 32)
+	icmp[0] = icmpv6NeighborAdvert
+	icmp[4] = naFlagOverride
+	copy(icmp[8:24], ip.To16())
+	icmp[24] = ndOptTargetLLAddr
+	icmp[25] = 1
+	copy(icmp[26:32], mac)
+
+	b := make([]byte, 40, 40+len(icmp))
+	b[0] = 0x60
+	binary.BigEndian.PutUint16(b[4:6], uint16(len(icmp)))
+	b[6] = syscall.IPPROTO_ICMPV6
+	b[7] = 255
+	copy(b[8:24], ip.To16())
+	copy(b[24:40], ipv6AllNodes)
+
+	// pseudo-header checksum
+	pseudo := make([]byte, 40, 40+len(icmp))
+	copy(pseudo[0:32], b[8:40])
+	binary.BigEndian.PutUint32(pseudo[32:36], uint32(len(icmp)))
+	pseudo[39] = syscall.IPPROTO_ICMPV6
+	binary.BigEndian.PutUint16(icmp[2:4], checksum(append(pseudo, icmp...)))
+
+	return append(b, icmp...)
+}
+
+func sendPacket(i *net.Interface, proto uint16, dst net.HardwareAddr, packet []byte) error {
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, int(htons(proto)))
+	if err != nil {
+		return os.NewSyscallError("socket", err)
+	}
+	defer syscall.Close(fd)
+
+	addr := &syscall.SockaddrLinklayer{Protocol: htons(proto), Ifindex: i.Index, Halen: uint8(len(dst))}
+	copy(addr.Addr[:], dst)
+	if err = syscall.Sendto(fd, packet, 0, addr); err != nil {
+		return os.NewSyscallError("sendto", err)
+	}
+	return nil
+}
+
+// Announce send gratuitous ARP (for IPv4) or unsolicited neighbor advertisement (for IPv6) for ip addresses on interface.
+// Packets sent count times with interval. Loopback and non-ethernet interfaces are skipped.
+func Announce(iface string, addrs []*net.IPNet, count int, interval time.Duration) []error {
+	errs := make([]error, 0)
+	if count < 1 || len(addrs) == 0 {
+		return errs
+	}
+	i, err := net.InterfaceByName(iface)
+	if err != nil {
+		return append(errs, err)
+	}
+	if i.Flags&net.FlagLoopback != 0 || len(i.HardwareAddr) != 6 {
+		return errs
+	}
+
+	for n := 0; n < count; n++ {
+		if n > 0 {
+			time.Sleep(interval)
+		}
+		for _, addr := range addrs {
+			var err error
+			if ip := addr.IP.To4(); ip != nil {
+				err = sendPacket(i, ethPArp, ethBroadcast, arpPacket(i.HardwareAddr, ip))
+			} else {
+				err = sendPacket(i, ethPIPv6, ethIPv6AllNodes, naPacket(i.HardwareAddr, addr.IP))
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("announce %s dev %s: %s", addr.IP.String(), iface, err.Error()))
+			}
+		}
+		if len(errs) > 0 {
+			break
+		}
+	}
+
+	return errs
+}
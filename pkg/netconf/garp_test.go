@@ -0,0 +1,70 @@
+package netconf
+
+import (
+	"bytes"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func Test_arpPacket(t *testing.T) {
+	mac := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	got := arpPacket(mac, net.ParseIP("192.168.151.11"))
+	want := []byte{
+		0, 1, 8, 0, 6, 4, 0, 1,
+		0x02, 0, 0, 0, 0, 0x01, 192, 168, 151, 11,
+		0, 0, 0, 0, 0, 0, 192, 168, 151, 11,
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("arpPacket() =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func Test_naPacket(t *testing.T) {
+	mac := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	ip := net.ParseIP("fd00::11")
+	got := naPacket(mac, ip)
+	if len(got) != 72 {
+		t.Fatalf("naPacket() length = %d, want 72", len(got))
+	}
+	if got[0] != 0x60 || got[6] != 58 || got[7] != 255 {
+		t.Errorf("naPacket() invalid IPv6 header: %v", got[:8])
+	}
+	if !net.IP(got[8:24]).Equal(ip) || !net.IP(got[24:40]).Equal(ipv6AllNodes) {
+		t.Errorf("naPacket() invalid addresses: %v -> %v", net.IP(got[8:24]), net.IP(got[24:40]))
+	}
+	icmp := got[40:]
+	if icmp[0] != icmpv6NeighborAdvert || icmp[4] != naFlagOverride || !net.IP(icmp[8:24]).Equal(ip) {
+		t.Errorf("naPacket() invalid neighbor advertisement: %v", icmp)
+	}
+	if !bytes.Equal(icmp[26:32], mac) {
+		t.Errorf("naPacket() target link-layer address = %v, want %v", net.HardwareAddr(icmp[26:32]), mac)
+	}
+
+	// checksum with pseudo-header must be valid
+	pseudo := make([]byte, 40)
+	copy(pseudo[0:32], got[8:40])
+	pseudo[35] = byte(len(icmp))
+	pseudo[39] = 58
+	if sum := checksum(append(pseudo, icmp...)); sum != 0 {
+		t.Errorf("naPacket() invalid checksum, verify sum = 0x%x", sum)
+	}
+}
+
+func TestAnnounceLoopback(t *testing.T) {
+	_, addr, _ := net.ParseCIDR("192.168.151.11/32")
+	if errs := Announce("lo", []*net.IPNet{addr}, 3, time.Second); len(errs) > 0 {
+		t.Errorf("Announce() on loopback = %v", errs)
+	}
+}
+
+func Test_htons(t *testing.T) {
+	v := htons(syscall.ETH_P_ARP)
+	// network byte order in memory
+	b := (*[2]byte)(unsafe.Pointer(&v))
+	if b[0] != 0x08 || b[1] != 0x06 {
+		t.Errorf("htons(ETH_P_ARP) in memory = %x, want 0806", *b)
+	}
+}
@@ -7,10 +7,59 @@ import (
 	"os/exec"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
-func ipExec(iface string, addr string, scope string, add bool) (string, error, []string) {
+// Backend name for ip addresses configure
+const (
+	BackendNetlink = "netlink"
+	BackendIP      = "ip"
+)
+
+var (
+	backend     = BackendNetlink
+	addrOptions AddrOptions
+)
+
+// ParseBackend check ip addresses configure backend name (netlink, if empthy)
+func ParseBackend(name string) (string, error) {
+	switch name {
+	case "", BackendNetlink:
+		return BackendNetlink, nil
+	case BackendIP:
+		return BackendIP, nil
+	default:
+		return "", fmt.Errorf("unknown ip backend %s", name)
+	}
+}
+
+// SetBackend set ip addresses configure backend (netlink or ip, for exec iproute2 utility)
+func SetBackend(name string) error {
+	b, err := ParseBackend(name)
+	if err != nil {
+		return err
+	}
+	backend = b
+	return nil
+}
+
+// GetBackend return ip addresses configure backend
+func GetBackend() string {
+	return backend
+}
+
+// SetAddrOptions set options for added ip addresses
+func SetAddrOptions(opts AddrOptions) {
+	addrOptions = opts
+}
+
+// GetAddrOptions return options for added ip addresses
+func GetAddrOptions() AddrOptions {
+	return addrOptions
+}
+
+func ipExec(iface string, addr string, scope string, add bool, opts ...string) (string, error, []string) {
 	var ipArgs []string
 	if add {
 		if scope == "" {
@@ -26,6 +75,8 @@ func ipExec(iface string, addr string, scope string, add bool) (string, error, [
 		}
 	}
 
+	ipArgs = append(ipArgs, opts...)
+
 	var err error
 	ctx, cancel := context.WithTimeout(context.Background(), 180*time.Second)
 	defer cancel()
@@ -86,44 +137,64 @@ func IfaceAddrs(iface string) ([]net.Addr, error) {
 	return addrs, nil
 }
 
+func ipExecOpts(opts AddrOptions) (string, []string) {
+	var scope string
+	if opts.Scope != syscall.RT_SCOPE_UNIVERSE {
+		scope = scopeName(opts.Scope)
+	}
+	args := flagsNames(opts.Flags)
+	if len(opts.Label) > 0 {
+		args = append(args, "label", opts.Label)
+	}
+	return scope, args
+}
+
+func ipExecAddrs(iface string, a []*net.IPNet, opts AddrOptions, add bool) []error {
+	errs := make([]error, 0)
+	scope, args := ipExecOpts(opts)
+	for _, addr := range a {
+		netmask, _ := addr.Mask.Size()
+		ipAddr := addr.IP.String() + "/" + strconv.Itoa(netmask)
+		out, err, ipArgs := ipExec(iface, ipAddr, scope, add, args...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s with %s: %s", strings.Join(ipArgs, " "), err.Error(), out))
+		}
+	}
+	return errs
+}
+
 // IfaceAddrAdd configure ip addresses on interface (in ip/net format)
 func IfaceAddrAdd(iface string, a []*net.IPNet) []error {
-	errs := make([]error, 0)
 	addrs, err := IfaceAddrs(iface)
 	if err != nil {
-		errs = append(errs, err)
-		return errs
+		return []error{err}
 	}
+	add := make([]*net.IPNet, 0, len(a))
 	for _, addr := range a {
 		if !FindIPNet(addr, addrs) {
-			//fmt.Printf("%s\n", addr.String())
-			out, err, args := ipExec(iface, addr.String(), "", true)
-			if err != nil {
-				errs = append(errs, fmt.Errorf("%s with %s: %s", strings.Join(args, " "), err.Error(), out))
-			}
+			add = append(add, addr)
 		}
 	}
-	return errs
+	if backend == BackendIP {
+		return ipExecAddrs(iface, add, addrOptions, true)
+	}
+	return AddrAdd(iface, add, addrOptions)
 }
 
 // IfaceAddrDel remove ip addresses on interface
 func IfaceAddrDel(iface string, a []*net.IPNet) []error {
-	errs := make([]error, 0)
 	addrs, err := IfaceAddrs(iface)
 	if err != nil {
-		errs = append(errs, err)
-		return errs
+		return []error{err}
 	}
+	del := make([]*net.IPNet, 0, len(a))
 	for _, addr := range a {
 		if FindIPNet(addr, addrs) {
-			netmask, _ := addr.Mask.Size()
-			//fmt.Printf("%s\n", addr.IP.String())
-			delAddr := addr.IP.String() + "/" + strconv.Itoa(netmask)
-			out, err, args := ipExec(iface, delAddr, "", false)
-			if err != nil {
-				errs = append(errs, fmt.Errorf("%s with %s: %s", strings.Join(args, " "), err.Error(), out))
-			}
+			del = append(del, addr)
 		}
 	}
-	return errs
+	if backend == BackendIP {
+		return ipExecAddrs(iface, del, AddrOptions{}, false)
+	}
+	return AddrDel(iface, del)
 }
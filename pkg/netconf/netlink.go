@@ -0,0 +1,263 @@
+package netconf
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	// ifa_flags (include/uapi/linux/if_addr.h)
+	ifaFlagNoDad          = 0x02
+	ifaFlagOptimistic     = 0x04
+	ifaFlagHomeAddress    = 0x10
+	ifaFlagManageTempAddr = 0x100
+	ifaFlagNoPrefixRoute  = 0x200
+
+	// IFA_FLAGS attribute (u32 flags)
+	ifaFlags = 8
+
+	netlinkTimeout = 5 * time.Second
+)
+
+var (
+	addrScopes = map[string]uint8{
+		"global": syscall.RT_SCOPE_UNIVERSE, "site": syscall.RT_SCOPE_SITE,
+		"link": syscall.RT_SCOPE_LINK, "host": syscall.RT_SCOPE_HOST,
+	}
+	addrFlags = map[string]uint32{
+		"nodad": ifaFlagNoDad, "optimistic": ifaFlagOptimistic, "home": ifaFlagHomeAddress,
+		"mngtmpaddr": ifaFlagManageTempAddr, "noprefixroute": ifaFlagNoPrefixRoute,
+	}
+)
+
+// AddrOptions ip address options
+type AddrOptions struct {
+	// Label (IPv4 only, must be started with interface name, like lo:vip)
+	Label string
+	// Scope (see ParseScope)
+	Scope uint8
+	// Flags (see ParseFlags)
+	Flags uint32
+}
+
+// ParseScope parse address scope (global, site, link, host or number)
+func ParseScope(scope string) (uint8, error) {
+	if len(scope) == 0 {
+		return syscall.RT_SCOPE_UNIVERSE, nil
+	}
+	if s, ok := addrScopes[scope]; ok {
+		return s, nil
+	}
+	s, err := strconv.ParseUint(scope, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address scope %s", scope)
+	}
+	return uint8(s), nil
+}
+
+// ParseFlags parse address flags (nodad, optimistic, home, mngtmpaddr, noprefixroute)
+func ParseFlags(flags []string) (uint32, error) {
+	var f uint32
+	for i := range flags {
+		flag, ok := addrFlags[flags[i]]
+		if !ok {
+			return 0, fmt.Errorf("invalid address flag %s", flags[i])
+		}
+		f |= flag
+	}
+	return f, nil
+}
+
+func scopeName(scope uint8) string {
+	for name, s := range addrScopes {
+		if s == scope {
+			return name
+		}
+	}
+	return strconv.Itoa(int(scope))
+}
+
+func flagsNames(flags uint32) []string {
+	names := make([]string, 0)
+	for name, f := range addrFlags {
+		if flags&f != 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AddrError address configure error
+type AddrError struct {
+	Op    string
+	Iface string
+	Addr  string
+	Err   error
+}
+
+// Error get error description
+func (e *AddrError) Error() string {
+	return "ip addr " + e.Op + " " + e.Addr + " dev " + e.Iface + ": " + e.Err.Error()
+}
+
+func nlmAlign(n int) int {
+	return (n + syscall.NLMSG_ALIGNTO - 1) & ^(syscall.NLMSG_ALIGNTO - 1)
+}
+
+func rtaAlign(n int) int {
+	return (n + syscall.RTA_ALIGNTO - 1) & ^(syscall.RTA_ALIGNTO - 1)
+}
+
+func appendAttr(b []byte, typ uint16, data []byte) []byte {
+	l := syscall.SizeofRtAttr + len(data)
+	attr := make([]byte, rtaAlign(l))
+	nativeEndian.PutUint16(attr[0:2], uint16(l))
+	nativeEndian.PutUint16(attr[2:4], typ)
+	copy(attr[syscall.SizeofRtAttr:], data)
+	return append(b, attr...)
+}
+
+// addrMessage build RTM_NEWADDR/RTM_DELADDR netlink message
+func addrMessage(typ uint16, flags uint16, seq uint32, index int, addr *net.IPNet, opts AddrOptions) []byte {
+	family := uint8(syscall.AF_INET6)
+	ip := addr.IP.To4()
+	if ip == nil {
+		ip = addr.IP.To16()
+	} else {
+		family = syscall.AF_INET
+	}
+	prefixLen, _ := addr.Mask.Size()
+
+	b := make([]byte, syscall.SizeofNlMsghdr+syscall.SizeofIfAddrmsg)
+	// struct ifaddrmsg
+	msg := b[syscall.SizeofNlMsghdr:]
+	msg[0] = family
+	msg[1] = uint8(prefixLen)
+	msg[2] = uint8(opts.Flags & 0xff)
+	msg[3] = opts.Scope
+	nativeEndian.PutUint32(msg[4:8], uint32(index))
+
+	b = appendAttr(b, syscall.IFA_LOCAL, ip)
+	b = appendAttr(b, syscall.IFA_ADDRESS, ip)
+	if typ == syscall.RTM_NEWADDR {
+		if len(opts.Label) > 0 && family == syscall.AF_INET {
+			b = appendAttr(b, syscall.IFA_LABEL, append([]byte(opts.Label), 0))
+		}
+		if opts.Flags > 0xff {
+			f := make([]byte, 4)
+			nativeEndian.PutUint32(f, opts.Flags)
+			b = appendAttr(b, ifaFlags, f)
+		}
+	}
+
+	// struct nlmsghdr
+	nativeEndian.PutUint32(b[0:4], uint32(len(b)))
+	nativeEndian.PutUint16(b[4:6], typ)
+	nativeEndian.PutUint16(b[6:8], flags|syscall.NLM_F_REQUEST|syscall.NLM_F_ACK)
+	nativeEndian.PutUint32(b[8:12], seq)
+
+	return b
+}
+
+// parseAcks parse netlink responses, return errors by sequence number (nil for ack)
+func parseAcks(b []byte, acks map[uint32]error) error {
+	for len(b) >= syscall.SizeofNlMsghdr {
+		l := int(nativeEndian.Uint32(b[0:4]))
+		typ := nativeEndian.Uint16(b[4:6])
+		seq := nativeEndian.Uint32(b[8:12])
+		if l < syscall.SizeofNlMsghdr || l > len(b) {
+			return fmt.Errorf("netlink: invalid message length %d", l)
+		}
+		if typ == syscall.NLMSG_ERROR {
+			if l < syscall.SizeofNlMsghdr+4 {
+				return fmt.Errorf("netlink: invalid error message length %d", l)
+			}
+			errno := int32(nativeEndian.Uint32(b[syscall.SizeofNlMsghdr : syscall.SizeofNlMsghdr+4]))
+			if errno == 0 {
+				acks[seq] = nil
+			} else {
+				acks[seq] = syscall.Errno(-errno)
+			}
+		}
+		if l = nlmAlign(l); l > len(b) {
+			break
+		}
+		b = b[l:]
+	}
+	return nil
+}
+
+// netlinkAddrs send batch of address requests and wait for acks
+func netlinkAddrs(op string, iface string, typ uint16, flags uint16, addrs []*net.IPNet, opts AddrOptions) []error {
+	errs := make([]error, 0)
+	if len(addrs) == 0 {
+		return errs
+	}
+
+	i, err := net.InterfaceByName(iface)
+	if err != nil {
+		return append(errs, err)
+	}
+
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return append(errs, os.NewSyscallError("socket", err))
+	}
+	defer syscall.Close(fd)
+
+	tv := syscall.NsecToTimeval(int64(netlinkTimeout))
+	if err = syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		return append(errs, os.NewSyscallError("setsockopt", err))
+	}
+	if err = syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return append(errs, os.NewSyscallError("bind", err))
+	}
+
+	seqStart := uint32(time.Now().Unix())
+	req := make([]byte, 0, len(addrs)*64)
+	for n := range addrs {
+		req = append(req, addrMessage(typ, flags, seqStart+uint32(n), i.Index, addrs[n], opts)...)
+	}
+	if err = syscall.Sendto(fd, req, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return append(errs, os.NewSyscallError("sendto", err))
+	}
+
+	acks := make(map[uint32]error, len(addrs))
+	buf := make([]byte, syscall.Getpagesize())
+	for len(acks) < len(addrs) {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			return append(errs, os.NewSyscallError("recvfrom", err))
+		}
+		if err = parseAcks(buf[:n], acks); err != nil {
+			return append(errs, err)
+		}
+	}
+
+	for n := range addrs {
+		if err := acks[seqStart+uint32(n)]; err != nil {
+			errs = append(errs, &AddrError{Op: op, Iface: iface, Addr: addrs[n].String(), Err: err})
+		}
+	}
+
+	return errs
+}
+
+// AddrAdd add ip addresses on interface (batch with netlink)
+func AddrAdd(iface string, addrs []*net.IPNet, opts AddrOptions) []error {
+	return netlinkAddrs("add", iface, syscall.RTM_NEWADDR, syscall.NLM_F_CREATE|syscall.NLM_F_EXCL, addrs, opts)
+}
+
+// AddrDel remove ip addresses from interface (batch with netlink)
+func AddrDel(iface string, addrs []*net.IPNet) []error {
+	return netlinkAddrs("del", iface, syscall.RTM_DELADDR, 0, addrs, AddrOptions{})
+}
@@ -0,0 +1,168 @@
+package netconf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+func TestParseScope(t *testing.T) {
+	tests := []struct {
+		scope   string
+		want    uint8
+		wantErr bool
+	}{
+		{"", syscall.RT_SCOPE_UNIVERSE, false},
+		{"global", syscall.RT_SCOPE_UNIVERSE, false},
+		{"host", syscall.RT_SCOPE_HOST, false},
+		{"link", syscall.RT_SCOPE_LINK, false},
+		{"100", 100, false},
+		{"invalid", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			got, err := ParseScope(tt.scope)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseScope() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseScope() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	got, err := ParseFlags([]string{"nodad", "noprefixroute"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != ifaFlagNoDad|ifaFlagNoPrefixRoute {
+		t.Errorf("ParseFlags() = 0x%x", got)
+	}
+	names := flagsNames(got)
+	if len(names) != 2 || names[0] != "nodad" || names[1] != "noprefixroute" {
+		t.Errorf("flagsNames() = %v", names)
+	}
+	if _, err = ParseFlags([]string{"invalid"}); err == nil {
+		t.Errorf("ParseFlags() must fail for invalid flag")
+	}
+}
+
+func Test_addrMessageNativeEndian(t *testing.T) {
+	_, addr, _ := net.ParseCIDR("192.168.151.11/24")
+	b := addrMessage(syscall.RTM_NEWADDR, syscall.NLM_F_CREATE, 10, 1, addr, AddrOptions{})
+	// decode with host byte order
+	h := (*syscall.NlMsghdr)(unsafe.Pointer(&b[0]))
+	if h.Len != uint32(len(b)) || h.Type != syscall.RTM_NEWADDR || h.Seq != 10 ||
+		h.Flags != syscall.NLM_F_REQUEST|syscall.NLM_F_ACK|syscall.NLM_F_CREATE {
+		t.Errorf("addrMessage() nlmsghdr = %+v", *h)
+	}
+	attr := (*syscall.RtAttr)(unsafe.Pointer(&b[syscall.SizeofNlMsghdr+syscall.SizeofIfAddrmsg]))
+	if attr.Len != 8 || attr.Type != syscall.IFA_LOCAL {
+		t.Errorf("addrMessage() first rtattr = %+v", *attr)
+	}
+}
+
+func Test_addrMessage(t *testing.T) {
+	if nativeEndian != binary.LittleEndian {
+		t.Skip("wire dump in little-endian byte order")
+	}
+	_, addr, _ := net.ParseCIDR("192.168.151.11/24")
+	addr.IP = net.ParseIP("192.168.151.11")
+	opts := AddrOptions{Label: "lo:1", Scope: syscall.RT_SCOPE_HOST, Flags: ifaFlagNoDad | ifaFlagNoPrefixRoute}
+
+	got := addrMessage(syscall.RTM_NEWADDR, syscall.NLM_F_CREATE|syscall.NLM_F_EXCL, 10, 1, addr, opts)
+	want := []byte{
+		// nlmsghdr: len 60, RTM_NEWADDR, REQUEST|ACK|EXCL|CREATE, seq 10, pid 0
+		60, 0, 0, 0, 20, 0, 0x05, 0x06, 10, 0, 0, 0, 0, 0, 0, 0,
+		// ifaddrmsg: AF_INET, /24, flags nodad, scope host, index 1
+		2, 24, 0x02, 254, 1, 0, 0, 0,
+		// IFA_LOCAL
+		8, 0, 2, 0, 192, 168, 151, 11,
+		// IFA_ADDRESS
+		8, 0, 1, 0, 192, 168, 151, 11,
+		// IFA_LABEL
+		9, 0, 3, 0, 'l', 'o', ':', '1', 0, 0, 0, 0,
+		// IFA_FLAGS
+		8, 0, 8, 0, 0x02, 0x02, 0, 0,
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("addrMessage() =\n%v\nwant\n%v", got, want)
+	}
+
+	_, addr6, _ := net.ParseCIDR("fd00::1/128")
+	got = addrMessage(syscall.RTM_DELADDR, 0, 11, 2, addr6, opts)
+	if len(got) != 16+8+20+20 {
+		t.Fatalf("addrMessage() IPv6 delete length = %d", len(got))
+	}
+	if got[16] != syscall.AF_INET6 || got[17] != 128 {
+		t.Errorf("addrMessage() IPv6 family/prefix = %d/%d", got[16], got[17])
+	}
+}
+
+func Test_parseAcks(t *testing.T) {
+	if nativeEndian != binary.LittleEndian {
+		t.Skip("wire dump in little-endian byte order")
+	}
+	msg := func(seq byte, errno int32) []byte {
+		b := []byte{36, 0, 0, 0, 2, 0, 0, 0, seq, 0, 0, 0, 0, 0, 0, 0}
+		e := uint32(-errno)
+		b = append(b, byte(e), byte(e>>8), byte(e>>16), byte(e>>24))
+		return append(b, make([]byte, 16)...)
+	}
+	b := append(msg(1, 0), msg(2, int32(syscall.EEXIST))...)
+	acks := make(map[uint32]error)
+	if err := parseAcks(b, acks); err != nil {
+		t.Fatal(err)
+	}
+	if err, ok := acks[1]; !ok || err != nil {
+		t.Errorf("ack 1 = %v, %v", err, ok)
+	}
+	if err := acks[2]; err != syscall.EEXIST {
+		t.Errorf("ack 2 = %v, want %v", err, syscall.EEXIST)
+	}
+	if err := parseAcks([]byte{100, 0, 0, 0, 2, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0}, acks); err == nil {
+		t.Errorf("parseAcks() must fail on truncated message")
+	}
+}
+
+func TestAddrAddDel(t *testing.T) {
+	iface := "lo"
+	_, addr, _ := net.ParseCIDR("192.168.151.11/32")
+
+	errs := AddrAdd(iface, []*net.IPNet{addr}, AddrOptions{Label: "lo:relaymon"})
+	if len(errs) > 0 {
+		if ae, ok := errs[0].(*AddrError); ok && ae.Err == syscall.EPERM {
+			t.Skip(errs[0].Error())
+		}
+		t.Fatalf("AddrAdd() = %v", errs)
+	}
+	defer AddrDel(iface, []*net.IPNet{addr})
+
+	addrs, err := IfaceAddrs(iface)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !FindIPNet(addr, addrs) {
+		t.Fatalf("AddrAdd() %s not found on %s", addr.String(), iface)
+	}
+
+	errs = AddrAdd(iface, []*net.IPNet{addr}, AddrOptions{})
+	if len(errs) != 1 || errs[0].(*AddrError).Err != syscall.EEXIST {
+		t.Errorf("AddrAdd() duplicate = %v, want EEXIST", errs)
+	}
+
+	if errs = AddrDel(iface, []*net.IPNet{addr}); len(errs) > 0 {
+		t.Fatalf("AddrDel() = %v", errs)
+	}
+	if addrs, err = IfaceAddrs(iface); err != nil {
+		t.Fatal(err)
+	}
+	if FindIPNet(addr, addrs) {
+		t.Errorf("AddrDel() %s still found on %s", addr.String(), iface)
+	}
+}
@@ -0,0 +1,292 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	defaultBackoff = time.Second
+)
+
+// Checker state in notification
+type Checker struct {
+	Name   string   `json:"name"`
+	State  string   `json:"state"`
+	Events []string `json:"events,omitempty"`
+}
+
+// Action result in notification
+type Action struct {
+	Type     string `json:"type"`
+	Result   string `json:"result"`
+	Error    string `json:"error,omitempty"`
+	Attempts int    `json:"attempts"`
+}
+
+// IPs changes in notification
+type IPs struct {
+	Iface   string   `json:"iface"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// Payload of state transition notification
+type Payload struct {
+	Hostname  string    `json:"hostname"`
+	Timestamp int64     `json:"timestamp"`
+	OldState  string    `json:"old_state"`
+	NewState  string    `json:"new_state"`
+	Failing   []Checker `json:"failing"`
+	Actions   []Action  `json:"actions"`
+	IPs       IPs       `json:"ips"`
+}
+
+// Text return short human-readable description
+func (p *Payload) Text() string {
+	var sb strings.Builder
+	sb.WriteString("relaymon ")
+	sb.WriteString(p.Hostname)
+	sb.WriteString(": ")
+	sb.WriteString(p.OldState)
+	sb.WriteString(" -> ")
+	sb.WriteString(p.NewState)
+	for i, c := range p.Failing {
+		if i == 0 {
+			sb.WriteString("\nfailing: ")
+		} else {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(c.Name)
+		if len(c.Events) > 0 {
+			sb.WriteString(" (")
+			sb.WriteString(strings.Join(c.Events, "; "))
+			sb.WriteString(")")
+		}
+	}
+	for i, a := range p.Actions {
+		if i == 0 {
+			sb.WriteString("\nactions: ")
+		} else {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(a.Type)
+		sb.WriteString(" ")
+		sb.WriteString(a.Result)
+	}
+	if len(p.IPs.Added) > 0 {
+		sb.WriteString("\nadded: ")
+		sb.WriteString(strings.Join(p.IPs.Added, ", "))
+	}
+	if len(p.IPs.Removed) > 0 {
+		sb.WriteString("\nremoved: ")
+		sb.WriteString(strings.Join(p.IPs.Removed, ", "))
+	}
+	return sb.String()
+}
+
+// Webhook describe notification endpoint
+type Webhook struct {
+	URL string
+	// Format of body: generic (JSON payload), slack or telegram (ignored, if Template is set)
+	Format string
+	// Template for body (Go text/template with payload, Text method and json function)
+	Template string
+	// ChatID for telegram
+	ChatID  string
+	Headers map[string]string
+	Timeout time.Duration
+	// Retries count after failure (with exponential backoff)
+	Retries int
+	Backoff time.Duration
+
+	tmpl *template.Template
+}
+
+// marshal encode JSON without HTML escaping (for messengers text)
+func marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+var funcs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := marshal(v)
+		return string(b), err
+	},
+}
+
+// Body build request body for payload
+func (w *Webhook) Body(p *Payload) ([]byte, error) {
+	if w.tmpl != nil {
+		var buf bytes.Buffer
+		if err := w.tmpl.Execute(&buf, p); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	switch w.Format {
+	case "slack":
+		return marshal(map[string]string{"text": p.Text()})
+	case "telegram":
+		return marshal(map[string]string{"chat_id": w.ChatID, "text": p.Text()})
+	default:
+		return marshal(p)
+	}
+}
+
+func (w *Webhook) send(ctx context.Context, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, w.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s: %s", w.URL, resp.Status)
+	}
+	return nil
+}
+
+// Notifier send notifications to webhooks (in background)
+type Notifier struct {
+	hooks []*Webhook
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	onSend func(url string, err error)
+}
+
+// NewNotifier create notifier
+func NewNotifier(hooks []Webhook) (*Notifier, error) {
+	n := &Notifier{hooks: make([]*Webhook, len(hooks))}
+	for i := range hooks {
+		w := hooks[i]
+		if len(w.URL) == 0 {
+			return nil, fmt.Errorf("webhook url empthy")
+		}
+		switch w.Format {
+		case "", "generic", "slack":
+		case "telegram":
+			if len(w.ChatID) == 0 && len(w.Template) == 0 {
+				return nil, fmt.Errorf("webhook %s telegram chat_id empthy", w.URL)
+			}
+		default:
+			return nil, fmt.Errorf("webhook %s format %s unknown", w.URL, w.Format)
+		}
+		if len(w.Template) > 0 {
+			tmpl, err := template.New(w.URL).Funcs(funcs).Parse(w.Template)
+			if err != nil {
+				return nil, fmt.Errorf("webhook %s template: %s", w.URL, err.Error())
+			}
+			w.tmpl = tmpl
+		}
+		if w.Timeout <= 0 {
+			w.Timeout = defaultTimeout
+		}
+		if w.Backoff <= 0 {
+			w.Backoff = defaultBackoff
+		}
+		n.hooks[i] = &w
+	}
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+	return n, nil
+}
+
+// OnSend set callback for send results (called after retries, from background goroutine)
+func (n *Notifier) OnSend(f func(url string, err error)) {
+	n.onSend = f
+}
+
+// Notify send payload to all webhooks (in background, with retries)
+func (n *Notifier) Notify(p Payload) {
+	for _, w := range n.hooks {
+		body, err := w.Body(&p)
+		if err != nil {
+			n.result(w.URL, err)
+			continue
+		}
+		n.wg.Add(1)
+		go func(w *Webhook) {
+			defer n.wg.Done()
+			n.result(w.URL, n.deliver(w, body))
+		}(w)
+	}
+}
+
+func (n *Notifier) deliver(w *Webhook, body []byte) error {
+	backoff := w.Backoff
+	var err error
+	for i := 0; i <= w.Retries; i++ {
+		if i > 0 {
+			log.Debug().Str("notify", w.URL).Int("retry", i).Str("error", err.Error()).Msg("webhook retry")
+			select {
+			case <-n.ctx.Done():
+				return err
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		if err = w.send(n.ctx, body); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (n *Notifier) result(url string, err error) {
+	if err == nil {
+		log.Debug().Str("notify", url).Msg("webhook sent")
+	} else {
+		log.Error().Str("notify", url).Str("error", err.Error()).Msg("webhook failed")
+	}
+	if n.onSend != nil {
+		n.onSend(url, err)
+	}
+}
+
+// Close wait for pending notifications (up to timeout, after that retries are cancelled)
+func (n *Notifier) Close(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		n.cancel()
+		<-done
+	}
+	n.cancel()
+}
@@ -0,0 +1,141 @@
+package notify
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testPayload = Payload{
+	Hostname:  "relay1",
+	Timestamp: 1600000000,
+	OldState:  "success",
+	NewState:  "error",
+	Failing:   []Checker{{Name: "carbon-c-relay", State: "error", Events: []string{"service stopped"}}},
+	Actions:   []Action{{Type: "ip", Result: "success", Attempts: 1}},
+	IPs:       IPs{Iface: "lo", Removed: []string{"192.168.155.10/32"}},
+}
+
+// standIn collect webhook requests, first fails requests return 500
+type standIn struct {
+	lock   sync.Mutex
+	fails  int
+	bodies []string
+	ts     *httptest.Server
+}
+
+func newStandIn(fails int) *standIn {
+	s := &standIn{fails: fails}
+	s.ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		if s.fails > 0 {
+			s.fails--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		s.bodies = append(s.bodies, string(b))
+	}))
+	return s
+}
+
+func (s *standIn) received() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.bodies
+}
+
+func TestNotifier(t *testing.T) {
+	generic := newStandIn(2)
+	defer generic.ts.Close()
+	slack := newStandIn(0)
+	defer slack.ts.Close()
+	failed := newStandIn(10)
+	defer failed.ts.Close()
+
+	n, err := NewNotifier([]Webhook{
+		{URL: generic.ts.URL, Retries: 2, Backoff: 10 * time.Millisecond},
+		{URL: slack.ts.URL, Format: "slack"},
+		{URL: failed.ts.URL, Retries: 1, Backoff: 10 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		lock    sync.Mutex
+		results = make(map[string]error)
+	)
+	n.OnSend(func(url string, err error) {
+		lock.Lock()
+		results[url] = err
+		lock.Unlock()
+	})
+
+	n.Notify(testPayload)
+	n.Close(5 * time.Second)
+
+	if results[generic.ts.URL] != nil || results[slack.ts.URL] != nil || results[failed.ts.URL] == nil {
+		t.Errorf("Notify() results = %v", results)
+	}
+
+	bodies := generic.received()
+	if len(bodies) != 1 {
+		t.Fatalf("generic webhook received %d, want 1", len(bodies))
+	}
+	var p Payload
+	if err = json.Unmarshal([]byte(bodies[0]), &p); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, testPayload) {
+		t.Errorf("generic webhook payload got\n%+v\nwant\n%+v", p, testPayload)
+	}
+
+	bodies = slack.received()
+	want := `{"text":"relaymon relay1: success -> error\nfailing: carbon-c-relay (service stopped)\nactions: ip success\nremoved: 192.168.155.10/32"}`
+	if len(bodies) != 1 || bodies[0] != want {
+		t.Errorf("slack webhook got\n%v\nwant\n%s", bodies, want)
+	}
+}
+
+func TestWebhookBody(t *testing.T) {
+	n, err := NewNotifier([]Webhook{
+		{URL: "http://127.0.0.1/telegram", Format: "telegram", ChatID: "-100"},
+		{URL: "http://127.0.0.1/template", Template: `{"host":{{ json .Hostname }},"state":"{{ .NewState }}","failing":{{ len .Failing }}}`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := n.hooks[0].Body(&testPayload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var telegram map[string]string
+	if err = json.Unmarshal(b, &telegram); err != nil {
+		t.Fatal(err)
+	}
+	if telegram["chat_id"] != "-100" || telegram["text"] != testPayload.Text() {
+		t.Errorf("telegram body = %s", string(b))
+	}
+
+	b, err = n.hooks[1].Body(&testPayload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"host":"relay1","state":"error","failing":1}`; string(b) != want {
+		t.Errorf("template body = %s, want %s", string(b), want)
+	}
+
+	if _, err = NewNotifier([]Webhook{{URL: "http://127.0.0.1", Format: "telegram"}}); err == nil {
+		t.Errorf("NewNotifier() must fail without telegram chat_id")
+	}
+	if _, err = NewNotifier([]Webhook{{URL: "http://127.0.0.1", Template: "{{ .Invalid"}}); err == nil {
+		t.Errorf("NewNotifier() must fail with invalid template")
+	}
+}
@@ -0,0 +1,151 @@
+package peers
+
+import (
+	"strconv"
+	"time"
+)
+
+// ElectionConfig describe active/passive owner election options
+type ElectionConfig struct {
+	// Priority of node (node with highest priority is preferred owner, on equal priority - node with lowest name)
+	Priority int
+	// Preempt current owner with lower priority
+	Preempt bool
+	// PreemptDelay before preempt request
+	PreemptDelay time.Duration
+	// Quorum is minimal alive nodes count (with local node) for ownership (split-brain protection)
+	Quorum int
+}
+
+// Elector elect single owner between candidates (healthy nodes) with VRRP-like priorities.
+// Owner is released before new owner elected (on preemption or owners conflict after network partition).
+type Elector struct {
+	cfg   ElectionConfig
+	node  string
+	peers func() []Peer
+	start time.Time
+	wait  time.Duration
+	owner bool
+	// preempt request is sent after preempt delay (from preemptSince)
+	preempt      bool
+	preemptSince time.Time
+	reason       string
+}
+
+// NewElector create elector for gossip, ownership can't be acquired until peers timeout elapsed after start
+func NewElector(g *Gossip, cfg ElectionConfig) *Elector {
+	return newElector(g.cfg.Node, g.Peers, g.Timeout(), cfg, time.Now())
+}
+
+func newElector(node string, peers func() []Peer, wait time.Duration, cfg ElectionConfig, start time.Time) *Elector {
+	return &Elector{cfg: cfg, node: node, peers: peers, start: start, wait: wait}
+}
+
+// Restore set ownership (persisted before restart), restored owner not wait for peers
+func (e *Elector) Restore(owner bool) {
+	e.owner = owner
+}
+
+// SetConfig set new options (on config reload)
+func (e *Elector) SetConfig(cfg ElectionConfig) {
+	e.cfg = cfg
+}
+
+// better return true, if node a is better owner than node b
+func better(aPriority int, aNode string, bPriority int, bNode string) bool {
+	if aPriority != bPriority {
+		return aPriority > bPriority
+	}
+	return aNode < bNode
+}
+
+// Elect update ownership with local candidate flag (node is healthy and in service),
+// return ownership and reason (for logs)
+func (e *Elector) Elect(now time.Time, candidate bool) (bool, string) {
+	var alivePeers []Peer
+	for _, p := range e.peers() {
+		if p.Alive {
+			alivePeers = append(alivePeers, p)
+		}
+	}
+	owner, want := e.elect(now, candidate, alivePeers)
+	if !want {
+		e.preemptSince = time.Time{}
+	}
+	e.owner = owner
+	e.preempt = want && now.Sub(e.preemptSince) >= e.cfg.PreemptDelay
+	return e.owner, e.reason
+}
+
+func (e *Elector) elect(now time.Time, candidate bool, peers []Peer) (owner bool, want bool) {
+	if !candidate {
+		e.reason = "not candidate"
+		return false, false
+	}
+	if alive := len(peers) + 1; alive < e.cfg.Quorum {
+		e.reason = "no quorum, alive " + strconv.Itoa(alive) + " of " + strconv.Itoa(e.cfg.Quorum)
+		return false, false
+	}
+	if !e.owner && now.Sub(e.start) < e.wait {
+		e.reason = "waiting for peers"
+		return false, false
+	}
+
+	var currentOwner *Peer
+	for i := range peers {
+		p := &peers[i]
+		if !p.Candidate {
+			continue
+		}
+		if p.Owner && (currentOwner == nil || better(p.Priority, p.Node, currentOwner.Priority, currentOwner.Node)) {
+			currentOwner = p
+		}
+	}
+
+	if e.owner {
+		if currentOwner != nil && better(currentOwner.Priority, currentOwner.Node, e.cfg.Priority, e.node) {
+			e.reason = "owner conflict, released for " + currentOwner.Node
+			return false, false
+		}
+		for i := range peers {
+			p := &peers[i]
+			if p.Candidate && p.Preempt && better(p.Priority, p.Node, e.cfg.Priority, e.node) {
+				e.reason = "preempted by " + p.Node
+				return false, false
+			}
+		}
+		e.reason = "owner"
+		return true, false
+	}
+
+	if currentOwner != nil {
+		if e.cfg.Preempt && e.cfg.Priority > currentOwner.Priority {
+			if e.preemptSince.IsZero() {
+				e.preemptSince = now
+			}
+			e.reason = "preempt owner " + currentOwner.Node
+			return false, true
+		}
+		e.reason = "backup, owner " + currentOwner.Node
+		return false, false
+	}
+
+	// no owner, elect best candidate
+	for i := range peers {
+		p := &peers[i]
+		if p.Candidate && better(p.Priority, p.Node, e.cfg.Priority, e.node) {
+			e.reason = "backup, best candidate " + p.Node
+			return false, false
+		}
+	}
+	e.reason = "elected"
+	return true, false
+}
+
+// Fill set election state in local state (for send to peers)
+func (e *Elector) Fill(s *State, candidate bool) {
+	s.Priority = e.cfg.Priority
+	s.Candidate = candidate
+	s.Owner = e.owner
+	s.Preempt = e.preempt
+}
@@ -0,0 +1,201 @@
+package peers
+
+import (
+	"testing"
+	"time"
+)
+
+// cluster simulate synchronous states exchange between electors
+type cluster struct {
+	nodes     []string
+	electors  []*Elector
+	states    []State
+	candidate []bool
+	// link[i][j] is true, if node i receive states from node j
+	link [][]bool
+	now  time.Time
+}
+
+func newCluster(start time.Time, cfgs ...ElectionConfig) *cluster {
+	c := &cluster{now: start}
+	n := len(cfgs)
+	c.nodes = make([]string, n)
+	c.electors = make([]*Elector, n)
+	c.states = make([]State, n)
+	c.candidate = make([]bool, n)
+	c.link = make([][]bool, n)
+	for i := range cfgs {
+		i := i
+		c.nodes[i] = "relay" + string(rune('1'+i))
+		c.link[i] = make([]bool, n)
+		for j := range c.link[i] {
+			c.link[i][j] = true
+		}
+		c.electors[i] = newElector(c.nodes[i], func() []Peer { return c.peers(i) }, 3*time.Second, cfgs[i], start)
+		c.candidate[i] = true
+	}
+	return c
+}
+
+func (c *cluster) peers(i int) []Peer {
+	var peers []Peer
+	for j := range c.states {
+		if j != i && c.link[i][j] && c.states[j].Node != "" {
+			peers = append(peers, Peer{State: c.states[j], Alive: true})
+		}
+	}
+	return peers
+}
+
+// round elect on all nodes with previous round states, return owners
+func (c *cluster) round() []string {
+	c.now = c.now.Add(time.Second)
+	states := make([]State, len(c.states))
+	var owners []string
+	for i, e := range c.electors {
+		owner, _ := e.Elect(c.now, c.candidate[i])
+		states[i] = State{Node: c.nodes[i]}
+		e.Fill(&states[i], c.candidate[i])
+		if owner {
+			owners = append(owners, c.nodes[i])
+		}
+	}
+	c.states = states
+	return owners
+}
+
+// run rounds, check that no more than one owner in connected cluster, return last owners
+func (c *cluster) run(t *testing.T, rounds int, single bool) []string {
+	var owners []string
+	for r := 0; r < rounds; r++ {
+		owners = c.round()
+		if single && len(owners) > 1 {
+			t.Fatalf("round %d: several owners %v", r, owners)
+		}
+	}
+	return owners
+}
+
+func TestElector(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	c := newCluster(start,
+		ElectionConfig{Priority: 100, Quorum: 2},
+		ElectionConfig{Priority: 50, Quorum: 2},
+		ElectionConfig{Priority: 50, Quorum: 2},
+	)
+
+	// wait for peers after start
+	if owners := c.run(t, 2, true); len(owners) != 0 {
+		t.Fatalf("owners before peers wait = %v", owners)
+	}
+	if owners := c.run(t, 3, true); len(owners) != 1 || owners[0] != "relay1" {
+		t.Fatalf("owners = %v, want [relay1]", owners)
+	}
+	if _, reason := c.electors[1].Elect(c.now, true); reason != "backup, owner relay1" {
+		t.Errorf("backup reason = %q", reason)
+	}
+
+	// owner failed, relay2 (equal priority with relay3, but lower name) elected
+	c.candidate[0] = false
+	if owners := c.run(t, 3, true); len(owners) != 1 || owners[0] != "relay2" {
+		t.Fatalf("owners after failure = %v, want [relay2]", owners)
+	}
+
+	// relay1 recovered, but preempt disabled
+	c.candidate[0] = true
+	if owners := c.run(t, 5, true); len(owners) != 1 || owners[0] != "relay2" {
+		t.Fatalf("owners without preempt = %v, want [relay2]", owners)
+	}
+
+	// preempt enabled (with delay)
+	c.electors[0].SetConfig(ElectionConfig{Priority: 100, Quorum: 2, Preempt: true, PreemptDelay: 2 * time.Second})
+	if owners := c.run(t, 2, true); len(owners) != 1 || owners[0] != "relay2" {
+		t.Fatalf("owners before preempt delay = %v, want [relay2]", owners)
+	}
+	if owners := c.run(t, 4, true); len(owners) != 1 || owners[0] != "relay1" {
+		t.Fatalf("owners after preempt = %v, want [relay1]", owners)
+	}
+}
+
+func TestElectorPartition(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	c := newCluster(start,
+		ElectionConfig{Priority: 100, Quorum: 2},
+		ElectionConfig{Priority: 50, Quorum: 2},
+		ElectionConfig{Priority: 10, Quorum: 2},
+	)
+	if owners := c.run(t, 5, true); len(owners) != 1 || owners[0] != "relay1" {
+		t.Fatalf("owners = %v, want [relay1]", owners)
+	}
+
+	// relay1 isolated: no quorum, owner released, relay2 elected in majority partition
+	for j := range c.link {
+		c.link[0][j], c.link[j][0] = false, false
+	}
+	if owners := c.run(t, 3, true); len(owners) != 1 || owners[0] != "relay2" {
+		t.Fatalf("owners in partition = %v, want [relay2]", owners)
+	}
+	if _, reason := c.electors[0].Elect(c.now, true); reason != "no quorum, alive 1 of 2" {
+		t.Errorf("isolated reason = %q", reason)
+	}
+
+	// partition without quorum protection: both partitions elect owner, conflict resolved after heal
+	for i := range c.electors {
+		c.electors[i].SetConfig(ElectionConfig{Priority: c.electors[i].cfg.Priority, Quorum: 1})
+	}
+	if owners := c.run(t, 3, false); len(owners) != 2 {
+		t.Fatalf("owners in partition without quorum = %v, want 2", owners)
+	}
+	for j := range c.link {
+		c.link[0][j], c.link[j][0] = true, true
+	}
+	c.round()
+	if owners := c.run(t, 3, true); len(owners) != 1 || owners[0] != "relay1" {
+		t.Fatalf("owners after heal = %v, want [relay1]", owners)
+	}
+}
+
+func TestElectorGossip(t *testing.T) {
+	addrs := freeUDPAddrs(t, 2)
+	var (
+		gossips  [2]*Gossip
+		electors [2]*Elector
+	)
+	for i := range gossips {
+		g, err := New(Config{Node: "relay" + string(rune('1'+i)), Listen: addrs[i], Peers: []string{addrs[1-i]}, Interval: 20 * time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer g.Close()
+		g.Start()
+		gossips[i] = g
+		electors[i] = NewElector(g, ElectionConfig{Priority: 100 - i, Quorum: 2})
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var owners []int
+		for i := range electors {
+			owner, _ := electors[i].Elect(time.Now(), true)
+			s := State{State: "success", Up: true}
+			electors[i].Fill(&s, true)
+			gossips[i].Update(s)
+			if owner {
+				owners = append(owners, i)
+			}
+		}
+		if len(owners) > 1 {
+			t.Fatalf("several owners")
+		}
+		if len(owners) == 1 {
+			if owners[0] != 0 {
+				t.Fatalf("owner relay%d, want relay1", owners[0]+1)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("owner not elected")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
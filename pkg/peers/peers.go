@@ -0,0 +1,293 @@
+package peers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultInterval = time.Second
+	maxMessageSize  = 8192
+)
+
+// State is node state, sent to peers
+type State struct {
+	Node string `json:"node"`
+	// State is aggregated checkers state (collecting, success, warn, error)
+	State string `json:"state"`
+	// Up is true, if node in service (ip addresses configured/routes announced)
+	Up bool `json:"up"`
+	// Failing checkers names
+	Failing   []string `json:"failing,omitempty"`
+	Timestamp int64    `json:"timestamp"`
+	// Seq is message sequence number (increased for every message, started from start time in nanoseconds,
+	// so increased across restarts), for replay protection
+	Seq uint64 `json:"seq"`
+
+	// Election state (active/passive mode)
+	Priority  int  `json:"priority,omitempty"`
+	Candidate bool `json:"candidate,omitempty"`
+	Owner     bool `json:"owner,omitempty"`
+	// Preempt request from candidate with higher priority
+	Preempt bool `json:"preempt,omitempty"`
+}
+
+// Healthy return true, if node checks not failed
+func (s *State) Healthy() bool {
+	return s.State == "success" || s.State == "warn"
+}
+
+// Peer is last received peer state
+type Peer struct {
+	State
+	Address  string    `json:"address"`
+	LastSeen time.Time `json:"last_seen"`
+	Alive    bool      `json:"alive"`
+}
+
+// Config describe gossip options
+type Config struct {
+	// Node name (must be unique in peers group)
+	Node string
+	// Listen address for UDP messages
+	Listen string
+	// Peers addresses (host:port)
+	Peers []string
+	// Interval between state messages
+	Interval time.Duration
+	// Timeout after last message for peer dead (3 intervals, if 0)
+	Timeout time.Duration
+	// Secret for messages authentication (HMAC-SHA256), disabled if empty
+	Secret string
+}
+
+// Gossip send local state to peers and receive peers states over UDP
+type Gossip struct {
+	cfg   Config
+	conn  *net.UDPConn
+	addrs []*net.UDPAddr
+
+	lock  sync.RWMutex
+	local State
+	peers map[string]*Peer
+
+	// seq is last sent message sequence number
+	seq uint64
+
+	wake chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New create gossip and bind listen address
+func New(cfg Config) (*Gossip, error) {
+	if len(cfg.Node) == 0 {
+		return nil, fmt.Errorf("peers: node name empthy")
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 3 * cfg.Interval
+	}
+	g := &Gossip{
+		cfg:   cfg,
+		addrs: make([]*net.UDPAddr, len(cfg.Peers)),
+		local: State{Node: cfg.Node, State: "collecting"},
+		peers: make(map[string]*Peer),
+		seq:   uint64(time.Now().UnixNano()),
+		wake:  make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	for i := range cfg.Peers {
+		addr, err := net.ResolveUDPAddr("udp", cfg.Peers[i])
+		if err != nil {
+			return nil, fmt.Errorf("peers: %s", err.Error())
+		}
+		g.addrs[i] = addr
+	}
+	laddr, err := net.ResolveUDPAddr("udp", cfg.Listen)
+	if err != nil {
+		return nil, fmt.Errorf("peers: %s", err.Error())
+	}
+	if g.conn, err = net.ListenUDP("udp", laddr); err != nil {
+		return nil, fmt.Errorf("peers: %s", err.Error())
+	}
+	return g, nil
+}
+
+// Timeout return peer dead timeout
+func (g *Gossip) Timeout() time.Duration {
+	return g.cfg.Timeout
+}
+
+// Addr return listen address
+func (g *Gossip) Addr() string {
+	return g.conn.LocalAddr().String()
+}
+
+// Start send and receive states in background
+func (g *Gossip) Start() {
+	g.wg.Add(2)
+	go g.receive()
+	go g.send()
+}
+
+// Update set local state and send it to peers immediately
+func (g *Gossip) Update(s State) {
+	s.Node = g.cfg.Node
+	g.lock.Lock()
+	g.local = s
+	g.lock.Unlock()
+	select {
+	case g.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Peers return peers states (sorted by node name)
+func (g *Gossip) Peers() []Peer {
+	now := time.Now()
+	g.lock.RLock()
+	peers := make([]Peer, 0, len(g.peers))
+	for _, p := range g.peers {
+		peer := *p
+		peer.Alive = now.Sub(p.LastSeen) <= g.cfg.Timeout
+		peers = append(peers, peer)
+	}
+	g.lock.RUnlock()
+	sort.Slice(peers, func(i, j int) bool { return peers[i].Node < peers[j].Node })
+	return peers
+}
+
+// Close stop gossip
+func (g *Gossip) Close() error {
+	close(g.done)
+	err := g.conn.Close()
+	g.wg.Wait()
+	return err
+}
+
+func (g *Gossip) sign(b []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(g.cfg.Secret))
+	_, _ = mac.Write(b)
+	return mac.Sum(nil)
+}
+
+// encode message: JSON state, prefixed with HMAC-SHA256, if secret set
+func (g *Gossip) encode(s *State) ([]byte, error) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(g.cfg.Secret) == 0 {
+		return b, nil
+	}
+	return append(g.sign(b), b...), nil
+}
+
+func (g *Gossip) decode(b []byte) (*State, error) {
+	if len(g.cfg.Secret) > 0 {
+		if len(b) < sha256.Size {
+			return nil, fmt.Errorf("message too short")
+		}
+		if !hmac.Equal(b[:sha256.Size], g.sign(b[sha256.Size:])) {
+			return nil, fmt.Errorf("message signature mismatch")
+		}
+		b = b[sha256.Size:]
+	}
+	var s State
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	if len(s.Node) == 0 {
+		return nil, fmt.Errorf("message node empthy")
+	}
+	return &s, nil
+}
+
+// check message for replay protection: message timestamp must be within timeout of local clock
+// and sequence number must be greater than in last accepted message from node (caller must hold lock)
+func (g *Gossip) check(s *State, now time.Time) error {
+	ts := time.Unix(s.Timestamp, 0)
+	if d := now.Sub(ts); d > g.cfg.Timeout || d < -g.cfg.Timeout {
+		return fmt.Errorf("message timestamp %s out of timeout", ts.Format(time.RFC3339))
+	}
+	if p, ok := g.peers[s.Node]; ok && s.Seq <= p.Seq {
+		return fmt.Errorf("message sequence %d not greater than last accepted %d", s.Seq, p.Seq)
+	}
+	return nil
+}
+
+func (g *Gossip) send() {
+	defer g.wg.Done()
+	ticker := time.NewTicker(g.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		g.lock.RLock()
+		s := g.local
+		g.lock.RUnlock()
+		s.Timestamp = time.Now().Unix()
+		g.seq++
+		s.Seq = g.seq
+		b, err := g.encode(&s)
+		if err == nil {
+			for _, addr := range g.addrs {
+				if _, err := g.conn.WriteToUDP(b, addr); err != nil {
+					log.Debug().Str("peers", addr.String()).Str("error", err.Error()).Msg("send failed")
+				}
+			}
+		} else {
+			log.Error().Str("peers", "encode").Msg(err.Error())
+		}
+		select {
+		case <-g.done:
+			return
+		case <-g.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (g *Gossip) receive() {
+	defer g.wg.Done()
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := g.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-g.done:
+				return
+			default:
+			}
+			log.Error().Str("peers", "receive").Msg(err.Error())
+			continue
+		}
+		s, err := g.decode(buf[:n])
+		if err != nil {
+			log.Warn().Str("peers", addr.String()).Str("error", err.Error()).Msg("invalid message")
+			continue
+		}
+		if s.Node == g.cfg.Node {
+			log.Warn().Str("peers", addr.String()).Msg("message with local node name")
+			continue
+		}
+		now := time.Now()
+		g.lock.Lock()
+		if err = g.check(s, now); err == nil {
+			g.peers[s.Node] = &Peer{State: *s, Address: addr.String(), LastSeen: now}
+		}
+		g.lock.Unlock()
+		if err != nil {
+			log.Warn().Str("peers", addr.String()).Str("node", s.Node).Str("error", err.Error()).Msg("message rejected")
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package peers
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// freeUDPAddrs reserve localhost UDP addresses
+func freeUDPAddrs(t *testing.T, n int) []string {
+	addrs := make([]string, n)
+	for i := range addrs {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrs[i] = conn.LocalAddr().String()
+		conn.Close()
+	}
+	return addrs
+}
+
+func waitPeers(t *testing.T, g *Gossip, check func(peers []Peer) bool) []Peer {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		peers := g.Peers()
+		if check(peers) {
+			return peers
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s: peers not converged: %+v", g.cfg.Node, peers)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGossip(t *testing.T) {
+	addrs := freeUDPAddrs(t, 3)
+	nodes := make([]*Gossip, 3)
+	for i := range nodes {
+		var peers []string
+		for j := range addrs {
+			if i != j {
+				peers = append(peers, addrs[j])
+			}
+		}
+		g, err := New(Config{
+			Node: "relay" + string(rune('1'+i)), Listen: addrs[i], Peers: peers,
+			Interval: 50 * time.Millisecond, Secret: "secret",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.Start()
+		nodes[i] = g
+	}
+	defer func() {
+		for i := range nodes {
+			if nodes[i] != nil {
+				nodes[i].Close()
+			}
+		}
+	}()
+
+	nodes[0].Update(State{State: "success", Up: true})
+	nodes[1].Update(State{State: "error", Up: true, Failing: []string{"clickhouse"}})
+	nodes[2].Update(State{State: "warn", Up: true})
+
+	peers := waitPeers(t, nodes[0], func(peers []Peer) bool {
+		return len(peers) == 2 && peers[0].State.State == "error" && peers[1].State.State == "warn"
+	})
+	if peers[0].Node != "relay2" || !peers[0].Alive || !peers[0].Up || len(peers[0].Failing) != 1 || peers[0].Address != addrs[1] {
+		t.Errorf("peer relay2 = %+v", peers[0])
+	}
+	if ok, reason := AllowWithdraw(PolicyMinHealthy, 2, peers, time.Time{}); ok {
+		t.Errorf("AllowWithdraw() with 1 healthy peer allowed")
+	} else if reason != "1 healthy peers remain, min 2" {
+		t.Errorf("AllowWithdraw() reason = %q", reason)
+	}
+
+	// relay3 dead
+	nodes[2].Close()
+	nodes[2] = nil
+	waitPeers(t, nodes[0], func(peers []Peer) bool {
+		return len(peers) == 2 && !peers[1].Alive
+	})
+	if ok, _ := AllowWithdraw(PolicyLocalOnly, 0, nodes[0].Peers(), time.Time{}); ok {
+		t.Errorf("AllowWithdraw() with failed alive peers allowed")
+	}
+}
+
+func TestGossipSecret(t *testing.T) {
+	addrs := freeUDPAddrs(t, 2)
+	a, err := New(Config{Node: "a", Listen: addrs[0], Peers: []string{addrs[1]}, Interval: 20 * time.Millisecond, Secret: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := New(Config{Node: "b", Listen: addrs[1], Peers: []string{addrs[0]}, Interval: 20 * time.Millisecond, Secret: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	a.Start()
+	b.Start()
+
+	time.Sleep(200 * time.Millisecond)
+	if peers := a.Peers(); len(peers) != 0 {
+		t.Errorf("peers with other secret accepted: %+v", peers)
+	}
+}
+
+func TestGossipReplay(t *testing.T) {
+	g := &Gossip{cfg: Config{Timeout: 3 * time.Second}, peers: make(map[string]*Peer)}
+	now := time.Unix(1600000000, 0)
+	g.peers["a"] = &Peer{State: State{Node: "a", Timestamp: now.Unix() - 1, Seq: 10}}
+
+	tests := []struct {
+		name      string
+		timestamp int64
+		seq       uint64
+		wantErr   bool
+	}{
+		{"next", now.Unix(), 11, false},
+		{"next in same second", now.Unix() - 1, 11, false},
+		{"replay", now.Unix() - 1, 10, true},
+		{"older than last", now.Unix() - 2, 9, true},
+		{"expired", now.Unix() - 10, 11, true},
+		{"future", now.Unix() + 10, 11, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := g.check(&State{Node: "a", Timestamp: tt.timestamp, Seq: tt.seq}, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	if err := g.check(&State{Node: "b", Timestamp: now.Unix() - 2, Seq: 1}, now); err != nil {
+		t.Errorf("check() for new node = %v", err)
+	}
+}
+
+func TestAllowWithdraw(t *testing.T) {
+	healthyUp := Peer{State: State{Node: "a", State: "success", Up: true}, Alive: true}
+	healthyDown := Peer{State: State{Node: "b", State: "warn"}, Alive: true}
+	failed := Peer{State: State{Node: "c", State: "error", Up: true}, Alive: true}
+	dead := Peer{State: State{Node: "d", State: "success", Up: true}}
+
+	tests := []struct {
+		name       string
+		policy     Policy
+		minHealthy int
+		peers      []Peer
+		want       bool
+	}{
+		{"none", PolicyNone, 0, []Peer{failed}, true},
+		{"min healthy", PolicyMinHealthy, 1, []Peer{healthyUp, failed}, true},
+		{"min healthy not up", PolicyMinHealthy, 1, []Peer{healthyDown, failed}, false},
+		{"min healthy dead", PolicyMinHealthy, 1, []Peer{dead, failed}, false},
+		{"local", PolicyLocalOnly, 0, []Peer{healthyDown, failed}, true},
+		{"shared", PolicyLocalOnly, 0, []Peer{failed, dead}, false},
+		{"no alive peers", PolicyLocalOnly, 0, []Peer{dead}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, reason := AllowWithdraw(tt.policy, tt.minHealthy, tt.peers, time.Time{}); got != tt.want {
+				t.Errorf("AllowWithdraw() = (%v, %q), want %v", got, reason, tt.want)
+			}
+		})
+	}
+	// peer state not updated after local failure
+	now := time.Now()
+	healthyUp.LastSeen = now.Add(-time.Second)
+	if ok, reason := AllowWithdraw(PolicyLocalOnly, 0, []Peer{healthyUp}, now); ok || reason != "waiting for peer a state" {
+		t.Errorf("AllowWithdraw() with outdated peer state = (%v, %q)", ok, reason)
+	}
+	if ok, _ := AllowWithdraw(PolicyLocalOnly, 0, []Peer{healthyUp}, now.Add(-time.Minute)); !ok {
+		t.Errorf("AllowWithdraw() with updated peer state not allowed")
+	}
+
+	if _, err := ParsePolicy("invalid"); err == nil {
+		t.Errorf("ParsePolicy() must fail")
+	}
+}
@@ -0,0 +1,67 @@
+package peers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Policy for withdraw decision
+type Policy string
+
+const (
+	// PolicyNone always allow withdraw (peers states are informational)
+	PolicyNone Policy = "none"
+	// PolicyMinHealthy allow withdraw, if at least MinHealthy peers remain healthy and in service
+	PolicyMinHealthy Policy = "min_healthy"
+	// PolicyLocalOnly allow withdraw only on local failure (some alive peer is healthy),
+	// if no alive peers, local state is used
+	PolicyLocalOnly Policy = "local_only"
+)
+
+// ParsePolicy parse policy name (none, if empty)
+func ParsePolicy(s string) (Policy, error) {
+	switch Policy(s) {
+	case "", PolicyNone:
+		return PolicyNone, nil
+	case PolicyMinHealthy, PolicyLocalOnly:
+		return Policy(s), nil
+	default:
+		return PolicyNone, fmt.Errorf("peers: policy %s unknown", s)
+	}
+}
+
+// AllowWithdraw check local node withdraw with peers states, received after local failure since time,
+// return reason, if withdraw not allowed (or alive peers states not received yet)
+func AllowWithdraw(policy Policy, minHealthy int, peers []Peer, since time.Time) (bool, string) {
+	if policy == PolicyNone {
+		return true, ""
+	}
+	var alive, healthy, healthyUp int
+	for i := range peers {
+		if !peers[i].Alive {
+			continue
+		}
+		if peers[i].LastSeen.Before(since) {
+			return false, "waiting for peer " + peers[i].Node + " state"
+		}
+		alive++
+		if peers[i].Healthy() {
+			healthy++
+			if peers[i].Up {
+				healthyUp++
+			}
+		}
+	}
+	switch policy {
+	case PolicyMinHealthy:
+		if healthyUp < minHealthy {
+			return false, strconv.Itoa(healthyUp) + " healthy peers remain, min " + strconv.Itoa(minHealthy)
+		}
+	case PolicyLocalOnly:
+		if alive > 0 && healthy == 0 {
+			return false, "failure is not local, " + strconv.Itoa(alive) + " alive peers failed"
+		}
+	}
+	return true, ""
+}
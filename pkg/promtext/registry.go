@@ -0,0 +1,155 @@
+package promtext
+
+import (
+	"bytes"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/msaf1980/relaymon/pkg/checker"
+)
+
+// MetricType Prometheus metric type
+type MetricType int8
+
+const (
+	// Gauge metric
+	Gauge MetricType = iota
+	// Counter metric
+	Counter
+)
+
+// String get MetricType string representation
+func (t MetricType) String() string {
+	return [...]string{"gauge", "counter"}[t]
+}
+
+type sample struct {
+	labels string
+	value  float64
+}
+
+type family struct {
+	name    string
+	help    string
+	typ     MetricType
+	samples map[string]*sample
+}
+
+// Registry store gauges and counters and expose it in Prometheus text format
+type Registry struct {
+	lock     sync.Mutex
+	prefix   string
+	families map[string]*family
+}
+
+// NewRegistry return new registry instance (prefix prepended to all metric names)
+func NewRegistry(prefix string) *Registry {
+	return &Registry{prefix: prefix, families: make(map[string]*family)}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func labelsString(labels []checker.Label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("{")
+	for i := range labels {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(labels[i].Name)
+		sb.WriteString(`="`)
+		sb.WriteString(labelEscaper.Replace(labels[i].Value))
+		sb.WriteString(`"`)
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+func (r *Registry) sample(name, help string, typ MetricType, labels []checker.Label) *sample {
+	name = r.prefix + name
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{name: name, help: help, typ: typ, samples: make(map[string]*sample)}
+		r.families[name] = f
+	}
+	l := labelsString(labels)
+	s, ok := f.samples[l]
+	if !ok {
+		s = &sample{labels: l}
+		f.samples[l] = s
+	}
+	return s
+}
+
+// SetGauge set gauge value
+func (r *Registry) SetGauge(name, help string, value float64, labels ...checker.Label) {
+	r.lock.Lock()
+	r.sample(name, help, Gauge, labels).value = value
+	r.lock.Unlock()
+}
+
+// AddCounter increment counter value
+func (r *Registry) AddCounter(name, help string, delta float64, labels ...checker.Label) {
+	r.lock.Lock()
+	r.sample(name, help, Counter, labels).value += delta
+	r.lock.Unlock()
+}
+
+// SetCounter set counter value (for counters, maintained outside registry)
+func (r *Registry) SetCounter(name, help string, value float64, labels ...checker.Label) {
+	r.lock.Lock()
+	r.sample(name, help, Counter, labels).value = value
+	r.lock.Unlock()
+}
+
+// Delete remove metric family
+func (r *Registry) Delete(name string) {
+	r.lock.Lock()
+	delete(r.families, r.prefix+name)
+	r.lock.Unlock()
+}
+
+// Write render metrics in Prometheus text format
+func (r *Registry) Write(buf *bytes.Buffer) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := r.families[name]
+		if len(f.help) > 0 {
+			buf.WriteString("# HELP " + f.name + " " + f.help + "\n")
+		}
+		buf.WriteString("# TYPE " + f.name + " " + f.typ.String() + "\n")
+		labels := make([]string, 0, len(f.samples))
+		for l := range f.samples {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+		for _, l := range labels {
+			buf.WriteString(f.name)
+			buf.WriteString(l)
+			buf.WriteString(" ")
+			buf.WriteString(strconv.FormatFloat(f.samples[l].value, 'g', -1, 64))
+			buf.WriteString("\n")
+		}
+	}
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var buf bytes.Buffer
+	r.Write(&buf)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write(buf.Bytes())
+}
@@ -0,0 +1,39 @@
+package promtext
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/msaf1980/relaymon/pkg/checker"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	r := NewRegistry("relaymon_")
+	r.SetGauge("status", "global state", 1)
+	r.SetGauge("carbon_endpoint_state", "", 3,
+		checker.Label{Name: "cluster", Value: "test2"}, checker.Label{Name: "endpoint", Value: "test3:2003"},
+	)
+	r.SetGauge("carbon_endpoint_state", "", 1,
+		checker.Label{Name: "cluster", Value: "test1"}, checker.Label{Name: "endpoint", Value: `te"st\1:2003`},
+	)
+	r.AddCounter("ip_errors_total", "ip addresses add/del failures", 1, checker.Label{Name: "op", Value: "add"})
+	r.AddCounter("ip_errors_total", "ip addresses add/del failures", 2, checker.Label{Name: "op", Value: "add"})
+	r.SetGauge("deleted", "", 1)
+	r.Delete("deleted")
+
+	want := `# TYPE relaymon_carbon_endpoint_state gauge
+relaymon_carbon_endpoint_state{cluster="test1",endpoint="te\"st\\1:2003"} 1
+relaymon_carbon_endpoint_state{cluster="test2",endpoint="test3:2003"} 3
+# HELP relaymon_ip_errors_total ip addresses add/del failures
+# TYPE relaymon_ip_errors_total counter
+relaymon_ip_errors_total{op="add"} 3
+# HELP relaymon_status global state
+# TYPE relaymon_status gauge
+relaymon_status 1
+`
+	var buf bytes.Buffer
+	r.Write(&buf)
+	if buf.String() != want {
+		t.Errorf("Registry.Write() got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
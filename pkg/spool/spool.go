@@ -0,0 +1,244 @@
+package spool
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const suffix = ".spool"
+
+// ErrClosed is returned on write to closed spool
+var ErrClosed = errors.New("spool: closed")
+
+type segment struct {
+	id    uint64
+	size  int64
+	count int
+}
+
+// Spool is disk queue of text records (one record per line), splitted to segment files.
+// If total size exceeded, oldest segments are removed.
+type Spool struct {
+	lock        sync.Mutex
+	dir         string
+	segmentSize int64
+	maxSize     int64
+
+	segments []segment
+	// w is writer for last segment
+	w      *os.File
+	size   int64
+	count  int
+	closed bool
+}
+
+// Open open spool directory (created if not exist) and load existing segments
+func Open(dir string, segmentSize, maxSize int64) (*Spool, error) {
+	if segmentSize < 1 || maxSize < segmentSize {
+		return nil, fmt.Errorf("spool: segment size must be positive and not greater than max size")
+	}
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &Spool{dir: dir, segmentSize: segmentSize, maxSize: maxSize, segments: []segment{}}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), suffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(f.Name(), suffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		seg := segment{id: id, size: int64(len(data)), count: bytes.Count(data, []byte{'\n'})}
+		s.segments = append(s.segments, seg)
+		s.size += seg.size
+		s.count += seg.count
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].id < s.segments[j].id })
+	return s, nil
+}
+
+func (s *Spool) path(id uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", id, suffix))
+}
+
+// rotate close current segment and create new one
+func (s *Spool) rotate() error {
+	if s.w != nil {
+		_ = s.w.Close()
+		s.w = nil
+	}
+	var id uint64 = 1
+	if len(s.segments) > 0 {
+		id = s.segments[len(s.segments)-1].id + 1
+	}
+	w, err := os.OpenFile(s.path(id), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0640)
+	if err != nil {
+		return err
+	}
+	s.w = w
+	s.segments = append(s.segments, segment{id: id})
+	return nil
+}
+
+func (s *Spool) flush(buf *bytes.Buffer, n int) error {
+	if buf.Len() == 0 {
+		return nil
+	}
+	written, err := s.w.Write(buf.Bytes())
+	if err != nil {
+		// account partially written records (last incomplete record is skipped on read)
+		// and write next records to new segment
+		n = bytes.Count(buf.Bytes()[:written], []byte{'\n'})
+		_ = s.w.Close()
+		s.w = nil
+	}
+	buf.Reset()
+	seg := &s.segments[len(s.segments)-1]
+	seg.size += int64(written)
+	seg.count += n
+	s.size += int64(written)
+	s.count += n
+	return err
+}
+
+// Write append records to spool, return count of dropped records (from oldest segments, if max size exceeded),
+// ErrClosed after Close
+func (s *Spool) Write(records []string) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return 0, ErrClosed
+	}
+	var (
+		buf bytes.Buffer
+		n   int
+	)
+	for _, r := range records {
+		if s.w == nil {
+			if err := s.rotate(); err != nil {
+				return 0, err
+			}
+		} else if size := s.segments[len(s.segments)-1].size + int64(buf.Len()); size > 0 && size+int64(len(r))+1 > s.segmentSize {
+			if err := s.flush(&buf, n); err != nil {
+				return 0, err
+			}
+			n = 0
+			if err := s.rotate(); err != nil {
+				return 0, err
+			}
+		}
+		buf.WriteString(r)
+		buf.WriteByte('\n')
+		n++
+	}
+	if err := s.flush(&buf, n); err != nil {
+		return 0, err
+	}
+
+	dropped := 0
+	// current segment is removed too, if single record exceed max size
+	for s.size > s.maxSize && len(s.segments) > 0 {
+		n, err := s.remove(s.segments[0].id)
+		dropped += n
+		if err != nil {
+			return dropped, err
+		}
+	}
+	return dropped, nil
+}
+
+// Oldest return oldest segment id and records (segment is closed for write), id is 0 if spool is empty
+func (s *Spool) Oldest() (uint64, []string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.segments) == 0 {
+		return 0, nil, nil
+	}
+	if len(s.segments) == 1 && s.w != nil {
+		// next write go to new segment
+		_ = s.w.Close()
+		s.w = nil
+	}
+	id := s.segments[0].id
+	data, err := ioutil.ReadFile(s.path(id))
+	if err != nil {
+		return id, nil, err
+	}
+	records := strings.Split(string(data), "\n")
+	// skip last empty line or incomplete record
+	return id, records[:len(records)-1], nil
+}
+
+func (s *Spool) remove(id uint64) (int, error) {
+	for i := range s.segments {
+		if s.segments[i].id == id {
+			if i == len(s.segments)-1 && s.w != nil {
+				_ = s.w.Close()
+				s.w = nil
+			}
+			count := s.segments[i].count
+			s.size -= s.segments[i].size
+			s.count -= count
+			s.segments = append(s.segments[:i], s.segments[i+1:]...)
+			err := os.Remove(s.path(id))
+			if os.IsNotExist(err) {
+				return count, nil
+			}
+			return count, err
+		}
+	}
+	return 0, nil
+}
+
+// Remove remove segment (after records processed), return segment records count
+func (s *Spool) Remove(id uint64) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.remove(id)
+}
+
+// Len return spooled records count
+func (s *Spool) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.count
+}
+
+// Size return spool size in bytes
+func (s *Spool) Size() int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.size
+}
+
+// Close close current segment, next writes fail with ErrClosed
+func (s *Spool) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+	if s.w == nil {
+		return nil
+	}
+	err := s.w.Close()
+	s.w = nil
+	return err
+}
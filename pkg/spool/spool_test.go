@@ -0,0 +1,166 @@
+package spool
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func records(from, to int) []string {
+	r := make([]string, 0, to-from)
+	for i := from; i < to; i++ {
+		// 16 bytes with new line
+		r = append(r, "metric."+strconv.Itoa(100+i)+" 1 10")
+	}
+	return r
+}
+
+func TestSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "relaymon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 4 records per segment, max 3 segments
+	s, err := Open(dir, 64, 192)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, r, err := s.Oldest(); id != 0 || len(r) != 0 || err != nil {
+		t.Fatalf("Spool.Oldest() on empty spool got %d, %v, %v", id, r, err)
+	}
+	if dropped, err := s.Write(records(0, 10)); dropped != 0 || err != nil {
+		t.Fatalf("Spool.Write() got %d, %v", dropped, err)
+	}
+	if s.Len() != 10 || s.Size() != 160 {
+		t.Fatalf("Spool.Len() = %d, Spool.Size() = %d", s.Len(), s.Size())
+	}
+	// segments 1 (4 records), 2 (4 records), 3 (4 records), 4 (2 records) - drop oldest
+	if dropped, err := s.Write(records(10, 14)); dropped != 4 || err != nil {
+		t.Fatalf("Spool.Write() got %d, %v", dropped, err)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// reopen
+	if s, err = Open(dir, 64, 192); err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 10 {
+		t.Fatalf("Spool.Len() after reopen = %d", s.Len())
+	}
+	var got []string
+	for {
+		id, r, err := s.Oldest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id == 0 {
+			break
+		}
+		got = append(got, r...)
+		if n, err := s.Remove(id); err != nil || n != len(r) {
+			t.Fatalf("Spool.Remove() got %d, %v, want %d", n, err, len(r))
+		}
+	}
+	if want := records(4, 14); !reflect.DeepEqual(got, want) {
+		t.Errorf("Spool records got %v, want %v", got, want)
+	}
+	if s.Len() != 0 || s.Size() != 0 {
+		t.Errorf("Spool.Len() = %d, Spool.Size() = %d after drain", s.Len(), s.Size())
+	}
+
+	// write after current segment read
+	if _, err = s.Write(records(0, 2)); err != nil {
+		t.Fatal(err)
+	}
+	id, r, _ := s.Oldest()
+	if _, err = s.Write(records(2, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r, records(0, 2)) {
+		t.Errorf("Spool.Oldest() got %v", r)
+	}
+	_, _ = s.Remove(id)
+	if s.Len() != 1 {
+		t.Errorf("Spool.Len() = %d, want 1", s.Len())
+	}
+	_ = s.Close()
+}
+
+func TestSpoolMaxSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "relaymon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := Open(dir, 32, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if dropped, err := s.Write(records(0, 2)); dropped != 0 || err != nil {
+		t.Fatalf("Spool.Write() got %d, %v", dropped, err)
+	}
+	// single record exceed max size
+	long := "metric.long_long_long_long_long_long 1 10"
+	if dropped, err := s.Write([]string{long}); dropped != 3 || err != nil {
+		t.Fatalf("Spool.Write() got %d, %v, want 3 dropped", dropped, err)
+	}
+	if s.Len() != 0 || s.Size() != 0 {
+		t.Errorf("Spool.Len() = %d, Spool.Size() = %d, want empty", s.Len(), s.Size())
+	}
+	if dropped, err := s.Write(records(2, 3)); dropped != 0 || err != nil {
+		t.Fatalf("Spool.Write() got %d, %v", dropped, err)
+	}
+	if id, r, err := s.Oldest(); id == 0 || !reflect.DeepEqual(r, records(2, 3)) || err != nil {
+		t.Errorf("Spool.Oldest() got %d, %v, %v", id, r, err)
+	}
+
+	_ = s.Close()
+	if _, err := s.Write(records(3, 4)); err != ErrClosed {
+		t.Errorf("Spool.Write() after close got %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestSpoolWriteError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "relaymon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := Open(dir, 64, 192)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err = s.Write(records(0, 2)); err != nil {
+		t.Fatal(err)
+	}
+	// segment write failed
+	_ = s.w.Close()
+	if _, err = s.Write(records(2, 3)); err == nil {
+		t.Fatal("Spool.Write() to closed segment must fail")
+	}
+	if s.Len() != 2 || s.Size() != 32 {
+		t.Errorf("Spool.Len() = %d, Spool.Size() = %d after write error, want 2, 32", s.Len(), s.Size())
+	}
+	// next records written to new segment
+	if _, err = s.Write(records(3, 4)); err != nil {
+		t.Fatal(err)
+	}
+	var size int64
+	files, _ := ioutil.ReadDir(dir)
+	for _, f := range files {
+		size += f.Size()
+	}
+	if len(files) != 2 || s.Len() != 3 || s.Size() != size {
+		t.Errorf("Spool.Len() = %d, Spool.Size() = %d, want 3, %d (%d segments)", s.Len(), s.Size(), size, len(files))
+	}
+}
@@ -0,0 +1,102 @@
+package statefile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/msaf1980/relaymon/pkg/checker"
+)
+
+// Version of state file format
+const Version = 1
+
+// ErrStale is returned for state, saved before staleness limit
+var ErrStale = errors.New("state is stale")
+
+// Checker is persisted checker state
+type Checker struct {
+	Name     string           `json:"name"`
+	State    checker.State    `json:"state"`
+	Counters checker.Counters `json:"counters"`
+}
+
+// State is persisted relaymon state
+type State struct {
+	Version int `json:"version"`
+	// Timestamp of save
+	Timestamp int64 `json:"timestamp"`
+	// Status is global state
+	Status checker.State `json:"status"`
+	// LastTransition is timestamp of last up/down transition
+	LastTransition int64 `json:"last_transition"`
+	// Downs is timestamps of down transitions within hysteresis window
+	Downs []int64 `json:"downs,omitempty"`
+	// FlapLocked is flap lock state (cleared only manually)
+	FlapLocked     bool      `json:"flap_locked,omitempty"`
+	FlapLockReason string    `json:"flap_lock_reason,omitempty"`
+	Checkers       []Checker `json:"checkers"`
+	// Iface and IPs configured (owned) by relaymon
+	Iface string   `json:"iface"`
+	IPs   []string `json:"ips"`
+}
+
+// Checker return persisted checker state by name
+func (s *State) Checker(name string) (Checker, bool) {
+	for i := range s.Checkers {
+		if s.Checkers[i].Name == name {
+			return s.Checkers[i], true
+		}
+	}
+	return Checker{}, false
+}
+
+// Save write state to file atomically (with temporary file in same dir)
+func Save(path string, s *State) error {
+	s.Version = Version
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err = f.Write(b); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// Load read state from file, return ErrStale, if state saved more than maxAge before now (not checked, if maxAge is 0)
+func Load(path string, maxAge time.Duration, now time.Time) (*State, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s State
+	if err = json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("state file %s: %s", path, err.Error())
+	}
+	if s.Version != Version {
+		return nil, fmt.Errorf("state file %s: version %d unsupported", path, s.Version)
+	}
+	if maxAge > 0 && now.Sub(time.Unix(s.Timestamp, 0)) > maxAge {
+		return &s, ErrStale
+	}
+	return &s, nil
+}
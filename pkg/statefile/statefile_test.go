@@ -0,0 +1,78 @@
+package statefile
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/msaf1980/relaymon/pkg/checker"
+)
+
+func TestSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "relaymon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := path.Join(dir, "relaymon.state")
+
+	now := time.Unix(1600000000, 0)
+	s := &State{
+		Timestamp:      now.Unix(),
+		Status:         checker.SuccessState,
+		LastTransition: now.Unix() - 60,
+		Downs:          []int64{now.Unix() - 600, now.Unix() - 60},
+		FlapLocked:     true,
+		FlapLockReason: "3 down transitions in 10m0s",
+		Checkers: []Checker{
+			{Name: "carbon-c-relay", State: checker.SuccessState, Counters: checker.Counters{Success: 4, Checked: 10}},
+		},
+		Iface: "lo",
+		IPs:   []string{"192.168.155.10/32"},
+	}
+	if err = Save(file, s); err != nil {
+		t.Fatal(err)
+	}
+	// overwrite
+	if err = Save(file, s); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("temporary files not removed: %d files", len(files))
+	}
+
+	got, err := Load(file, time.Minute, now.Add(30*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, s) {
+		t.Errorf("Load() got\n%+v\nwant\n%+v", got, s)
+	}
+	if c, ok := got.Checker("carbon-c-relay"); !ok || c.Counters.Success != 4 {
+		t.Errorf("Checker() = (%+v, %v)", c, ok)
+	}
+	if _, ok := got.Checker("unknown"); ok {
+		t.Errorf("Checker() found unknown checker")
+	}
+
+	if _, err = Load(file, time.Minute, now.Add(2*time.Minute)); err != ErrStale {
+		t.Errorf("Load() error = %v, want %v", err, ErrStale)
+	}
+	if _, err = Load(file, 0, now.Add(time.Hour)); err != nil {
+		t.Errorf("Load() without staleness limit error = %v", err)
+	}
+
+	if err = ioutil.WriteFile(file, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Load(file, 0, now); err == nil {
+		t.Errorf("Load() must fail for corrupted file")
+	}
+	if _, err = Load(path.Join(dir, "not_exist"), 0, now); !os.IsNotExist(err) {
+		t.Errorf("Load() error = %v, want not exist", err)
+	}
+}
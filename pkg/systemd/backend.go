@@ -0,0 +1,41 @@
+package systemd
+
+import "fmt"
+
+// Backend get systemd service state
+type Backend interface {
+	// ServiceState return service state
+	ServiceState(name string) (*Service, error)
+}
+
+// RestartsCounter is implemented by backends, which report systemd restarts counter (NRestarts)
+// and main process start time (ExecMainStartTimestamp), so service state can be queried on every check
+type RestartsCounter interface {
+	CountRestarts() bool
+}
+
+// SystemctlBackend get service state with parse systemctl status output
+type SystemctlBackend struct{}
+
+// ServiceState return service state
+func (SystemctlBackend) ServiceState(name string) (*Service, error) {
+	return ServiceState(name)
+}
+
+// NewBackend return backend by name (systemctl, dbus or auto - dbus, if system bus is available)
+func NewBackend(name string) (Backend, error) {
+	switch name {
+	case "systemctl":
+		return SystemctlBackend{}, nil
+	case "dbus":
+		return NewDBusBackend(NewSystemBus())
+	case "", "auto":
+		b, err := NewDBusBackend(NewSystemBus())
+		if err != nil {
+			return SystemctlBackend{}, nil
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("unknown systemd backend %s", name)
+	}
+}
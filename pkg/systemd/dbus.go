@@ -0,0 +1,179 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	dbusDest        = "org.freedesktop.systemd1"
+	dbusPath        = "/org/freedesktop/systemd1"
+	dbusManager     = "org.freedesktop.systemd1.Manager"
+	dbusUnit        = "org.freedesktop.systemd1.Unit"
+	dbusService     = "org.freedesktop.systemd1.Service"
+	dbusGetAll      = "org.freedesktop.DBus.Properties.GetAll"
+	dbusCallTimeout = 10 * time.Second
+)
+
+// Bus get systemd unit properties
+type Bus interface {
+	// Connect check bus connection
+	Connect() error
+	// UnitProperties return unit properties for interface (org.freedesktop.systemd1.Unit or org.freedesktop.systemd1.Service)
+	UnitProperties(unit string, iface string) (map[string]interface{}, error)
+}
+
+// SystemBus D-Bus system bus connection (reconnected after errors)
+type SystemBus struct {
+	lock sync.Mutex
+	conn *dbus.Conn
+}
+
+// NewSystemBus return new system bus instance (not connected)
+func NewSystemBus() *SystemBus {
+	return &SystemBus{}
+}
+
+// Connect connect to system bus (if not connected)
+func (b *SystemBus) Connect() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.connect()
+}
+
+func (b *SystemBus) connect() error {
+	if b.conn != nil && b.conn.Connected() {
+		return nil
+	}
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return err
+	}
+	b.conn = conn
+	return nil
+}
+
+// UnitProperties return unit properties for interface
+func (b *SystemBus) UnitProperties(unit string, iface string) (map[string]interface{}, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if err := b.connect(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbusCallTimeout)
+	defer cancel()
+
+	var path dbus.ObjectPath
+	err := b.conn.Object(dbusDest, dbusPath).CallWithContext(ctx, dbusManager+".LoadUnit", 0, unit).Store(&path)
+	if err != nil {
+		b.conn.Close()
+		return nil, err
+	}
+
+	var props map[string]dbus.Variant
+	err = b.conn.Object(dbusDest, path).CallWithContext(ctx, dbusGetAll, 0, iface).Store(&props)
+	if err != nil {
+		b.conn.Close()
+		return nil, err
+	}
+
+	values := make(map[string]interface{}, len(props))
+	for k, v := range props {
+		values[k] = v.Value()
+	}
+	return values, nil
+}
+
+// DBusBackend get service state with systemd D-Bus API
+type DBusBackend struct {
+	bus Bus
+}
+
+// NewDBusBackend return new D-Bus backend instance
+func NewDBusBackend(bus Bus) (*DBusBackend, error) {
+	if err := bus.Connect(); err != nil {
+		return nil, err
+	}
+	return &DBusBackend{bus: bus}, nil
+}
+
+// CountRestarts D-Bus backend report NRestarts and ExecMainStartTimestamp
+func (b *DBusBackend) CountRestarts() bool {
+	return true
+}
+
+func unitName(name string) string {
+	if strings.Contains(name, ".") {
+		return name
+	}
+	return name + ".service"
+}
+
+func propString(props map[string]interface{}, name string) string {
+	s, _ := props[name].(string)
+	return s
+}
+
+func propUint64(props map[string]interface{}, name string) uint64 {
+	switch v := props[name].(type) {
+	case uint32:
+		return uint64(v)
+	case uint64:
+		return v
+	default:
+		return 0
+	}
+}
+
+// ServiceState return service state
+func (b *DBusBackend) ServiceState(name string) (*Service, error) {
+	service := &Service{PID: -1, State: UnknownState}
+	unit := unitName(name)
+
+	props, err := b.bus.UnitProperties(unit, dbusUnit)
+	if err != nil {
+		return service, fmt.Errorf("service %s %s", name, err.Error())
+	}
+	service.ActiveState = propString(props, "ActiveState")
+	service.SubState = propString(props, "SubState")
+	if propString(props, "LoadState") == "not-found" {
+		service.State = NotFoundState
+		return service, fmt.Errorf("service %s not found", name)
+	}
+
+	props, err = b.bus.UnitProperties(unit, dbusService)
+	if err != nil {
+		return service, fmt.Errorf("service %s %s", name, err.Error())
+	}
+	service.NRestarts = propUint64(props, "NRestarts")
+	if usec := propUint64(props, "ExecMainStartTimestamp"); usec > 0 {
+		service.StartTime = time.Unix(int64(usec/1000000), int64(usec%1000000)*1000)
+	}
+
+	switch service.ActiveState {
+	case "active", "reloading":
+		pid := propUint64(props, "MainPID")
+		if pid == 0 {
+			service.State = StartedState
+			return service, fmt.Errorf("service %s can't extract pid", name)
+		}
+		service.PID = int64(pid)
+		service.State = StartedState
+		return service, nil
+	case "failed":
+		service.State = FailedState
+		return service, fmt.Errorf("service %s failed", name)
+	case "inactive", "activating", "deactivating":
+		service.State = StoppedState
+		return service, fmt.Errorf("service %s stopped", name)
+	default:
+		return service, fmt.Errorf("service %s unknown state %s", name, service.ActiveState)
+	}
+}
@@ -0,0 +1,187 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/msaf1980/relaymon/pkg/checker"
+)
+
+type fakeBus struct {
+	units map[string]map[string]map[string]interface{}
+	err   error
+}
+
+func (b *fakeBus) Connect() error {
+	return b.err
+}
+
+func (b *fakeBus) UnitProperties(unit string, iface string) (map[string]interface{}, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	props, ok := b.units[unit]
+	if !ok {
+		if iface == dbusUnit {
+			return map[string]interface{}{"LoadState": "not-found", "ActiveState": "inactive", "SubState": "dead"}, nil
+		}
+		return map[string]interface{}{"MainPID": uint32(0), "NRestarts": uint32(0), "ExecMainStartTimestamp": uint64(0)}, nil
+	}
+	return props[iface], nil
+}
+
+func newFakeUnit(active, sub string, pid uint32, restarts uint32, start uint64) map[string]map[string]interface{} {
+	return map[string]map[string]interface{}{
+		dbusUnit:    {"LoadState": "loaded", "ActiveState": active, "SubState": sub},
+		dbusService: {"MainPID": pid, "NRestarts": restarts, "ExecMainStartTimestamp": start},
+	}
+}
+
+func TestDBusBackend_ServiceState(t *testing.T) {
+	bus := &fakeBus{units: map[string]map[string]map[string]interface{}{
+		"sshd.service":       newFakeUnit("active", "running", 1234, 2, 1598000000000001),
+		"stopped.service":    newFakeUnit("inactive", "dead", 0, 0, 0),
+		"failed.service":     newFakeUnit("failed", "failed", 0, 5, 1598000000000000),
+		"activating.service": newFakeUnit("activating", "auto-restart", 0, 3, 1598000000000000),
+		"oneshot.service":    newFakeUnit("active", "exited", 0, 0, 1598000000000000),
+	}}
+	backend, err := NewDBusBackend(bus)
+	if err != nil {
+		t.Fatalf("NewDBusBackend() error = %s", err.Error())
+	}
+
+	tests := []struct {
+		service string
+		want    Service
+		wantErr string
+	}{
+		{
+			"sshd",
+			Service{PID: 1234, State: StartedState, StartTime: time.Unix(1598000000, 1000),
+				ActiveState: "active", SubState: "running", NRestarts: 2},
+			"",
+		},
+		{
+			"relaymon_not_found",
+			Service{PID: -1, State: NotFoundState, ActiveState: "inactive", SubState: "dead"},
+			"service relaymon_not_found not found",
+		},
+		{
+			"stopped.service",
+			Service{PID: -1, State: StoppedState, ActiveState: "inactive", SubState: "dead"},
+			"service stopped.service stopped",
+		},
+		{
+			"failed",
+			Service{PID: -1, State: FailedState, StartTime: time.Unix(1598000000, 0),
+				ActiveState: "failed", SubState: "failed", NRestarts: 5},
+			"service failed failed",
+		},
+		{
+			"activating",
+			Service{PID: -1, State: StoppedState, StartTime: time.Unix(1598000000, 0),
+				ActiveState: "activating", SubState: "auto-restart", NRestarts: 3},
+			"service activating stopped",
+		},
+		{
+			"oneshot",
+			Service{PID: -1, State: StartedState, StartTime: time.Unix(1598000000, 0),
+				ActiveState: "active", SubState: "exited"},
+			"service oneshot can't extract pid",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.service, func(t *testing.T) {
+			got, err := backend.ServiceState(tt.service)
+			if err == nil {
+				if tt.wantErr != "" {
+					t.Errorf("DBusBackend.ServiceState() error = nil, want '%s'", tt.wantErr)
+				}
+			} else if err.Error() != tt.wantErr {
+				t.Errorf("DBusBackend.ServiceState() error = '%s', want '%s'", err.Error(), tt.wantErr)
+			}
+			if *got != tt.want {
+				t.Errorf("DBusBackend.ServiceState() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDBusBackend_BusError(t *testing.T) {
+	bus := &fakeBus{}
+	backend, err := NewDBusBackend(bus)
+	if err != nil {
+		t.Fatalf("NewDBusBackend() error = %s", err.Error())
+	}
+	bus.err = fmt.Errorf("connection closed")
+	got, err := backend.ServiceState("sshd")
+	if err == nil || err.Error() != "service sshd connection closed" {
+		t.Errorf("DBusBackend.ServiceState() error = %v", err)
+	}
+	if got.State != UnknownState {
+		t.Errorf("DBusBackend.ServiceState() state = %v, want %v", got.State, UnknownState)
+	}
+
+	if _, err = NewDBusBackend(&fakeBus{err: fmt.Errorf("no bus")}); err == nil {
+		t.Errorf("NewDBusBackend() must fail without bus")
+	}
+}
+
+func TestServiceChecker_RestartsPolicy(t *testing.T) {
+	failCount := 100
+	checkCount := 2
+	resetCount := 2
+	maxRestarts := 2
+	window := 30 * time.Second
+
+	// pid of test process (not a systemd child, so checks counted as failed, but service not go to error state)
+	pid := uint32(os.Getpid())
+	start := uint64(1598000000000000)
+	bus := &fakeBus{units: map[string]map[string]map[string]interface{}{
+		"test.service": newFakeUnit("active", "running", pid, 1, start),
+	}}
+	backend, err := NewDBusBackend(bus)
+	if err != nil {
+		t.Fatalf("NewDBusBackend() error = %s", err.Error())
+	}
+
+	s := NewServiceChecker("test", failCount, checkCount, resetCount).SetBackend(backend).
+		SetRestartsPolicy(maxRestarts, window)
+
+	tests := []struct {
+		timestamp  int64
+		nRestarts  uint32
+		start      uint64
+		want       checker.State
+		wantEvents []string
+		wantTotal  string
+	}{
+		{1000, 1, start, checker.CollectingState, nil, "0"},
+		{1010, 1, start, checker.WarnState, nil, "0"},
+		// systemd automatic restarts
+		{1020, 3, start + 1, checker.WarnState, nil, "2"},
+		// manual restart (start time changed)
+		{1025, 3, start + 2, checker.ErrorState, []string{"restarted more than 2 times in 30s"}, "3"},
+		{1030, 3, start + 2, checker.ErrorState, nil, "3"},
+		// restarts out of window
+		{1060, 3, start + 2, checker.WarnState, []string{"restarts flapping end"}, "3"},
+	}
+	for i, tt := range tests {
+		bus.units["test.service"] = newFakeUnit("active", "running", pid, tt.nRestarts, tt.start)
+		got, events := s.Status(context.Background(), tt.timestamp)
+		if got != tt.want {
+			t.Errorf("Step %d ServiceChecker.Status() got = %v, want %v", i, got, tt.want)
+		}
+		if !reflect.DeepEqual(events, tt.wantEvents) {
+			t.Errorf("Step %d ServiceChecker.Status() events got = %v, want %v", i, events, tt.wantEvents)
+		}
+		metrics := s.Metrics()
+		if metrics[1].Name != "systemd_restarts.test" || metrics[1].Value != tt.wantTotal {
+			t.Errorf("Step %d ServiceChecker.Metrics()[1] got = %+v, want %s", i, metrics[1], tt.wantTotal)
+		}
+	}
+}
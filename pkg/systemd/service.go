@@ -50,6 +50,11 @@ type Service struct {
 	PID       int64
 	State     State
 	StartTime time.Time
+
+	// D-Bus backend only
+	ActiveState string
+	SubState    string
+	NRestarts   uint64
 }
 
 // $ sudo systemctl status sshd
@@ -129,7 +134,10 @@ func ServiceState(name string) (*Service, error) {
 
 // ServiceChecker systemd service (implement pkg/checker/Checker interface)
 type ServiceChecker struct {
-	name string
+	name    string
+	backend Backend
+	// expected main process name (as in /proc/<pid>/stat)
+	process string
 
 	event string
 
@@ -145,12 +153,23 @@ type ServiceChecker struct {
 	failCount  int
 	checkCount int
 	resetCount int
+
+	// restarts tracking
+	pid            int64
+	nRestarts      uint64
+	startTime      time.Time
+	restartsTotal  uint64
+	restarts       []time.Time
+	maxRestarts    int
+	restartsWindow time.Duration
+	flapping       bool
 }
 
 // NewServiceChecker return new systemd service instance
 func NewServiceChecker(name string, failCount int, checkCount int, resetCount int) *ServiceChecker {
 	service := &ServiceChecker{
 		name:       name,
+		backend:    SystemctlBackend{},
 		failCount:  failCount,
 		checkCount: checkCount,
 		resetCount: resetCount,
@@ -159,6 +178,33 @@ func NewServiceChecker(name string, failCount int, checkCount int, resetCount in
 	return service
 }
 
+// SetBackend set backend for get service state
+func (s *ServiceChecker) SetBackend(backend Backend) *ServiceChecker {
+	s.backend = backend
+	return s
+}
+
+// SetRestartsPolicy set max restarts per window (service go to error state, if exceeded), disabled if maxRestarts < 1
+func (s *ServiceChecker) SetRestartsPolicy(maxRestarts int, window time.Duration) *ServiceChecker {
+	s.maxRestarts = maxRestarts
+	s.restartsWindow = window
+	return s
+}
+
+// SetProcess set expected main process name (checked if not empthy)
+func (s *ServiceChecker) SetProcess(name string) *ServiceChecker {
+	if len(name) == 0 {
+		s.process = ""
+	} else {
+		// process name in /proc/<pid>/stat truncated to 15 symbols
+		if len(name) > 15 {
+			name = name[:15]
+		}
+		s.process = "(" + name + ")"
+	}
+	return s
+}
+
 // Name get service name
 func (s *ServiceChecker) Name() string {
 	return s.name
@@ -186,11 +232,72 @@ func (s *ServiceChecker) events(event string) []string {
 	return events
 }
 
+// trackRestarts detect restarts with NRestarts, main process start time or pid change
+func (s *ServiceChecker) trackRestarts(service *Service, now time.Time) {
+	if service.PID <= 0 && service.NRestarts == 0 && service.StartTime.IsZero() {
+		return
+	}
+	restarts := uint64(0)
+	if service.NRestarts > s.nRestarts {
+		if s.pid > 0 || !s.startTime.IsZero() {
+			restarts = service.NRestarts - s.nRestarts
+		}
+	} else if !service.StartTime.IsZero() {
+		if !s.startTime.IsZero() && !service.StartTime.Equal(s.startTime) {
+			// manual restart or exit without Restart= policy (reload not change start time)
+			restarts = 1
+		}
+	} else if s.pid > 0 && service.PID > 0 && service.PID != s.pid {
+		restarts = 1
+	}
+
+	s.nRestarts = service.NRestarts
+	if !service.StartTime.IsZero() {
+		s.startTime = service.StartTime
+	}
+	if service.PID > 0 {
+		s.pid = service.PID
+	}
+
+	s.restartsTotal += restarts
+	if s.maxRestarts > 0 {
+		for i := uint64(0); i < restarts && i <= uint64(s.maxRestarts); i++ {
+			s.restarts = append(s.restarts, now)
+		}
+	}
+}
+
+// restartsFlapping check restarts count in window
+func (s *ServiceChecker) restartsFlapping(now time.Time) (bool, []string) {
+	if s.maxRestarts < 1 {
+		return false, nil
+	}
+	n := 0
+	for n < len(s.restarts) && now.Sub(s.restarts[n]) > s.restartsWindow {
+		n++
+	}
+	s.restarts = s.restarts[n:]
+	flapping := len(s.restarts) > s.maxRestarts
+	if flapping != s.flapping {
+		s.flapping = flapping
+		if flapping {
+			return true, []string{fmt.Sprintf("restarted more than %d times in %s", s.maxRestarts, s.restartsWindow)}
+		}
+		return false, []string{"restarts flapping end"}
+	}
+	return flapping, nil
+}
+
 // Status get result of service status check
 func (s *ServiceChecker) Status(ctx context.Context, timestamp int64) (checker.State, []string) {
 	needRecheck := false
 	successCheck := false
 
+	now := time.Now()
+	if timestamp > 0 {
+		now = time.Unix(timestamp, 0)
+	}
+
 	if s.Process == nil {
 		needRecheck = true
 	} else {
@@ -209,11 +316,18 @@ func (s *ServiceChecker) Status(ctx context.Context, timestamp int64) (checker.S
 			s.Process = nil
 		} else {
 			successCheck = true
+			if r, ok := s.backend.(RestartsCounter); ok && r.CountRestarts() {
+				service, err := s.backend.ServiceState(s.name)
+				if err == nil {
+					s.trackRestarts(service, now)
+				}
+			}
 		}
 	}
 
 	if needRecheck {
-		service, err := ServiceState(s.name)
+		service, err := s.backend.ServiceState(s.name)
+		s.trackRestarts(service, now)
 		if err != nil {
 			switch service.State {
 			case UnknownState:
@@ -242,7 +356,8 @@ func (s *ServiceChecker) Status(ctx context.Context, timestamp int64) (checker.S
 					return s.status, s.events(procErr.Error())
 				}
 			} else {
-				if proc.PPID != systemdPID || proc.ProcName != service.ProcName {
+				if proc.PPID != systemdPID || (len(service.ProcName) > 0 && proc.ProcName != service.ProcName) ||
+					(len(s.process) > 0 && proc.ProcName != s.process) {
 					if s.failed < math.MaxInt32 {
 						s.failed++
 					}
@@ -269,22 +384,69 @@ func (s *ServiceChecker) Status(ctx context.Context, timestamp int64) (checker.S
 	} else if s.success > 0 {
 		s.success = 0
 	}
+
+	flapping, events := s.restartsFlapping(now)
+
 	if s.checked < s.checkCount {
 		s.status = checker.CollectingState
-		return s.status, nil
+	} else if flapping {
+		s.status = checker.ErrorState
 	} else if s.failed > 0 {
 		if s.failed >= s.failCount {
 			s.status = checker.ErrorState
-			return s.status, nil
+		} else {
+			s.status = checker.WarnState
 		}
-		s.status = checker.WarnState
-		return s.status, nil
+	} else {
+		s.status = checker.SuccessState
 	}
-	s.status = checker.SuccessState
-	return s.status, nil
+	return s.status, events
 }
 
 // Metrics get metric for service status check
 func (s *ServiceChecker) Metrics() []checker.Metric {
-	return []checker.Metric{{Name: "systemd." + s.Name(), Value: strconv.Itoa(int(s.status))}}
+	return []checker.Metric{
+		{
+			Name: "systemd." + s.Name(), Value: strconv.Itoa(int(s.status)),
+			Family: "service_state", Labels: []checker.Label{{Name: "service", Value: s.Name()}},
+		},
+		{
+			Name: "systemd_restarts." + s.Name(), Value: strconv.FormatUint(s.restartsTotal, 10),
+			Family: "service_restarts_total", Labels: []checker.Label{{Name: "service", Value: s.Name()}},
+			Help: "service restarts detected", Counter: true,
+		},
+	}
+}
+
+// Counters get service check results counters
+func (s *ServiceChecker) Counters() checker.Counters {
+	return checker.Counters{Failed: s.failed, Success: s.success, Checked: s.checked}
+}
+
+// Restore set state and counters (persisted before restart), service process is rechecked on next check
+func (s *ServiceChecker) Restore(state checker.State, counters checker.Counters) {
+	s.status = state
+	s.failed = counters.Failed
+	s.success = counters.Success
+	s.checked = counters.Checked
+}
+
+// Merge copy state from previous service checker instance
+func (s *ServiceChecker) Merge(previous checker.Checker) {
+	p, ok := previous.(*ServiceChecker)
+	if !ok || p.name != s.name {
+		return
+	}
+	s.event = p.event
+	s.Process = p.Process
+	s.status = p.status
+	s.failed = p.failed
+	s.success = p.success
+	s.checked = p.checked
+	s.pid = p.pid
+	s.nRestarts = p.nRestarts
+	s.startTime = p.startTime
+	s.restartsTotal = p.restartsTotal
+	s.restarts = p.restarts
+	s.flapping = p.flapping
 }
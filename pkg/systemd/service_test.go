@@ -124,15 +124,15 @@ func TestServiceChecker_Status(t *testing.T) {
 	}{
 		{
 			name: "not_found", service: "relaymon_not_found", want: checker.ErrorState,
-			wantMetrics: []string{"systemd.relaymon_not_found"},
+			wantMetrics: []string{"systemd.relaymon_not_found", "systemd_restarts.relaymon_not_found"},
 		},
 		{
 			name: "active", service: active, want: checker.SuccessState,
-			wantMetrics: []string{"systemd." + active},
+			wantMetrics: []string{"systemd." + active, "systemd_restarts." + active},
 		},
 		{
 			name: "inactive", service: inactive, want: checker.ErrorState,
-			wantMetrics: []string{"systemd." + inactive},
+			wantMetrics: []string{"systemd." + inactive, "systemd_restarts." + inactive},
 		},
 	}
 	for _, tt := range tests {
@@ -148,10 +148,14 @@ func TestServiceChecker_Status(t *testing.T) {
 					t.Errorf("Step %d ServiceChecker.Status() got = %v, want %v", i, got, want)
 				}
 				metrics := s.Metrics()
-				for j := range metrics {
-					if metrics[j].Name != tt.wantMetrics[j] || metrics[j].Value != strconv.Itoa(int(want)) {
-						t.Errorf("Step %d ServiceChecker.Metrics()[%d] got = %v, want %v", i, j, metrics[j], tt.wantMetrics[j])
-					}
+				if len(metrics) != 2 {
+					t.Fatalf("Step %d ServiceChecker.Metrics() got %d metrics, want 2", i, len(metrics))
+				}
+				if metrics[0].Name != tt.wantMetrics[0] || metrics[0].Value != strconv.Itoa(int(want)) {
+					t.Errorf("Step %d ServiceChecker.Metrics()[%d] got = %v, want %v", i, 0, metrics[0], tt.wantMetrics[0])
+				}
+				if metrics[1].Name != tt.wantMetrics[1] || metrics[1].Value != "0" {
+					t.Errorf("Step %d ServiceChecker.Metrics()[%d] got = %v, want %v", i, 1, metrics[1], tt.wantMetrics[1])
 				}
 			}
 		})
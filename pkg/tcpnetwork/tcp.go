@@ -0,0 +1,165 @@
+package tcpnetwork
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/msaf1980/relaymon/pkg/checker"
+	"github.com/msaf1980/relaymon/pkg/neterror"
+)
+
+// Target describe tcp endpoint
+type Target struct {
+	Address  string
+	Timeout  time.Duration
+	Required bool
+
+	err error
+}
+
+type check struct {
+	N   int
+	Err error
+}
+
+func (t *Target) check(ctx context.Context) error {
+	ctxTout, cancel := context.WithTimeout(ctx, t.Timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctxTout, "tcp", t.Address)
+	if err != nil {
+		return neterror.NewNetError(err)
+	}
+	return neterror.NewNetError(conn.Close())
+}
+
+// TCPChecker check group of tcp endpoints with tcp connect (N of M must succeed)
+type TCPChecker struct {
+	name       string
+	targets    []Target
+	minSuccess int
+
+	// check results and thresholds
+	thresholds checker.Thresholds
+
+	metrics []checker.Metric
+}
+
+// NewTCPChecker return new tcp ports checker instance (if minSuccess < 1, all targets must succeed)
+func NewTCPChecker(name string, targets []Target, minSuccess int,
+	failCount int, checkCount int, resetCount int) *TCPChecker {
+
+	if minSuccess < 1 || minSuccess > len(targets) {
+		minSuccess = len(targets)
+	}
+
+	c := &TCPChecker{
+		name:       name,
+		targets:    targets,
+		minSuccess: minSuccess,
+		thresholds: checker.NewThresholds(failCount, checkCount, resetCount),
+		metrics:    make([]checker.Metric, len(targets)),
+	}
+	for i := range targets {
+		c.metrics[i].Name = "network.tcp." + checker.Strip(name) + "." + checker.Strip(targets[i].Address)
+		c.metrics[i].Value = strconv.Itoa(int(checker.CollectingState))
+		c.metrics[i].Family = "tcp_target_state"
+		c.metrics[i].Labels = []checker.Label{
+			{Name: "check", Value: name},
+			{Name: "target", Value: targets[i].Address},
+		}
+	}
+
+	return c
+}
+
+// Name get check name
+func (c *TCPChecker) Name() string {
+	return c.name
+}
+
+// Check probe targets, return success status and errors
+func (c *TCPChecker) Check(ctx context.Context) (bool, []error) {
+	out := make(chan check, len(c.targets))
+	for i := range c.targets {
+		go func(n int) {
+			out <- check{n, c.targets[n].check(ctx)}
+		}(i)
+	}
+
+	errs := make([]error, len(c.targets))
+	successCheck := true
+	succeeded := 0
+	for range c.targets {
+		n := <-out
+		errs[n.N] = n.Err
+		if n.Err == nil {
+			succeeded++
+		} else if c.targets[n.N].Required {
+			successCheck = false
+		}
+	}
+
+	return successCheck && succeeded >= c.minSuccess, errs
+}
+
+// Status get result of tcp ports status check
+func (c *TCPChecker) Status(ctx context.Context, timestamp int64) (checker.State, []string) {
+	events := make([]string, 0)
+
+	successCheck, errs := c.Check(ctx)
+	for i := range errs {
+		if errs[i] != nil {
+			c.metrics[i].Value = strconv.Itoa(int(checker.ErrorState))
+			if checker.ErrorChanged(c.targets[i].err, errs[i]) {
+				events = append(events, fmt.Sprintf("target %s %s", c.targets[i].Address, errs[i].Error()))
+			}
+		} else {
+			c.metrics[i].Value = strconv.Itoa(int(checker.SuccessState))
+			if checker.ErrorChanged(c.targets[i].err, errs[i]) {
+				events = append(events, fmt.Sprintf("target %s up", c.targets[i].Address))
+			}
+		}
+		c.targets[i].err = errs[i]
+	}
+
+	return c.thresholds.Update(successCheck), events
+}
+
+// Metrics get metric for status check
+func (c *TCPChecker) Metrics() []checker.Metric {
+	return c.metrics
+}
+
+// Counters get tcp check results counters
+func (c *TCPChecker) Counters() checker.Counters {
+	return c.thresholds.Counters
+}
+
+// Restore set counters (persisted before restart)
+func (c *TCPChecker) Restore(state checker.State, counters checker.Counters) {
+	c.thresholds.Counters = counters
+}
+
+// Merge copy state (counters and errors for same targets) from previous tcp checker instance
+func (c *TCPChecker) Merge(previous checker.Checker) {
+	p, ok := previous.(*TCPChecker)
+	if !ok {
+		return
+	}
+	c.thresholds.Counters = p.thresholds.Counters
+
+	for i := range c.targets {
+		for j := range p.targets {
+			if c.targets[i].Address == p.targets[j].Address {
+				c.targets[i].err = p.targets[j].err
+				c.metrics[i].Value = p.metrics[j].Value
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package tcpnetwork
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/msaf1980/relaymon/pkg/checker"
+)
+
+func listenAddr(t *testing.T, listen bool) (string, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %s", err.Error())
+	}
+	address := ln.Addr().String()
+	if !listen {
+		ln.Close()
+		return address, func() {}
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	return address, func() { ln.Close() }
+}
+
+func TestTCPChecker_Status(t *testing.T) {
+	failCount := 2
+	checkCount := 3
+	resetCount := 2
+	timeout := time.Second
+
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		up         []bool
+		required   []bool
+		minSuccess int
+		want       checker.State
+	}{
+		{
+			name: "all_success", up: []bool{true, true}, required: []bool{false, false},
+			want: checker.SuccessState,
+		},
+		{
+			name: "all_must_success", up: []bool{true, false}, required: []bool{false, false},
+			want: checker.ErrorState,
+		},
+		{
+			name: "one_of_two", up: []bool{false, true}, required: []bool{false, false}, minSuccess: 1,
+			want: checker.SuccessState,
+		},
+		{
+			name: "required_failed", up: []bool{false, true}, required: []bool{true, false}, minSuccess: 1,
+			want: checker.ErrorState,
+		},
+		{
+			name: "two_of_three", up: []bool{false, true, true}, required: []bool{false, true, false}, minSuccess: 2,
+			want: checker.SuccessState,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets := make([]Target, len(tt.up))
+			for i := range tt.up {
+				address, stop := listenAddr(t, tt.up[i])
+				defer stop()
+				targets[i] = Target{Address: address, Timeout: timeout, Required: tt.required[i]}
+			}
+
+			c := NewTCPChecker(tt.name, targets, tt.minSuccess, failCount, checkCount, resetCount)
+			for i := 0; i < checkCount+1; i++ {
+				got, _ := c.Status(ctx, 0)
+				want := checker.CollectingState
+				if i >= checkCount-1 {
+					want = tt.want
+				}
+				if got != want {
+					t.Errorf("Step %d TCPChecker.Status() got = %v, want %v", i, got, want)
+				}
+				metrics := c.Metrics()
+				for n := range targets {
+					wantMetric := "network.tcp." + tt.name + "." + checker.Strip(targets[n].Address)
+					if metrics[n].Name != wantMetric {
+						t.Errorf("Step %d TCPChecker.Metrics()[%d] name got = '%s', want '%s'", i, n,
+							metrics[n].Name, wantMetric,
+						)
+					}
+					want := checker.ErrorState
+					if tt.up[n] {
+						want = checker.SuccessState
+					}
+					if metrics[n].Value != strconv.Itoa(int(want)) {
+						t.Errorf("Step %d TCPChecker.Metrics()[%d] got = %v, want %v", i, n, metrics[n].Value, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestTCPChecker_Merge(t *testing.T) {
+	prev := NewTCPChecker("tcp", []Target{{Address: "127.0.0.1:2003"}, {Address: "127.0.0.1:2004"}}, 0, 2, 3, 2)
+	prev.thresholds.Counters = checker.Counters{Failed: 0, Success: 4, Checked: 6}
+	prev.metrics[1].Value = strconv.Itoa(int(checker.SuccessState))
+
+	c := NewTCPChecker("tcp", []Target{{Address: "127.0.0.1:2004"}, {Address: "127.0.0.1:2005"}}, 0, 2, 3, 2)
+	c.Merge(prev)
+
+	if got := c.Counters(); got != (checker.Counters{Failed: 0, Success: 4, Checked: 6}) {
+		t.Errorf("TCPChecker.Merge() counters got %+v", got)
+	}
+	if c.metrics[0].Value != strconv.Itoa(int(checker.SuccessState)) {
+		t.Errorf("TCPChecker.Merge() metrics[0] got %s", c.metrics[0].Value)
+	}
+	if c.metrics[1].Value != strconv.Itoa(int(checker.CollectingState)) {
+		t.Errorf("TCPChecker.Merge() metrics[1] got %s", c.metrics[1].Value)
+	}
+}
+
+func TestTCPChecker_Restore(t *testing.T) {
+	address, stop := listenAddr(t, true)
+	defer stop()
+
+	c := NewTCPChecker("tcp", []Target{{Address: address, Timeout: time.Second}}, 0, 2, 3, 2)
+	c.Restore(checker.SuccessState, checker.Counters{Success: 4, Checked: 6})
+	// no collecting state after restore
+	if got, _ := c.Status(context.Background(), 0); got != checker.SuccessState {
+		t.Errorf("TCPChecker.Status() after restore got = %v, want %v", got, checker.SuccessState)
+	}
+	if got := c.Counters(); got != (checker.Counters{Success: 5, Checked: 7}) {
+		t.Errorf("TCPChecker.Counters() after restore got %+v", got)
+	}
+}